@@ -0,0 +1,191 @@
+// Package leap provides leap second announcements based on the IETF/NIST
+// leap-seconds.list file.
+package leap
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Indicator announces a leap second at the end of the current UTC month. The
+// values match those of the NTP leap indicator.
+type Indicator uint8
+
+const (
+	None   Indicator = 0
+	Insert Indicator = 1
+	Delete Indicator = 2
+)
+
+// Entry states that from time At on, TAI is ahead of UTC by Offset seconds.
+type Entry struct {
+	At     time.Time
+	Offset int
+}
+
+// Table is the content of a leap-seconds.list file.
+type Table struct {
+	Updated time.Time
+	Expires time.Time
+	Entries []Entry
+}
+
+var (
+	epoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	errInvalidFile = errors.New("invalid leap seconds file")
+	errInvalidHash = errors.New("leap seconds file hash mismatch")
+)
+
+func (ind Indicator) String() string {
+	switch ind {
+	case None:
+		return "none"
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	x, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return time.Time{}, errInvalidFile
+	}
+	return epoch.Add(time.Duration(x) * time.Second), nil
+}
+
+// Parse reads a leap-seconds.list file from r. If the file contains a hash,
+// the hash must match the data.
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{}
+	var data bytes.Buffer
+	var hash []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, "#$"), strings.HasPrefix(l, "#@"):
+			fs := strings.Fields(l[2:])
+			if len(fs) == 0 {
+				return nil, errInvalidFile
+			}
+			x, err := parseTime(fs[0])
+			if err != nil {
+				return nil, err
+			}
+			if l[1] == '$' {
+				t.Updated = x
+			} else {
+				t.Expires = x
+			}
+			data.WriteString(fs[0])
+		case strings.HasPrefix(l, "#h"):
+			hash = strings.Fields(l[2:])
+		case strings.HasPrefix(l, "#"):
+		default:
+			l, _, _ = strings.Cut(l, "#")
+			fs := strings.Fields(l)
+			if len(fs) == 0 {
+				continue
+			}
+			if len(fs) != 2 {
+				return nil, errInvalidFile
+			}
+			at, err := parseTime(fs[0])
+			if err != nil {
+				return nil, err
+			}
+			off, err := strconv.Atoi(fs[1])
+			if err != nil {
+				return nil, errInvalidFile
+			}
+			if len(t.Entries) != 0 && !at.After(t.Entries[len(t.Entries)-1].At) {
+				return nil, errInvalidFile
+			}
+			t.Entries = append(t.Entries, Entry{At: at, Offset: off})
+			data.WriteString(fs[0])
+			data.WriteString(fs[1])
+		}
+	}
+	err := s.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(t.Entries) == 0 {
+		return nil, errInvalidFile
+	}
+	if hash != nil {
+		if len(hash) != sha1.Size/4 {
+			return nil, errInvalidFile
+		}
+		h := sha1.Sum(data.Bytes())
+		for i := 0; i != len(hash); i++ {
+			x, err := strconv.ParseUint(hash[i], 16, 32)
+			if err != nil {
+				return nil, errInvalidFile
+			}
+			if uint32(x) != binary.BigEndian.Uint32(h[4*i:]) {
+				return nil, errInvalidHash
+			}
+		}
+	}
+	return t, nil
+}
+
+// Load reads the leap-seconds.list file with the given name.
+func Load(name string) (*Table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Expired reports whether the table is no longer valid at time now.
+func (t *Table) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// Pending returns the leap second which the table announces for the end of
+// the UTC month containing now.
+func (t *Table) Pending(now time.Time) Indicator {
+	end := MonthEnd(now)
+	for i := 1; i < len(t.Entries); i++ {
+		if t.Entries[i].At.Equal(end) {
+			switch d := t.Entries[i].Offset - t.Entries[i-1].Offset; {
+			case d > 0:
+				return Insert
+			case d < 0:
+				return Delete
+			}
+		}
+	}
+	return None
+}
+
+// MonthEnd returns the end of the UTC month containing now, i.e., the time at
+// which an announced leap second takes effect.
+func MonthEnd(now time.Time) time.Time {
+	y, m, _ := now.UTC().Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// LastDay reports whether now is within the last UTC day of its month, i.e.,
+// whether the kernel is to insert or delete an announced leap second at the
+// end of the current day.
+func LastDay(now time.Time) bool {
+	return !now.Before(MonthEnd(now).Add(-24 * time.Hour))
+}
@@ -0,0 +1,69 @@
+package leap_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/scion-time/base/leap"
+)
+
+const leapSecondsList = `# leap-seconds.list excerpt
+#$	3960835200
+#@	3991593600
+3550089600	35	# 1 Jul 2012
+3644697600	36	# 1 Jul 2015
+3692217600	37	# 1 Jan 2017
+#h	7933299a afa2e659 affedc2e 32de15ad 2483c5cd
+`
+
+func TestParse(t *testing.T) {
+	tbl, err := leap.Parse(strings.NewReader(leapSecondsList))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(tbl.Entries) != 3 {
+		t.Fatalf("len(tbl.Entries) == %d; want 3", len(tbl.Entries))
+	}
+	at := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	if !tbl.Entries[2].At.Equal(at) || tbl.Entries[2].Offset != 37 {
+		t.Errorf("tbl.Entries[2] == %+v; want {%v 37}", tbl.Entries[2], at)
+	}
+	if !tbl.Expires.Equal(time.Date(2026, 6, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("tbl.Expires == %v", tbl.Expires)
+	}
+
+	_, err = leap.Parse(strings.NewReader(strings.Replace(leapSecondsList, "37", "38", 1)))
+	if err == nil {
+		t.Errorf("Parse succeeded despite hash mismatch")
+	}
+}
+
+func TestPending(t *testing.T) {
+	tbl, err := leap.Parse(strings.NewReader(`
+2272060800	10
+3692217600	37
+3723753600	36
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	for _, tc := range []struct {
+		now  time.Time
+		want leap.Indicator
+	}{
+		{time.Date(2016, 11, 30, 12, 0, 0, 0, time.UTC), leap.None},
+		{time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC), leap.Insert},
+		{time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC), leap.Insert},
+		{time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), leap.None},
+		{time.Date(2017, 12, 15, 0, 0, 0, 0, time.UTC), leap.Delete},
+	} {
+		if x := tbl.Pending(tc.now); x != tc.want {
+			t.Errorf("tbl.Pending(%v) == %v; want %v", tc.now, x, tc.want)
+		}
+	}
+	if leap.LastDay(time.Date(2016, 12, 30, 23, 59, 59, 0, time.UTC)) ||
+		!leap.LastDay(time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected LastDay result")
+	}
+}
@@ -1,6 +1,11 @@
 package metrics
 
 const (
+	ClockForeignStepsH = "The total number of steps of the system clock made by other processes"
+	ClockForeignStepsN = "timeservice_clock_foreign_steps"
+	ClockTimeDaemonsH  = "The number of other time synchronization daemons running on the host"
+	ClockTimeDaemonsN  = "timeservice_clock_time_daemons"
+
 	DRKeyCacheKeysInsertedH = "The total number of DRKeys inserted into cache"
 	DRKeyCacheKeysInsertedN = "timeservice_drkey_cache_keys_inserted"
 	DRKeyCacheKeysExpiredH  = "The total number of DRKeys expired in the cache"
@@ -65,8 +70,34 @@ const (
 	ServerTxtIncrementsBeforeH   = "The total number of TX timestamps incremented before transfer to ensure monotonicity"
 	ServerTxtIncrementsBeforeN   = "timeservice_server_txt_increments_before"
 
-	SyncGlobalCorrH = "The current clock correction applied based on global sync"
-	SyncGlobalCorrN = "timeservice_sync_global_corr"
-	SyncLocalCorrH  = "The current clock correction applied based on local sync"
-	SyncLocalCorrN  = "timeservice_sync_local_corr"
+	SyncCorrsRefusedH       = "The total number of clock corrections refused because they exceeded the panic threshold"
+	SyncCorrsRefusedN       = "timeservice_sync_corrs_refused"
+	SyncGlobalCorrH         = "The current clock correction applied based on global sync"
+	SyncGlobalCorrN         = "timeservice_sync_global_corr"
+	SyncGlobalHoldoverH     = "Whether global sync is in holdover (1) or not (0)"
+	SyncGlobalHoldoverN     = "timeservice_sync_global_holdover"
+	SyncGlobalHoldoverFreqH = "The frequency offset held by global sync during the last holdover"
+	SyncGlobalHoldoverFreqN = "timeservice_sync_global_holdover_freq"
+	SyncGlobalHoldoversH    = "The total number of times global sync entered holdover"
+	SyncGlobalHoldoversN    = "timeservice_sync_global_holdovers"
+	SyncLocalCorrH          = "The current clock correction applied based on local sync"
+	SyncLocalCorrN          = "timeservice_sync_local_corr"
+	SyncLocalHoldoverH      = "Whether local sync is in holdover (1) or not (0)"
+	SyncLocalHoldoverN      = "timeservice_sync_local_holdover"
+	SyncLocalHoldoverFreqH  = "The frequency offset held by local sync during the last holdover"
+	SyncLocalHoldoverFreqN  = "timeservice_sync_local_holdover_freq"
+	SyncLocalHoldoversH     = "The total number of times local sync entered holdover"
+	SyncLocalHoldoversN     = "timeservice_sync_local_holdovers"
+	SyncSourceQuarantinedH  = "Whether a source is quarantined (1) or not (0)"
+	SyncSourceQuarantinedN  = "timeservice_sync_source_quarantined"
+	SyncSourceQuarantinesH  = "The total number of times a source was quarantined"
+	SyncSourceQuarantinesN  = "timeservice_sync_source_quarantines"
+	SyncSourceScoreH        = "The current misbehavior score of a source"
+	SyncSourceScoreN        = "timeservice_sync_source_score"
+	SyncSourceSelectionsH   = "The total number of times a source was classified as truechimer, falseticker or unreachable"
+	SyncSourceSelectionsN   = "timeservice_sync_source_selections"
+	SyncStepsH              = "The total number of clock steps"
+	SyncStepsN              = "timeservice_sync_steps"
+	SyncStepsDeniedH        = "The total number of clock steps denied by the step policy and slewed instead"
+	SyncStepsDeniedN        = "timeservice_sync_steps_denied"
 )
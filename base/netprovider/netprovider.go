@@ -23,6 +23,7 @@ var _ Connection = (*net.UDPConn)(nil)
 
 type ConnProvider interface {
 	ListenUDP(network string, laddr *net.UDPAddr) (Connection, error)
+	ListenUDPReusePort(network string, laddr *net.UDPAddr) (Connection, error)
 	EnableTimestamping(n Connection, localHostIface string) error
 	SetDSCP(n Connection, dscp uint8) error
 	ReadTXTimestamp(n Connection) (time.Time, uint32, error)
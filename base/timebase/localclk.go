@@ -12,3 +12,46 @@ type LocalClock interface {
 	Adjust(offset, duration time.Duration, frequency float64)
 	Sleep(duration time.Duration)
 }
+
+// SystemTimeClock is implemented by local clocks whose time scale differs from
+// that of the system clock, CLOCK_REALTIME, which the kernel uses for packet
+// timestamps. FromSystemTime converts the system clock timestamp t into the
+// time scale of the local clock.
+type SystemTimeClock interface {
+	FromSystemTime(t time.Time) time.Time
+}
+
+// SyncQuality describes how well a local clock is synchronized. MaxError is
+// the maximum and EstError the estimated error of the clock's time. The
+// quality of an unsynchronized clock is the zero value.
+type SyncQuality struct {
+	Synchronized bool
+	MaxError     time.Duration
+	EstError     time.Duration
+}
+
+// SyncQualityClock is implemented by local clocks that report their
+// synchronization quality, e.g., to other applications on the same host.
+type SyncQualityClock interface {
+	SetSyncQuality(q SyncQuality)
+	SyncQuality() SyncQuality
+}
+
+// TimerClock is implemented by local clocks whose time does not advance in
+// real time, e.g., simulated clocks. AfterFunc calls f after duration d
+// measured by the clock. Calling stop prevents f from being called; stop
+// reports whether it did so.
+type TimerClock interface {
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// TrackingClock is implemented by local clocks whose time only advances when
+// all goroutines that use them are blocked, e.g., simulated clocks. Go calls f
+// in a new tracked goroutine. A tracked goroutine calls Block before it waits
+// for an event from another goroutine, which calls Unblock for the n
+// goroutines it is about to wake up before triggering the event.
+type TrackingClock interface {
+	Go(f func())
+	Block()
+	Unblock(n int)
+}
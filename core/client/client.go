@@ -11,19 +11,48 @@ import (
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
 	"example.com/scion-time/base/timemath"
 	"example.com/scion-time/core/cryptobase"
+	"example.com/scion-time/core/timebase"
+	"example.com/scion-time/net/ntp"
 	"example.com/scion-time/net/scion"
 	"example.com/scion-time/net/udp"
 )
 
+// Measurement is the result of a clock offset measurement taken at local time
+// Time from the reference clock identified by Source. Delay is the round trip
+// delay of the measurement, or zero if there is no network path to the
+// reference clock. ErrorBound is the maximum error of Offset, i.e., the true
+// offset is within Offset ± ErrorBound. Weight is assigned to the measurement
+// by the offset filter. Stratum, RootDelay, RootDispersion and the announced
+// Leap second are reported by the reference clock, with stratum 0 denoting a
+// primary reference clock, and RefID is the reference ID of a clock
+// synchronized to it. Authenticated reports whether the response was
+// authenticated via NTS or SPAO.
+type Measurement struct {
+	Time           time.Time
+	Source         string
+	Offset         time.Duration
+	Delay          time.Duration
+	ErrorBound     time.Duration
+	Weight         float64
+	Stratum        uint8
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	RefID          uint32
+	Leap           leap.Indicator
+	Authenticated  bool
+}
+
 type measurement struct {
-	off time.Duration
-	err error
+	m      Measurement
+	err    error
+	source string
 }
 
 type ReferenceClock interface {
-	MeasureClockOffset(ctx context.Context, log *zap.Logger) (time.Duration, error)
+	MeasureClockOffset(ctx context.Context, log *zap.Logger) (Measurement, error)
 }
 
 type ReferenceClockClient struct {
@@ -32,6 +61,7 @@ type ReferenceClockClient struct {
 
 var (
 	errNoPaths            = errors.New("failed to measure clock offset: no paths")
+	errNoMeasurements     = errors.New("failed to measure clock offset: no measurements")
 	errUnexpectedAddrType = errors.New("unexpected address type")
 
 	ipMetrics    atomic.Pointer[ipClientMetrics]
@@ -45,7 +75,7 @@ func init() {
 
 func MeasureClockOffsetIP(ctx context.Context, log *zap.Logger,
 	ntpc *IPClient, localAddr, remoteAddr *net.UDPAddr) (
-	at time.Time, off time.Duration, err error) {
+	m Measurement, err error) {
 	mtrcs := ipMetrics.Load()
 
 	var nerr, n int
@@ -55,15 +85,15 @@ func MeasureClockOffsetIP(ctx context.Context, log *zap.Logger,
 		n = 1
 	}
 	for i := 0; i != n; i++ {
-		a, o, _, e := ntpc.measureClockOffsetIP(ctx, log, mtrcs, localAddr, remoteAddr)
+		o, e := ntpc.measureClockOffsetIP(ctx, log, mtrcs, localAddr, remoteAddr)
 		if e == nil {
-			at, off, err = a, o, e
+			m, err = o, e
 			if ntpc.InInterleavedMode() {
 				break
 			}
 		} else {
 			if nerr == i {
-				at, off, err = a, o, e
+				m, err = o, e
 			}
 			nerr++
 			log.Info("failed to measure clock offset",
@@ -73,37 +103,38 @@ func MeasureClockOffsetIP(ctx context.Context, log *zap.Logger,
 	return
 }
 
-func collectMeasurements(ctx context.Context, off []time.Duration, ms chan measurement) int {
+func collectMeasurements(ctx context.Context, res []Measurement, ms chan measurement) int {
 	i := 0
 	j := 0
-	n := len(off)
-loop:
+	n := len(res)
 	for i != n {
-		select {
-		case m := <-ms:
-			if m.err == nil {
-				if j != len(off) {
-					off[j] = m.off
-					j++
+		m, ok := timebase.Receive(ctx, ms)
+		if !ok {
+			break
+		}
+		if m.err == nil {
+			if j != len(res) {
+				res[j] = m.m
+				if m.source != "" {
+					res[j].Source = m.source
 				}
+				j++
 			}
-			i++
-		case <-ctx.Done():
-			break loop
 		}
+		i++
 	}
-	go func(n int) { // drain channel
-		for n != 0 {
-			<-ms
-			n--
+	timebase.Go(ctx, func() { // drain channel
+		ctx := context.WithoutCancel(ctx)
+		for k := n - i; k != 0; k-- {
+			timebase.Receive(ctx, ms)
 		}
-	}(n - i)
+	})
 	return j
 }
 
 func MeasureClockOffsetSCION(ctx context.Context, log *zap.Logger,
 	ntpcs []*SCIONClient, localAddr, remoteAddr udp.UDPAddr, ps []snet.Path) (
-	time.Duration, error) {
+	Measurement, error) {
 	mtrcs := scionMetrics.Load()
 
 	sps := make([]snet.Path, len(ntpcs))
@@ -111,20 +142,20 @@ func MeasureClockOffsetSCION(ctx context.Context, log *zap.Logger,
 		sps[dst] = ps[src]
 	})
 	if err != nil {
-		return 0, err
+		return Measurement{}, err
 	}
 	if n == 0 {
-		return 0, errNoPaths
+		return Measurement{}, errNoPaths
 	}
 	sps = sps[:n]
 
-	off := make([]time.Duration, len(sps))
+	res := make([]Measurement, len(sps))
 	ms := make(chan measurement)
 	for i := 0; i != len(sps); i++ {
-		go func(ctx context.Context, log *zap.Logger, mtrcs *scionClientMetrics,
-			ntpc *SCIONClient, localAddr, remoteAddr udp.UDPAddr, p snet.Path) {
+		ntpc, p := ntpcs[i], sps[i]
+		timebase.Go(ctx, func() {
 			var err error
-			var off time.Duration
+			var m Measurement
 			var nerr, n int
 			log.Debug("measuring clock offset",
 				zap.Stringer("to", remoteAddr.IA),
@@ -137,15 +168,15 @@ func MeasureClockOffsetSCION(ctx context.Context, log *zap.Logger,
 				n = 1
 			}
 			for j := 0; j != n; j++ {
-				_, o, _, e := ntpc.measureClockOffsetSCION(ctx, log, mtrcs, localAddr, remoteAddr, p)
+				o, e := ntpc.measureClockOffsetSCION(ctx, log, mtrcs, localAddr, remoteAddr, p)
 				if e == nil {
-					off, err = o, e
+					m, err = o, e
 					if ntpc.InInterleavedMode() {
 						break
 					}
 				} else {
 					if nerr == j {
-						off, err = o, e
+						m, err = o, e
 					}
 					nerr++
 					log.Info("failed to measure clock offset",
@@ -155,17 +186,71 @@ func MeasureClockOffsetSCION(ctx context.Context, log *zap.Logger,
 					)
 				}
 			}
-			ms <- measurement{off, err}
-		}(ctx, log, mtrcs, ntpcs[i], localAddr, remoteAddr, sps[i])
+			timebase.Send(ctx, ms, measurement{m: m, err: err})
+		})
 	}
-	collectMeasurements(ctx, off, ms)
-	return timemath.Median(off), nil
+	n = collectMeasurements(ctx, res, ms)
+	if n == 0 {
+		return Measurement{}, errNoMeasurements
+	}
+	return combineMeasurements(res[:n]), nil
 }
 
+// combineMeasurements combines the measurements via multiple paths to the
+// same reference clock into a single measurement with the median offset,
+// delay and error bound, the mean weight and the latest measurement time. The
+// combined measurement is authenticated only if all measurements are.
+func combineMeasurements(ms []Measurement) Measurement {
+	off := make([]time.Duration, len(ms))
+	rtd := make([]time.Duration, len(ms))
+	eb := make([]time.Duration, len(ms))
+	var t time.Time
+	var w float64
+	auth := true
+	for i := 0; i != len(ms); i++ {
+		off[i] = ms[i].Offset
+		rtd[i] = ms[i].Delay
+		eb[i] = ms[i].ErrorBound
+		w += ms[i].Weight
+		if ms[i].Time.After(t) {
+			t = ms[i].Time
+		}
+		auth = auth && ms[i].Authenticated
+	}
+	return Measurement{
+		Time:           t,
+		Source:         ms[0].Source,
+		Offset:         timemath.Median(off),
+		Delay:          timemath.Median(rtd),
+		ErrorBound:     timemath.Median(eb),
+		Weight:         w / float64(len(ms)),
+		Stratum:        ms[0].Stratum,
+		RootDelay:      ms[0].RootDelay,
+		RootDispersion: ms[0].RootDispersion,
+		RefID:          ms[0].RefID,
+		Leap:           ms[0].Leap,
+		Authenticated:  auth,
+	}
+}
+
+// errorBound returns the maximum error of a clock offset measured with round
+// trip delay rtd from a server which responded with ntpresp, see RFC 5905,
+// Section 11.2, root distance.
+func errorBound(rtd time.Duration, ntpresp *ntp.Packet) time.Duration {
+	return timemath.Abs(rtd)/2 +
+		ntp.DurationFromTime32(ntpresp.RootDelay)/2 +
+		ntp.DurationFromTime32(ntpresp.RootDispersion)
+}
+
+// MeasureClockOffsets measures the clock offsets to refclks concurrently and
+// stores the n successful measurements in ms[:n]. The Source of the
+// measurement of refclks[i] is sources[i] if i < len(sources), independent of
+// the address a reference clock actually measured, which may have been
+// changed, e.g., by NTS-KE.
 func (c *ReferenceClockClient) MeasureClockOffsets(ctx context.Context, log *zap.Logger,
-	refclks []ReferenceClock, off []time.Duration) {
-	if len(off) != len(refclks) {
-		panic("number of result offsets must be equal to the number of reference clocks")
+	refclks []ReferenceClock, sources []string, ms []Measurement) int {
+	if len(ms) != len(refclks) {
+		panic("number of result measurements must be equal to the number of reference clocks")
 	}
 	swapped := atomic.CompareAndSwapUint32(&c.numOpsInProgress, 0, 1)
 	if !swapped {
@@ -178,12 +263,17 @@ func (c *ReferenceClockClient) MeasureClockOffsets(ctx context.Context, log *zap
 		}
 	}(&c.numOpsInProgress)
 
-	ms := make(chan measurement)
-	for _, refclk := range refclks {
-		go func(ctx context.Context, log *zap.Logger, refclk ReferenceClock) {
-			off, err := refclk.MeasureClockOffset(ctx, log)
-			ms <- measurement{off, err}
-		}(ctx, log, refclk)
+	mch := make(chan measurement)
+	for i, refclk := range refclks {
+		var source string
+		if i < len(sources) {
+			source = sources[i]
+		}
+		refclk := refclk
+		timebase.Go(ctx, func() {
+			m, err := refclk.MeasureClockOffset(ctx, log)
+			timebase.Send(ctx, mch, measurement{m: m, err: err, source: source})
+		})
 	}
-	collectMeasurements(ctx, off, ms)
+	return collectMeasurements(ctx, ms, mch)
 }
@@ -13,6 +13,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
 	"example.com/scion-time/base/metrics"
 
 	"example.com/scion-time/core/timebase"
@@ -34,6 +35,7 @@ type IPClient struct {
 	Histo *hdrhistogram.Histogram
 	prev  struct {
 		reference   string
+		epoch       uint64
 		interleaved bool
 		cTxTime     ntp.Time64
 		cRxTime     ntp.Time64
@@ -93,24 +95,24 @@ func (c *IPClient) ResetInterleavedMode() {
 
 func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mtrcs *ipClientMetrics,
 	localAddr, remoteAddr *net.UDPAddr) (
-	at time.Time, offset time.Duration, weight float64, err error) {
-	conn, err := netbase.ListenUDP("udp", &net.UDPAddr{IP: localAddr.IP})
+	m Measurement, err error) {
+	conn, err := netbase.ListenUDP(ctx, "udp", &net.UDPAddr{IP: localAddr.IP})
 	if err != nil {
-		return at, offset, weight, err
+		return m, err
 	}
 	defer conn.Close()
 	deadline, deadlineIsSet := ctx.Deadline()
 	if deadlineIsSet {
 		err = conn.SetDeadline(deadline)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 	}
-	err = netbase.EnableTimestamping(conn, localAddr.Zone)
+	err = netbase.EnableTimestamping(ctx, conn, localAddr.Zone)
 	if err != nil {
 		log.Error("failed to enable timestamping", zap.Error(err))
 	}
-	err = netbase.SetDSCP(conn, c.DSCP)
+	err = netbase.SetDSCP(ctx, conn, c.DSCP)
 	if err != nil {
 		log.Info("failed to set DSCP", zap.Error(err))
 	}
@@ -120,7 +122,7 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 		ntskeData, err = c.Auth.NTSKEFetcher.FetchData()
 		if err != nil {
 			log.Info("failed to fetch key exchange data", zap.Error(err))
-			return at, offset, weight, err
+			return m, err
 		}
 		remoteAddr.IP = net.ParseIP(ntskeData.Server)
 		remoteAddr.Port = int(ntskeData.Port)
@@ -133,13 +135,13 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 	buf := make([]byte, ntp.PacketLen)
 
 	reference := remoteAddr.String()
-	cTxTime0 := timebase.Now()
+	cTxTime0 := timebase.Now(ctx)
 	interleavedReq := false
 
 	ntpreq := ntp.Packet{}
 	ntpreq.SetVersion(ntp.VersionMax)
 	ntpreq.SetMode(ntp.ModeClient)
-	if c.InterleavedMode && reference == c.prev.reference &&
+	if c.InterleavedMode && reference == c.prev.reference && c.prev.epoch == timebase.Epoch(ctx) &&
 		cTxTime0.Sub(ntp.TimeFromTime64(c.prev.cTxTime)) <= 2 * time.Second {
 		interleavedReq = true
 		ntpreq.OriginTime = c.prev.sRxTime
@@ -159,15 +161,17 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 
 	n, err := conn.WriteToUDPAddrPort(buf, remoteAddr.AddrPort())
 	if err != nil {
-		return at, offset, weight, err
+		return m, err
 	}
 	if n != len(buf) {
-		return at, offset, weight, errWrite
+		return m, errWrite
 	}
-	cTxTime1, id, err := netbase.ReadTXTimestamp(conn)
+	cTxTime1, id, err := netbase.ReadTXTimestamp(ctx, conn)
 	if err != nil || id != 0 {
-		cTxTime1 = timebase.Now()
+		cTxTime1 = timebase.Now(ctx)
 		log.Error("failed to read packet tx timestamp", zap.Error(err))
+	} else {
+		cTxTime1 = timebase.FromSystemTime(ctx, cTxTime1)
 	}
 	mtrcs.reqsSent.Inc()
 	if interleavedReq {
@@ -181,50 +185,52 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 		oob = oob[:cap(oob)]
 		n, oobn, flags, srcAddr, err := conn.ReadMsgUDPAddrPort(buf, oob)
 		if err != nil {
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to read packet", zap.Error(err))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		if flags != 0 {
 			err = errUnexpectedPacketFlags
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to read packet", zap.Int("flags", flags))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		oob = oob[:oobn]
 		cRxTime, err := udp.TimestampFromOOBData(oob)
 		if err != nil {
-			cRxTime = timebase.Now()
+			cRxTime = timebase.Now(ctx)
 			log.Error("failed to read packet rx timestamp", zap.Error(err))
+		} else {
+			cRxTime = timebase.FromSystemTime(ctx, cRxTime)
 		}
 		buf = buf[:n]
 		mtrcs.pktsReceived.Inc()
 
 		if compareAddrs(srcAddr.Addr(), remoteAddr.AddrPort().Addr()) != 0 {
 			err = errUnexpectedPacketSource
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("received packet from unexpected source")
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		var ntpresp ntp.Packet
 		err = ntp.DecodePacket(&ntpresp, buf)
 		if err != nil {
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to decode packet payload", zap.Error(err))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		authenticated := false
@@ -232,22 +238,22 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 		if c.Auth.Enabled {
 			err = nts.DecodePacket(&ntsresp, buf)
 			if err != nil {
-				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 					log.Info("failed to decode NTS packet", zap.Error(err))
 					numRetries++
 					continue
 				}
-				return at, offset, weight, err
+				return m, err
 			}
 
 			err = nts.ProcessResponse(buf, ntskeData.S2cKey, &c.Auth.NTSKEFetcher, &ntsresp, requestID)
 			if err != nil {
-				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 					log.Info("failed to process NTS packet", zap.Error(err))
 					numRetries++
 					continue
 				}
-				return at, offset, weight, err
+				return m, err
 			}
 
 			authenticated = true
@@ -259,17 +265,17 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 			interleavedResp = true
 		} else if ntpresp.OriginTime != ntpreq.TransmitTime {
 			err = errUnexpectedPacket
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("received packet with unexpected type or structure")
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		err = ntp.ValidateResponseMetadata(&ntpresp)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 
 		log.Debug("received response",
@@ -297,7 +303,7 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 
 		err = ntp.ValidateResponseTimestamps(t0, t1, t1, t3)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 
 		off := ntp.ClockOffset(t0, t1, t2, t3)
@@ -317,17 +323,27 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 
 		if c.InterleavedMode {
 			c.prev.reference = reference
+			c.prev.epoch = timebase.Epoch(ctx)
 			c.prev.interleaved = interleavedResp
 			c.prev.cTxTime = ntp.Time64FromTime(cTxTime1)
 			c.prev.cRxTime = ntp.Time64FromTime(cRxTime)
 			c.prev.sRxTime = ntpresp.ReceiveTime
 		}
 
-		at = cRxTime
+		m.Time = cRxTime
+		m.Source = reference
+		m.Delay = rtd
+		m.ErrorBound = errorBound(rtd, &ntpresp)
+		m.Stratum = ntpresp.Stratum
+		m.RootDelay = ntp.DurationFromTime32(ntpresp.RootDelay)
+		m.RootDispersion = ntp.DurationFromTime32(ntpresp.RootDispersion)
+		m.Leap = leap.Indicator(ntpresp.LeapIndicator())
+		m.RefID = ntp.ReferenceIDFromIP(remoteAddr.IP)
+		m.Authenticated = authenticated
 		if c.Raw {
-			offset, weight = off, 1000.0
+			m.Offset, m.Weight = off, 1000.0
 		} else {
-			offset, weight = filter(log, reference, t0, t1, t2, t3)
+			m.Offset, m.Weight = filter(ctx, log, reference, t0, t1, t2, t3)
 		}
 
 		if c.Histo != nil {
@@ -337,5 +353,5 @@ func (c *IPClient) measureClockOffsetIP(ctx context.Context, log *zap.Logger, mt
 		break
 	}
 
-	return at, offset, weight, nil
+	return m, nil
 }
@@ -21,6 +21,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
 	"example.com/scion-time/base/metrics"
 
 	"example.com/scion-time/core/timebase"
@@ -48,6 +49,7 @@ type SCIONClient struct {
 	Histo *hdrhistogram.Histogram
 	prev  struct {
 		reference   string
+		epoch       uint64
 		interleaved bool
 		cTxTime     ntp.Time64
 		cRxTime     ntp.Time64
@@ -112,7 +114,7 @@ func (c *SCIONClient) ResetInterleavedMode() {
 
 func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logger, mtrcs *scionClientMetrics,
 	localAddr, remoteAddr udp.UDPAddr, path snet.Path) (
-	at time.Time, offset time.Duration, weight float64, err error) {
+	m Measurement, err error) {
 	if c.Auth.Enabled && c.Auth.opt == nil {
 		c.Auth.opt = &slayers.EndToEndOption{}
 		c.Auth.opt.OptData = make([]byte, scion.PacketAuthOptDataLen)
@@ -121,23 +123,23 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 	}
 	var authKey []byte
 
-	conn, err := netbase.ListenUDP("udp", &net.UDPAddr{IP: localAddr.Host.IP})
+	conn, err := netbase.ListenUDP(ctx, "udp", &net.UDPAddr{IP: localAddr.Host.IP})
 	if err != nil {
-		return at, offset, weight, err
+		return m, err
 	}
 	defer conn.Close()
 	deadline, deadlineIsSet := ctx.Deadline()
 	if deadlineIsSet {
 		err = conn.SetDeadline(deadline)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 	}
-	err = netbase.EnableTimestamping(conn, localAddr.Host.Zone)
+	err = netbase.EnableTimestamping(ctx, conn, localAddr.Host.Zone)
 	if err != nil {
 		log.Error("failed to enable timestamping", zap.Error(err))
 	}
-	err = netbase.SetDSCP(conn, c.DSCP)
+	err = netbase.SetDSCP(ctx, conn, c.DSCP)
 	if err != nil {
 		log.Info("failed to set DSCP", zap.Error(err))
 	}
@@ -149,7 +151,7 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		ntskeData, err = c.Auth.NTSKEFetcher.FetchData()
 		if err != nil {
 			log.Info("failed to fetch key exchange data", zap.Error(err))
-			return at, offset, weight, err
+			return m, err
 		}
 		remoteAddr.Host.IP = net.ParseIP(ntskeData.Server)
 		remoteAddr.Host.Port = int(ntskeData.Port)
@@ -175,13 +177,13 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 	buf := make([]byte, scion.MTU)
 
 	reference := remoteAddr.IA.String() + "," + remoteAddr.Host.String()
-	cTxTime0 := timebase.Now()
+	cTxTime0 := timebase.Now(ctx)
 	interleavedReq := false
 
 	ntpreq := ntp.Packet{}
 	ntpreq.SetVersion(ntp.VersionMax)
 	ntpreq.SetMode(ntp.ModeClient)
-	if c.InterleavedMode && reference == c.prev.reference &&
+	if c.InterleavedMode && reference == c.prev.reference && c.prev.epoch == timebase.Epoch(ctx) &&
 		cTxTime0.Sub(ntp.TimeFromTime64(c.prev.cTxTime)) <= 2 * time.Second {
 		interleavedReq = true
 		ntpreq.OriginTime = c.prev.sRxTime
@@ -302,15 +304,17 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 
 	n, err := conn.WriteToUDPAddrPort(buffer.Bytes(), nextHop)
 	if err != nil {
-		return at, offset, weight, err
+		return m, err
 	}
 	if n != len(buffer.Bytes()) {
-		return at, offset, weight, errWrite
+		return m, errWrite
 	}
-	cTxTime1, id, err := netbase.ReadTXTimestamp(conn)
+	cTxTime1, id, err := netbase.ReadTXTimestamp(ctx, conn)
 	if err != nil || id != 0 {
-		cTxTime1 = timebase.Now()
+		cTxTime1 = timebase.Now(ctx)
 		log.Error("failed to read packet tx timestamp", zap.Error(err))
+	} else {
+		cTxTime1 = timebase.FromSystemTime(ctx, cTxTime1)
 	}
 	mtrcs.reqsSent.Inc()
 	if interleavedReq {
@@ -324,27 +328,29 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		oob = oob[:cap(oob)]
 		n, oobn, flags, lastHop, err := conn.ReadMsgUDPAddrPort(buf, oob)
 		if err != nil {
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to read packet", zap.Error(err))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		if flags != 0 {
 			err = errUnexpectedPacketFlags
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to read packet", zap.Int("flags", flags))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		oob = oob[:oobn]
 		cRxTime, err := udp.TimestampFromOOBData(oob)
 		if err != nil {
-			cRxTime = timebase.Now()
+			cRxTime = timebase.Now(ctx)
 			log.Error("failed to read packet rx timestamp", zap.Error(err))
+		} else {
+			cRxTime = timebase.FromSystemTime(ctx, cRxTime)
 		}
 		buf = buf[:n]
 		mtrcs.pktsReceived.Inc()
@@ -361,23 +367,23 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		decoded := make([]gopacket.LayerType, 4)
 		err = parser.DecodeLayers(buf, &decoded)
 		if err != nil {
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to decode packet", zap.Error(err))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		validType := len(decoded) >= 2 &&
 			decoded[len(decoded)-1] == slayers.LayerTypeSCIONUDP
 		if !validType {
 			err = errUnexpectedPacket
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to decode packet", zap.String("cause", "unexpected type or structure"))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 		validSrc := scionLayer.SrcIA.Equal(remoteAddr.IA) &&
 			compareIPs(scionLayer.RawSrcAddr, remoteAddr.Host.IP) == 0
@@ -385,7 +391,7 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 			compareIPs(scionLayer.RawDstAddr, localAddr.Host.IP) == 0
 		if !validSrc || !validDst {
 			err = errUnexpectedPacket
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				if !validSrc {
 					log.Info("received packet from unexpected source")
 				}
@@ -395,7 +401,7 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		authenticated := false
@@ -405,7 +411,7 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 			if err == nil {
 				cRxTime0, err := udp.TimestampFromOOBData(tsOpt.OptData)
 				if err == nil {
-					cRxTime = cRxTime0
+					cRxTime = timebase.FromSystemTime(ctx, cRxTime0)
 				}
 			}
 			if authKey != nil {
@@ -430,12 +436,12 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 						authenticated = subtle.ConstantTimeCompare(scion.PacketAuthOptMAC(authOpt), c.Auth.mac) != 0
 						if !authenticated {
 							err = errInvalidPacketAuthenticator
-							if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+							if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 								log.Info("failed to authenticate packet", zap.Error(err))
 								numRetries++
 								continue
 							}
-							return at, offset, weight, err
+							return m, err
 						}
 						mtrcs.pktsAuthenticated.Inc()
 					}
@@ -446,12 +452,12 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		var ntpresp ntp.Packet
 		err = ntp.DecodePacket(&ntpresp, udpLayer.Payload)
 		if err != nil {
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("failed to decode packet payload", zap.Error(err))
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		ntsAuthenticated := false
@@ -459,22 +465,22 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		if c.Auth.NTSEnabled {
 			err = nts.DecodePacket(&ntsresp, udpLayer.Payload)
 			if err != nil {
-				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 					log.Info("failed to decode NTS packet", zap.Error(err))
 					numRetries++
 					continue
 				}
-				return at, offset, weight, err
+				return m, err
 			}
 
 			err = nts.ProcessResponse(udpLayer.Payload, ntskeData.S2cKey, &c.Auth.NTSKEFetcher, &ntsresp, requestID)
 			if err != nil {
-				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+				if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 					log.Info("failed to process NTS packet", zap.Error(err))
 					numRetries++
 					continue
 				}
-				return at, offset, weight, err
+				return m, err
 			}
 			ntsAuthenticated = true
 		}
@@ -484,17 +490,17 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 			interleavedResp = true
 		} else if ntpresp.OriginTime != ntpreq.TransmitTime {
 			err = errUnexpectedPacket
-			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now().Before(deadline) {
+			if numRetries != maxNumRetries && deadlineIsSet && timebase.Now(ctx).Before(deadline) {
 				log.Info("received packet with unexpected type or structure")
 				numRetries++
 				continue
 			}
-			return at, offset, weight, err
+			return m, err
 		}
 
 		err = ntp.ValidateResponseMetadata(&ntpresp)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 
 		dscp := scionLayer.TrafficClass >> 2
@@ -527,7 +533,7 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 
 		err = ntp.ValidateResponseTimestamps(t0, t1, t1, t3)
 		if err != nil {
-			return at, offset, weight, err
+			return m, err
 		}
 
 		off := ntp.ClockOffset(t0, t1, t2, t3)
@@ -547,17 +553,27 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 
 		if c.InterleavedMode {
 			c.prev.reference = reference
+			c.prev.epoch = timebase.Epoch(ctx)
 			c.prev.interleaved = interleavedResp
 			c.prev.cTxTime = ntp.Time64FromTime(cTxTime1)
 			c.prev.cRxTime = ntp.Time64FromTime(cRxTime)
 			c.prev.sRxTime = ntpresp.ReceiveTime
 		}
 
-		at = cRxTime
+		m.Time = cRxTime
+		m.Source = reference
+		m.Delay = rtd
+		m.ErrorBound = errorBound(rtd, &ntpresp)
+		m.Stratum = ntpresp.Stratum
+		m.RootDelay = ntp.DurationFromTime32(ntpresp.RootDelay)
+		m.RootDispersion = ntp.DurationFromTime32(ntpresp.RootDispersion)
+		m.Leap = leap.Indicator(ntpresp.LeapIndicator())
+		m.RefID = ntp.ReferenceIDFromIP(remoteAddr.Host.IP)
+		m.Authenticated = authenticated || ntsAuthenticated
 		if c.Raw {
-			offset, weight = off, 1000.0
+			m.Offset, m.Weight = off, 1000.0
 		} else {
-			offset, weight = filter(log, reference, t0, t1, t2, t3)
+			m.Offset, m.Weight = filter(ctx, log, reference, t0, t1, t2, t3)
 		}
 
 		if c.Histo != nil {
@@ -567,5 +583,5 @@ func (c *SCIONClient) measureClockOffsetSCION(ctx context.Context, log *zap.Logg
 		break
 	}
 
-	return at, offset, weight, nil
+	return m, nil
 }
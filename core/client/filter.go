@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"math"
 	"sync"
 	"time"
@@ -22,11 +23,49 @@ type filterContext struct {
 	navg           float64
 }
 
+type filterStore struct {
+	mu      sync.Mutex
+	filters map[string]filterContext
+}
+
+type filterStoreKey struct{}
+
+// FilterObserver is notified of the result of every measurement passed
+// through the measurement filter.
+type FilterObserver interface {
+	ObserveFilteredMeasurement(reference string, branch int, offset time.Duration, weight float64)
+}
+
+type filterObserverKey struct{}
+
 var (
-	filters   = make(map[string]filterContext)
-	filtersMu = sync.Mutex{}
+	filters = newFilterStore()
 )
 
+func newFilterStore() *filterStore {
+	return &filterStore{filters: make(map[string]filterContext)}
+}
+
+// WithFilters returns a copy of ctx with a separate set of measurement filter
+// states, one per reference. By default, all measurements share one set.
+func WithFilters(ctx context.Context) context.Context {
+	return context.WithValue(ctx, filterStoreKey{}, newFilterStore())
+}
+
+// WithFilterObserver returns a copy of ctx in which o is notified of filtered
+// measurements.
+func WithFilterObserver(ctx context.Context, o FilterObserver) context.Context {
+	return context.WithValue(ctx, filterObserverKey{}, o)
+}
+
+func getFilters(ctx context.Context) *filterStore {
+	s, ok := ctx.Value(filterStoreKey{}).(*filterStore)
+	if ok {
+		return s
+	}
+	return filters
+}
+
 func combine(lo, mid, hi time.Duration, trust float64) (offset time.Duration, weight float64) {
 	offset = mid
 	weight = 0.001 + trust*2.0/timemath.Seconds(hi-lo)
@@ -36,20 +75,22 @@ func combine(lo, mid, hi time.Duration, trust float64) (offset time.Duration, we
 	return
 }
 
-func filter(log *zap.Logger, reference string, cTxTime, sRxTime, sTxTime, cRxTime time.Time) (
+func filter(ctx context.Context, log *zap.Logger, reference string, cTxTime, sRxTime, sTxTime, cRxTime time.Time) (
 	offset time.Duration, weight float64) {
 
 	// Based on Ntimed by Poul-Henning Kamp, https://github.com/bsdphk/Ntimed
 
-	filtersMu.Lock()
-	f := filters[reference]
+	fs := getFilters(ctx)
+	fs.mu.Lock()
+	f := fs.filters[reference]
 
 	lo := timemath.Seconds(cTxTime.Sub(sRxTime))
 	hi := timemath.Seconds(cRxTime.Sub(sTxTime))
 	mid := (lo + hi) / 2
 
-	if f.epoch != timebase.Epoch() {
-		f.epoch = timebase.Epoch()
+	epoch := timebase.Epoch(ctx)
+	if f.epoch != epoch {
+		f.epoch = epoch
 		f.alo = 0.0
 		f.amid = 0.0
 		f.ahi = 0.0
@@ -102,8 +143,8 @@ func filter(log *zap.Logger, reference string, cTxTime, sRxTime, sTxTime, cRxTim
 	f.alolo += (lo*lo - f.alolo) / r
 	f.ahihi += (hi*hi - f.ahihi) / r
 
-	filters[reference] = f
-	filtersMu.Unlock()
+	fs.filters[reference] = f
+	fs.mu.Unlock()
 
 	trust := 1.0
 
@@ -122,5 +163,10 @@ func filter(log *zap.Logger, reference string, cTxTime, sRxTime, sTxTime, cRxTim
 		zap.Float64("weight", weight),
 	)
 
-	return timemath.Inv(offset), weight
+	offset = timemath.Inv(offset)
+	if o, ok := ctx.Value(filterObserverKey{}).(FilterObserver); ok {
+		o.ObserveFilteredMeasurement(reference, branch, offset, weight)
+	}
+
+	return offset, weight
 }
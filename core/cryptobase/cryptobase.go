@@ -9,6 +9,8 @@ import (
 
 // TODO: structure copied from timebase
 
+type cryptoKey struct{}
+
 var lcrypt atomic.Value
 
 func RegisterCrypto(c cryptobase.CryptoProvider) {
@@ -20,17 +22,30 @@ func RegisterCrypto(c cryptobase.CryptoProvider) {
 	}
 }
 
+// WithCrypto returns a copy of ctx in which c replaces the registered crypto
+// provider.
+func WithCrypto(ctx context.Context, c cryptobase.CryptoProvider) context.Context {
+	if c == nil {
+		panic("crypto provider must not be nil")
+	}
+	return context.WithValue(ctx, cryptoKey{}, c)
+}
+
 func RandIntn(ctx context.Context, n int) (int, error) {
-	return getCrypt().RandIntn(ctx, n)
+	return getCrypt(ctx).RandIntn(ctx, n)
 }
 
 func Sample(ctx context.Context, k, n int, pick func(dst, src int)) (int, error) {
-	return getCrypt().Sample(ctx, k, n, pick)
+	return getCrypt(ctx).Sample(ctx, k, n, pick)
 }
 
-func getCrypt() cryptobase.CryptoProvider {
-	c := lcrypt.Load().(cryptobase.CryptoProvider)
-	if c == nil {
+func getCrypt(ctx context.Context) cryptobase.CryptoProvider {
+	c, ok := ctx.Value(cryptoKey{}).(cryptobase.CryptoProvider)
+	if ok {
+		return c
+	}
+	c, ok = lcrypt.Load().(cryptobase.CryptoProvider)
+	if !ok {
 		panic("no crypto provider registered")
 	}
 	return c
@@ -1,6 +1,7 @@
 package netbase
 
 import (
+	"context"
 	"example.com/scion-time/base/netprovider"
 	"net"
 	"sync/atomic"
@@ -9,6 +10,8 @@ import (
 
 // TODO: structure copied from timebase
 
+type netProviderKey struct{}
+
 var lnetprovider atomic.Value
 
 func RegisterNetProvider(n netprovider.ConnProvider) {
@@ -20,26 +23,43 @@ func RegisterNetProvider(n netprovider.ConnProvider) {
 	}
 }
 
-func getNetProvider() netprovider.ConnProvider {
-	c := lnetprovider.Load().(netprovider.ConnProvider)
-	if c == nil {
+// WithNetProvider returns a copy of ctx in which n replaces the registered net
+// provider.
+func WithNetProvider(ctx context.Context, n netprovider.ConnProvider) context.Context {
+	if n == nil {
+		panic("net provider must not be nil")
+	}
+	return context.WithValue(ctx, netProviderKey{}, n)
+}
+
+func getNetProvider(ctx context.Context) netprovider.ConnProvider {
+	c, ok := ctx.Value(netProviderKey{}).(netprovider.ConnProvider)
+	if ok {
+		return c
+	}
+	c, ok = lnetprovider.Load().(netprovider.ConnProvider)
+	if !ok {
 		panic("no net provider registered")
 	}
 	return c
 }
 
-func ListenUDP(network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
-	return getNetProvider().ListenUDP(network, laddr)
+func ListenUDP(ctx context.Context, network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
+	return getNetProvider(ctx).ListenUDP(network, laddr)
+}
+
+func ListenUDPReusePort(ctx context.Context, network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
+	return getNetProvider(ctx).ListenUDPReusePort(network, laddr)
 }
 
-func EnableTimestamping(n netprovider.Connection, localHostIface string) error {
-	return getNetProvider().EnableTimestamping(n, localHostIface)
+func EnableTimestamping(ctx context.Context, n netprovider.Connection, localHostIface string) error {
+	return getNetProvider(ctx).EnableTimestamping(n, localHostIface)
 }
 
-func SetDSCP(n netprovider.Connection, dscp uint8) error {
-	return getNetProvider().SetDSCP(n, dscp)
+func SetDSCP(ctx context.Context, n netprovider.Connection, dscp uint8) error {
+	return getNetProvider(ctx).SetDSCP(n, dscp)
 }
 
-func ReadTXTimestamp(n netprovider.Connection) (time.Time, uint32, error) {
-	return getNetProvider().ReadTXTimestamp(n)
+func ReadTXTimestamp(ctx context.Context, n netprovider.Connection) (time.Time, uint32, error) {
+	return getNetProvider(ctx).ReadTXTimestamp(n)
 }
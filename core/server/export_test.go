@@ -8,6 +8,6 @@ var HandleRequest = handleRequest
 
 func LogTSS(t *testing.T, prefix string) {
 	t.Helper()
-	t.Logf("%s:tss = %+v", prefix, tss)
-	t.Logf("%s:tssQ = %+v", prefix, tssQ)
+	t.Logf("%s:tss = %+v", prefix, tss.m)
+	t.Logf("%s:tssQ = %+v", prefix, tss.q)
 }
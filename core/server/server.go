@@ -2,7 +2,9 @@ package server
 
 import (
 	"container/heap"
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -35,9 +37,22 @@ type tssMap map[string]*tssItem
 
 type tssQueue []*tssItem
 
+type tssStore struct {
+	mu sync.Mutex
+	m  tssMap
+	q  tssQueue
+}
+
+type tssStoreKey struct{}
+
 var (
-	tss        = make(tssMap)
-	tssQ       = make(tssQueue, 0, tssCap)
+	ipMetrics    atomic.Pointer[ipServerMetrics]
+	scionMetrics atomic.Pointer[scionServerMetrics]
+
+	tss = &tssStore{
+		m: make(tssMap),
+		q: make(tssQueue, 0, tssCap),
+	}
 	tssMetrics = struct {
 		reqsServedInterleaved prometheus.Counter
 		rxtIncrements         prometheus.Counter
@@ -71,9 +86,27 @@ var (
 			Help: metrics.ServerTssValuesH,
 		}),
 	}
-	tssMu sync.Mutex
 )
 
+func init() {
+	ipMetrics.Store(newIPServerMetrics())
+	scionMetrics.Store(newSCIONServerMetrics())
+}
+
+// WithTimestampStore returns a copy of ctx with a separate timestamp store for
+// interleaved mode. By default, all servers share one timestamp store.
+func WithTimestampStore(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tssStoreKey{}, &tssStore{m: make(tssMap)})
+}
+
+func getTimestampStore(ctx context.Context) *tssStore {
+	s, ok := ctx.Value(tssStoreKey{}).(*tssStore)
+	if ok {
+		return s
+	}
+	return tss
+}
+
 func (q tssQueue) Len() int { return len(q) }
 
 func (q tssQueue) Less(i, j int) bool {
@@ -100,25 +133,32 @@ func (q *tssQueue) Pop() any {
 	return tssi
 }
 
-func handleRequest(clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *ntp.Packet) {
+func handleRequest(ctx context.Context, clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *ntp.Packet) {
 	resp.SetVersion(ntp.VersionMax)
 	resp.SetMode(ntp.ModeServer)
-	resp.Stratum = 1
 	resp.Poll = req.Poll
 	resp.Precision = -32
-	resp.RootDispersion = ntp.Time32{Seconds: 0, Fraction: 10}
-	resp.ReferenceID = serverRefID
+	sm := getLeapSmearer(ctx)
+	disciplined := setSyncStatus(ctx, resp, sm, *rxt)
+	if !disciplined {
+		resp.SetLeapIndicator(ntp.LeapIndicatorNoWarning)
+		resp.Stratum = ntp.StratumPrimary
+		resp.RootDelay = ntp.Time32{}
+		resp.RootDispersion = ntp.Time32{Seconds: 0, Fraction: 10}
+		resp.ReferenceID = serverRefID
+	}
 
-	*txt = timebase.Now()
+	*txt = timebase.Now(ctx)
 
-	rxt64 := ntp.Time64FromTime(*rxt)
-	txt64 := ntp.Time64FromTime(*txt)
+	rxt64 := sm.time64(*rxt)
+	txt64 := sm.time64(*txt)
 
-	tssMu.Lock()
-	defer tssMu.Unlock()
+	s := getTimestampStore(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	var o, min, max int
-	tssi, ok := tss[clientID]
+	tssi, ok := s.m[clientID]
 	if ok {
 		for {
 			var i int
@@ -139,13 +179,13 @@ func handleRequest(clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *
 			if i != tssi.len {
 				// ensure uniqueness of rx timestamps per clientID
 				*rxt = rxt.Add(1)
-				rxt64 = ntp.Time64FromTime(*rxt)
+				rxt64 = sm.time64(*rxt)
 				tssMetrics.rxtIncrements.Inc()
 				if !rxt.Before(*txt) {
 					// ensure strict monotonicity of rx/tx timestamps
 					*txt = *rxt
 					*txt = txt.Add(1)
-					txt64 = ntp.Time64FromTime(*txt)
+					txt64 = sm.time64(*txt)
 					tssMetrics.txtIncrementsBefore.Inc()
 				}
 				continue
@@ -153,27 +193,29 @@ func handleRequest(clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *
 			break
 		}
 	} else {
-		if len(tss) == tssCap && !tssQ[0].qval.After(rxt64) {
+		if len(s.m) == tssCap && !s.q[0].qval.After(rxt64) {
 			// remove minimum timestamp queue item
-			x := heap.Pop(&tssQ).(*tssItem)
-			delete(tss, x.key)
+			x := heap.Pop(&s.q).(*tssItem)
+			delete(s.m, x.key)
 			tssMetrics.tssItems.Dec()
 			tssMetrics.tssValues.Sub(float64(x.len))
 		}
-		if len(tss) == tssCap {
+		if len(s.m) == tssCap {
 			tssi = nil
 		} else {
 			// add timestamp store item
 			tssi = &tssItem{key: clientID}
-			tss[tssi.key] = tssi
+			s.m[tssi.key] = tssi
 			tssMetrics.tssItems.Inc()
 			tssi.qval = rxt64
-			heap.Push(&tssQ, tssi)
+			heap.Push(&s.q, tssi)
 		}
 		o, min, max = -1, -1, -1
 	}
 
-	resp.ReferenceTime = txt64
+	if !disciplined {
+		resp.ReferenceTime = txt64
+	}
 	resp.ReceiveTime = rxt64
 	if req.ReceiveTime != req.TransmitTime && o != -1 {
 		// interleaved mode: serve from timestamp store
@@ -189,7 +231,7 @@ func handleRequest(clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *
 		if max != -1 && rxt64.After(tssi.buf[max].rxt) {
 			// new maximum rx timestamp, fix queue accordingly
 			tssi.qval = rxt64
-			heap.Fix(&tssQ, tssi.qidx)
+			heap.Fix(&s.q, tssi.qidx)
 		}
 		if o != -1 {
 			// maintain interleaved mode timestamp values
@@ -209,9 +251,10 @@ func handleRequest(clientID string, req *ntp.Packet, rxt, txt *time.Time, resp *
 	}
 }
 
-func updateTXTimestamp(clientID string, rxt time.Time, txt *time.Time) {
-	tssMu.Lock()
-	defer tssMu.Unlock()
+func updateTXTimestamp(ctx context.Context, clientID string, rxt time.Time, txt *time.Time) {
+	s := getTimestampStore(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	if !rxt.Before(*txt) {
 		// ensure strict monotonicity of rx/tx timestamps
@@ -220,10 +263,11 @@ func updateTXTimestamp(clientID string, rxt time.Time, txt *time.Time) {
 		tssMetrics.txtIncrementsAfter.Inc()
 	}
 
-	tssi, ok := tss[clientID]
+	tssi, ok := s.m[clientID]
 	if ok {
-		rxt64 := ntp.Time64FromTime(rxt)
-		txt64 := ntp.Time64FromTime(*txt)
+		sm := getLeapSmearer(ctx)
+		rxt64 := sm.time64(rxt)
+		txt64 := sm.time64(*txt)
 		var i, x, max0, max1 int
 		for i, x, max0, max1 = 0, -1, -1, -1; i != tssi.len; i++ {
 			if tssi.buf[i].rxt == rxt64 {
@@ -242,8 +286,8 @@ func updateTXTimestamp(clientID string, rxt time.Time, txt *time.Time) {
 				// No updated tx timestamp available
 				if tssi.len == 1 {
 					// remove timestamp store item
-					heap.Remove(&tssQ, tssi.qidx)
-					delete(tss, tssi.key)
+					heap.Remove(&s.q, tssi.qidx)
+					delete(s.m, tssi.key)
 					tssMetrics.tssItems.Dec()
 					tssMetrics.tssValues.Sub(float64(tssi.len))
 				} else {
@@ -251,7 +295,7 @@ func updateTXTimestamp(clientID string, rxt time.Time, txt *time.Time) {
 					if tssi.buf[max0].rxt == rxt64 {
 						// new maximum rx timestamp, fix queue accordingly
 						tssi.qval = tssi.buf[max1].rxt
-						heap.Fix(&tssQ, tssi.qidx)
+						heap.Fix(&s.q, tssi.qidx)
 					}
 					tssi.buf[x] = tssi.buf[tssi.len-1]
 					tssi.len--
@@ -5,10 +5,8 @@ import (
 	"example.com/scion-time/base/netprovider"
 	"example.com/scion-time/core/netbase"
 	"net"
-	"strconv"
 	"time"
 
-	"github.com/libp2p/go-reuseport"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
@@ -51,14 +49,14 @@ func newIPServerMetrics() *ipServerMetrics {
 	}
 }
 
-func runIPServer(log *zap.Logger, mtrcs *ipServerMetrics,
+func runIPServer(ctx context.Context, log *zap.Logger, mtrcs *ipServerMetrics,
 	conn netprovider.Connection, iface string, dscp uint8, provider *ntske.Provider) {
 	defer conn.Close()
-	err := netbase.EnableTimestamping(conn, iface)
+	err := netbase.EnableTimestamping(ctx, conn, iface)
 	if err != nil {
 		log.Error("failed to enable timestamping", zap.Error(err))
 	}
-	err = netbase.SetDSCP(conn, dscp)
+	err = netbase.SetDSCP(ctx, conn, dscp)
 	if err != nil {
 		log.Info("failed to set DSCP", zap.Error(err))
 	}
@@ -82,8 +80,10 @@ func runIPServer(log *zap.Logger, mtrcs *ipServerMetrics,
 		rxt, err := udp.TimestampFromOOBData(oob)
 		if err != nil {
 			oob = oob[:0]
-			rxt = timebase.Now()
+			rxt = timebase.Now(ctx)
 			log.Error("failed to read packet rx timestamp", zap.Error(err))
+		} else {
+			rxt = timebase.FromSystemTime(ctx, rxt)
 		}
 		buf = buf[:n]
 		mtrcs.pktsReceived.Inc()
@@ -156,7 +156,7 @@ func runIPServer(log *zap.Logger, mtrcs *ipServerMetrics,
 
 		var txt0 time.Time
 		var ntpresp ntp.Packet
-		handleRequest(clientID, &ntpreq, &rxt, &txt0, &ntpresp)
+		handleRequest(ctx, clientID, &ntpreq, &rxt, &txt0, &ntpresp)
 
 		ntp.EncodePacket(&buf, &ntpresp)
 
@@ -188,7 +188,7 @@ func runIPServer(log *zap.Logger, mtrcs *ipServerMetrics,
 			log.Error("failed to write packet", zap.Error(err))
 			continue
 		}
-		txt1, id, err := netbase.ReadTXTimestamp(conn)
+		txt1, id, err := netbase.ReadTXTimestamp(ctx, conn)
 		if err != nil {
 			txt1 = txt0
 			log.Error("failed to read packet tx timestamp", zap.Error(err))
@@ -197,9 +197,10 @@ func runIPServer(log *zap.Logger, mtrcs *ipServerMetrics,
 			log.Error("failed to read packet tx timestamp", zap.Uint32("id", id), zap.Uint32("expected", txID))
 			txID = id + 1
 		} else {
+			txt1 = timebase.FromSystemTime(ctx, txt1)
 			txID++
 		}
-		updateTXTimestamp(clientID, rxt, &txt1)
+		updateTXTimestamp(ctx, clientID, rxt, &txt1)
 
 		mtrcs.reqsServed.Inc()
 	}
@@ -212,22 +213,25 @@ func StartIPServer(ctx context.Context, log *zap.Logger,
 		zap.Int("port", localHost.Port),
 	)
 
-	mtrcs := newIPServerMetrics()
+	mtrcs := ipMetrics.Load()
 
 	if ipServerNumGoroutine == 1 {
-		conn, err := netbase.ListenUDP("udp", localHost)
+		conn, err := netbase.ListenUDP(ctx, "udp", localHost)
 		if err != nil {
 			log.Fatal("failed to listen for packets", zap.Error(err))
 		}
-		go runIPServer(log, mtrcs, conn, localHost.Zone, dscp, provider)
+		timebase.Go(ctx, func() {
+			runIPServer(ctx, log, mtrcs, conn, localHost.Zone, dscp, provider)
+		})
 	} else {
 		for i := ipServerNumGoroutine; i > 0; i-- {
-			conn, err := reuseport.ListenPacket("udp",
-				net.JoinHostPort(localHost.IP.String(), strconv.Itoa(localHost.Port)))
+			conn, err := netbase.ListenUDPReusePort(ctx, "udp", localHost)
 			if err != nil {
 				log.Fatal("failed to listen for packets", zap.Error(err))
 			}
-			go runIPServer(log, mtrcs, conn.(netprovider.Connection), localHost.Zone, dscp, provider)
+			timebase.Go(ctx, func() {
+				runIPServer(ctx, log, mtrcs, conn, localHost.Zone, dscp, provider)
+			})
 		}
 	}
 }
@@ -7,12 +7,10 @@ import (
 	"example.com/scion-time/core/netbase"
 	"net"
 	"net/netip"
-	"strconv"
 	"time"
 
 	"github.com/google/gopacket"
 
-	"github.com/libp2p/go-reuseport"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
@@ -74,11 +72,11 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 	conn netprovider.Connection, localHostIface string, localHostPort int, dscp uint8,
 	fetcher *scion.Fetcher, provider *ntske.Provider) {
 	defer conn.Close()
-	err := netbase.EnableTimestamping(conn, localHostIface)
+	err := netbase.EnableTimestamping(ctx, conn, localHostIface)
 	if err != nil {
 		log.Error("failed to enable timestamping", zap.Error(err))
 	}
-	err = netbase.SetDSCP(conn, dscp)
+	err = netbase.SetDSCP(ctx, conn, dscp)
 	if err != nil {
 		log.Info("failed to set DSCP", zap.Error(err))
 	}
@@ -134,8 +132,10 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 		rxt, err := udp.TimestampFromOOBData(oob)
 		if err != nil {
 			oob = oob[:0]
-			rxt = timebase.Now()
+			rxt = timebase.Now(ctx)
 			log.Error("failed to read packet rx timestamp", zap.Error(err))
+		} else {
+			rxt = timebase.FromSystemTime(ctx, rxt)
 		}
 		buf = buf[:n]
 		mtrcs.pktsReceived.Inc()
@@ -217,7 +217,7 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 				log.Error("failed to write packet", zap.Error(err))
 				continue
 			}
-			_, id, err := netbase.ReadTXTimestamp(conn)
+			_, id, err := netbase.ReadTXTimestamp(ctx, conn)
 			if err != nil {
 				log.Error("failed to read packet tx timestamp", zap.Error(err))
 			} else if id != txID {
@@ -353,7 +353,7 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 
 			var txt0 time.Time
 			var ntpresp ntp.Packet
-			handleRequest(clientID, &ntpreq, &rxt, &txt0, &ntpresp)
+			handleRequest(ctx, clientID, &ntpreq, &rxt, &txt0, &ntpresp)
 
 			scionLayer.TrafficClass = dscp << 2
 			scionLayer.DstIA, scionLayer.SrcIA = scionLayer.SrcIA, scionLayer.DstIA
@@ -451,7 +451,7 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 				log.Error("failed to write packet", zap.Error(err))
 				continue
 			}
-			txt1, id, err := netbase.ReadTXTimestamp(conn)
+			txt1, id, err := netbase.ReadTXTimestamp(ctx, conn)
 			if err != nil {
 				txt1 = txt0
 				log.Error("failed to read packet tx timestamp", zap.Error(err))
@@ -460,9 +460,10 @@ func runSCIONServer(ctx context.Context, log *zap.Logger, mtrcs *scionServerMetr
 				log.Error("failed to read packet tx timestamp", zap.Uint32("id", id), zap.Uint32("expected", txID))
 				txID = id + 1
 			} else {
+				txt1 = timebase.FromSystemTime(ctx, txt1)
 				txID++
 			}
-			updateTXTimestamp(clientID, rxt, &txt1)
+			updateTXTimestamp(ctx, clientID, rxt, &txt1)
 
 			mtrcs.reqsServed.Inc()
 		}
@@ -483,24 +484,27 @@ func StartSCIONServer(ctx context.Context, log *zap.Logger,
 	localHostPort := localHost.Port
 	localHost.Port = scion.EndhostPort
 
-	mtrcs := newSCIONServerMetrics()
+	mtrcs := scionMetrics.Load()
 
 	if scionServerNumGoroutine == 1 {
 		fetcher := scion.NewFetcher(scion.NewDaemonConnector(ctx, daemonAddr))
-		conn, err := netbase.ListenUDP("udp", localHost)
+		conn, err := netbase.ListenUDP(ctx, "udp", localHost)
 		if err != nil {
 			log.Fatal("failed to listen for packets", zap.Error(err))
 		}
-		go runSCIONServer(ctx, log, mtrcs, conn, localHost.Zone, localHostPort, dscp, fetcher, provider)
+		timebase.Go(ctx, func() {
+			runSCIONServer(ctx, log, mtrcs, conn, localHost.Zone, localHostPort, dscp, fetcher, provider)
+		})
 	} else {
 		for i := scionServerNumGoroutine; i > 0; i-- {
 			fetcher := scion.NewFetcher(scion.NewDaemonConnector(ctx, daemonAddr))
-			conn, err := reuseport.ListenPacket("udp",
-				net.JoinHostPort(localHost.IP.String(), strconv.Itoa(localHost.Port)))
+			conn, err := netbase.ListenUDPReusePort(ctx, "udp", localHost)
 			if err != nil {
 				log.Fatal("failed to listen for packets", zap.Error(err))
 			}
-			go runSCIONServer(ctx, log, mtrcs, conn.(netprovider.Connection), localHost.Zone, localHostPort, dscp, fetcher, provider)
+			timebase.Go(ctx, func() {
+				runSCIONServer(ctx, log, mtrcs, conn, localHost.Zone, localHostPort, dscp, fetcher, provider)
+			})
 		}
 	}
 }
@@ -518,12 +522,14 @@ func StartSCIONDispatcher(ctx context.Context, log *zap.Logger,
 
 	localHost.Port = scion.EndhostPort
 
-	mtrcs := newSCIONServerMetrics()
+	mtrcs := scionMetrics.Load()
 
-	conn, err := netbase.ListenUDP("udp", localHost)
+	conn, err := netbase.ListenUDP(ctx, "udp", localHost)
 	if err != nil {
 		log.Fatal("failed to listen for packets", zap.Error(err))
 	}
-	go runSCIONServer(ctx, log, mtrcs, conn, localHost.Zone, localHost.Port,
-		0 /* DSCP */, nil /* DRKey fetcher */, nil /* NTSKE provider */)
+	timebase.Go(ctx, func() {
+		runSCIONServer(ctx, log, mtrcs, conn, localHost.Zone, localHost.Port,
+			0 /* DSCP */, nil /* DRKey fetcher */, nil /* NTSKE provider */)
+	})
 }
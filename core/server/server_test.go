@@ -1,39 +1,249 @@
 package server_test
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
+
+	"example.com/scion-time/core/client"
 	"example.com/scion-time/core/server"
+	"example.com/scion-time/core/sync"
 	"example.com/scion-time/core/timebase"
 
 	"example.com/scion-time/driver/clock"
 
 	"example.com/scion-time/net/ntp"
+	"example.com/scion-time/net/truetime"
 )
 
+type testReferenceClock struct {
+	m   client.Measurement
+	err error
+}
+
+func (c *testReferenceClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	return c.m, c.err
+}
+
 func init() {
 	lclk := &clock.SystemClock{Log: zap.NewNop()}
 	timebase.RegisterClock(lclk)
 }
 
 func TestSimpleRequest(t *testing.T) {
+	ctx := context.Background()
+
 	server.LogTSS(t, "pre")
 
-	cTxTime := timebase.Now()
+	cTxTime := timebase.Now(ctx)
 	ntpreq := ntp.Packet{}
 	ntpreq.SetVersion(ntp.VersionMax)
 	ntpreq.SetMode(ntp.ModeClient)
 	ntpreq.TransmitTime = ntp.Time64FromTime(cTxTime)
 
-	rxt := timebase.Now()
+	rxt := timebase.Now(ctx)
 	clientID := "client-0"
 
 	var txt0 time.Time
 	var ntpresp ntp.Packet
-	server.HandleRequest(clientID, &ntpreq, &rxt, &txt0, &ntpresp)
+	server.HandleRequest(ctx, clientID, &ntpreq, &rxt, &txt0, &ntpresp)
 
 	server.LogTSS(t, "post")
 }
+
+func TestSyncStatus(t *testing.T) {
+	refclk := &testReferenceClock{err: errors.New("unreachable")}
+	ctx := sync.WithClocks(context.Background(),
+		[]client.ReferenceClock{refclk}, nil, sync.Config{})
+	ctx = server.WithTimestampStore(ctx)
+
+	request := func(clientID string) ntp.Packet {
+		ntpreq := ntp.Packet{}
+		ntpreq.SetVersion(ntp.VersionMax)
+		ntpreq.SetMode(ntp.ModeClient)
+		ntpreq.TransmitTime = ntp.Time64FromTime(timebase.Now(ctx))
+		rxt := timebase.Now(ctx)
+		var txt time.Time
+		var ntpresp ntp.Packet
+		server.HandleRequest(ctx, clientID, &ntpreq, &rxt, &txt, &ntpresp)
+		return ntpresp
+	}
+
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	resp := request("client-1")
+	if resp.LeapIndicator() != ntp.LeapIndicatorNoWarning || resp.Stratum != ntp.StratumPrimary {
+		t.Errorf("server not yet synchronized responded with LI = %d, stratum = %d",
+			resp.LeapIndicator(), resp.Stratum)
+	}
+	if err := ntp.ValidateResponseMetadata(&resp); err != nil {
+		t.Errorf("response of server not yet synchronized must be valid: %v", err)
+	}
+
+	refclk.err = nil
+	refclk.m = client.Measurement{
+		Delay:          2 * time.Millisecond,
+		Weight:         1000.0,
+		Stratum:        1,
+		RootDelay:      3 * time.Millisecond,
+		RootDispersion: 1 * time.Millisecond,
+		RefID:          0x0a000001,
+		Leap:           leap.Insert,
+	}
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	resp = request("client-2")
+	if resp.LeapIndicator() != ntp.LeapIndicatorInsertSecond || resp.Stratum != 2 ||
+		resp.ReferenceID != 0x0a000001 {
+		t.Errorf("synchronized server responded with LI = %d, stratum = %d, reference ID = %#x",
+			resp.LeapIndicator(), resp.Stratum, resp.ReferenceID)
+	}
+	if d := ntp.DurationFromTime32(resp.RootDelay); d < 4900*time.Microsecond || d > 5100*time.Microsecond {
+		t.Errorf("resp.RootDelay == %v; want 5ms", d)
+	}
+	if d := ntp.DurationFromTime32(resp.RootDispersion); d < 900*time.Microsecond {
+		t.Errorf("resp.RootDispersion == %v; want >= 1ms", d)
+	}
+
+	refclk.m.Stratum = ntp.StratumMax
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	resp = request("client-3")
+	if resp.Stratum != ntp.StratumMax {
+		t.Errorf("server synchronized to stratum %d responded with stratum = %d",
+			ntp.StratumMax, resp.Stratum)
+	}
+
+	refclk.m.Stratum = ntp.StratumUnsynchronized
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	resp = request("client-4")
+	if resp.LeapIndicator() != ntp.LeapIndicatorUnknown || resp.Stratum != ntp.StratumUnsynchronized {
+		t.Errorf("unsynchronized server responded with LI = %d, stratum = %d",
+			resp.LeapIndicator(), resp.Stratum)
+	}
+	if ntp.ValidateResponseMetadata(&resp) == nil {
+		t.Errorf("response of unsynchronized server must not be valid")
+	}
+}
+
+func TestTrueTime(t *testing.T) {
+	refclk := &testReferenceClock{err: errors.New("unreachable")}
+	ctx := timebase.WithClock(context.Background(),
+		&clock.SystemClock{Log: zap.NewNop(), Tolerance: 100e-6})
+	ctx = sync.WithClocks(ctx, []client.ReferenceClock{refclk}, nil, sync.Config{})
+
+	path := filepath.Join(t.TempDir(), "truetime.sock")
+	server.StartTrueTimeServer(ctx, zap.NewNop(), path)
+	c, err := truetime.Dial(path)
+	if err != nil {
+		t.Fatalf("truetime.Dial failed: %v", err)
+	}
+	defer c.Close()
+
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	_, _, err = c.Now()
+	if err != truetime.ErrUnsynchronized {
+		t.Errorf("c.Now() returned %v; want %v", err, truetime.ErrUnsynchronized)
+	}
+
+	refclk.err = nil
+	refclk.m = client.Measurement{
+		Delay:          2 * time.Millisecond,
+		Weight:         1000.0,
+		Stratum:        1,
+		RootDelay:      3 * time.Millisecond,
+		RootDispersion: 1 * time.Millisecond,
+	}
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	t0 := time.Now()
+	earliest, latest, err := c.Now()
+	t1 := time.Now()
+	if err != nil {
+		t.Fatalf("c.Now() failed: %v", err)
+	}
+	if earliest.After(t1) || latest.Before(t0) {
+		t.Errorf("c.Now() == [%v, %v]; want interval containing [%v, %v]", earliest, latest, t0, t1)
+	}
+	if d := latest.Sub(earliest); d < 7*time.Millisecond || d > 8*time.Millisecond {
+		t.Errorf("c.Now() returned interval of %v; want 7ms", d)
+	}
+}
+
+func TestSyncStatusLeapSeconds(t *testing.T) {
+	end := leap.MonthEnd(time.Now())
+	tbl := &leap.Table{Entries: []leap.Entry{
+		{At: end.AddDate(-1, 0, 0), Offset: 37},
+		{At: end, Offset: 36},
+	}}
+	refclk := &testReferenceClock{m: client.Measurement{Weight: 1000.0, Leap: leap.Insert}}
+	ctx := sync.WithClocks(context.Background(),
+		[]client.ReferenceClock{refclk}, nil, sync.Config{LeapSeconds: tbl})
+	ctx = server.WithTimestampStore(ctx)
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+
+	ntpreq := ntp.Packet{}
+	ntpreq.SetVersion(ntp.VersionMax)
+	ntpreq.SetMode(ntp.ModeClient)
+	ntpreq.TransmitTime = ntp.Time64FromTime(timebase.Now(ctx))
+	rxt := timebase.Now(ctx)
+	var txt time.Time
+	var resp ntp.Packet
+	server.HandleRequest(ctx, "client-3", &ntpreq, &rxt, &txt, &resp)
+	if resp.LeapIndicator() != ntp.LeapIndicatorDeleteSecond {
+		t.Errorf("resp.LeapIndicator() == %d; want %d",
+			resp.LeapIndicator(), ntp.LeapIndicatorDeleteSecond)
+	}
+}
+
+func TestLeapSmear(t *testing.T) {
+	end := leap.MonthEnd(time.Now())
+	refclk := &testReferenceClock{m: client.Measurement{Weight: 1000.0, Leap: leap.Insert}}
+	ctx := sync.WithClocks(context.Background(),
+		[]client.ReferenceClock{refclk}, nil, sync.Config{})
+	ctx = server.WithTimestampStore(ctx)
+	ctx = server.WithLeapSmear(ctx, server.LeapSmear{
+		Mode:     server.LeapSmearLinear,
+		Duration: time.Hour,
+	})
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+
+	for _, tc := range []struct {
+		rxt   time.Time
+		want  time.Time
+		refID uint32
+		li    uint8
+	}{
+		{end.Add(-2 * time.Hour), end.Add(-2 * time.Hour), 0, ntp.LeapIndicatorNoWarning},
+		{end.Add(-15 * time.Minute), end.Add(-15*time.Minute - 250*time.Millisecond),
+			ntp.ReferenceIDLeapSmear, ntp.LeapIndicatorNoWarning},
+		{end.Add(-500 * time.Millisecond), time.Time{}, 0, ntp.LeapIndicatorUnknown},
+		{end.Add(15 * time.Minute), end.Add(15*time.Minute + 250*time.Millisecond),
+			ntp.ReferenceIDLeapSmear, ntp.LeapIndicatorNoWarning},
+	} {
+		ntpreq := ntp.Packet{}
+		ntpreq.SetVersion(ntp.VersionMax)
+		ntpreq.SetMode(ntp.ModeClient)
+		ntpreq.TransmitTime = ntp.Time64FromTime(tc.rxt)
+		rxt := tc.rxt
+		var txt time.Time
+		var resp ntp.Packet
+		server.HandleRequest(ctx, "client-4", &ntpreq, &rxt, &txt, &resp)
+		if resp.LeapIndicator() != tc.li {
+			t.Errorf("%v: resp.LeapIndicator() == %d; want %d", tc.rxt, resp.LeapIndicator(), tc.li)
+		}
+		if tc.li == ntp.LeapIndicatorUnknown {
+			continue
+		}
+		if (resp.ReferenceID == ntp.ReferenceIDLeapSmear) != (tc.refID == ntp.ReferenceIDLeapSmear) {
+			t.Errorf("%v: resp.ReferenceID == %#x", tc.rxt, resp.ReferenceID)
+		}
+		d := ntp.TimeFromTime64(resp.ReceiveTime).Sub(tc.want)
+		if d < -time.Microsecond || d > time.Microsecond {
+			t.Errorf("%v: resp.ReceiveTime off by %v", tc.rxt, d)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"example.com/scion-time/base/leap"
+
+	"example.com/scion-time/net/ntp"
+)
+
+const (
+	LeapSmearLinear = "linear"
+	LeapSmearCosine = "cosine"
+
+	minLeapSmearDuration = 2 * time.Second
+)
+
+// LeapSmear configures the server to gradually offset the time it serves by
+// the leap second instead of announcing it. The offset grows over a window of
+// the given Duration centered on the leap second, either linearly or
+// following a cosine. The local clock itself stays on UTC.
+type LeapSmear struct {
+	Mode     string
+	Duration time.Duration
+}
+
+type leapSmearer struct {
+	cfg LeapSmear
+	at  atomic.Int64
+	ind atomic.Uint32
+}
+
+type leapSmearKey struct{}
+
+var (
+	errInvalidLeapSmearMode     = errors.New("invalid leap smear mode")
+	errInvalidLeapSmearDuration = errors.New("invalid leap smear duration")
+)
+
+func (cfg LeapSmear) Validate() error {
+	if cfg.Mode != LeapSmearLinear && cfg.Mode != LeapSmearCosine {
+		return errInvalidLeapSmearMode
+	}
+	if cfg.Duration < minLeapSmearDuration {
+		return errInvalidLeapSmearDuration
+	}
+	return nil
+}
+
+// WithLeapSmear returns a copy of ctx in which servers smear leap seconds as
+// configured by cfg.
+func WithLeapSmear(ctx context.Context, cfg LeapSmear) context.Context {
+	err := cfg.Validate()
+	if err != nil {
+		panic(err)
+	}
+	return context.WithValue(ctx, leapSmearKey{}, &leapSmearer{cfg: cfg})
+}
+
+func getLeapSmearer(ctx context.Context) *leapSmearer {
+	s, _ := ctx.Value(leapSmearKey{}).(*leapSmearer)
+	return s
+}
+
+// observe records the leap second pending at time now. A recorded leap second
+// is kept until its smear window has ended.
+func (s *leapSmearer) observe(now time.Time, ind leap.Indicator) {
+	if ind == leap.None {
+		return
+	}
+	at, prev := s.leap()
+	if prev != leap.None && now.Before(at.Add(s.cfg.Duration/2)) {
+		return
+	}
+	s.at.Store(leap.MonthEnd(now).UnixNano())
+	s.ind.Store(uint32(ind))
+}
+
+func (s *leapSmearer) leap() (time.Time, leap.Indicator) {
+	at := s.at.Load()
+	if at == 0 {
+		return time.Time{}, leap.None
+	}
+	return time.Unix(0, at).UTC(), leap.Indicator(s.ind.Load())
+}
+
+// progress returns the fraction of the leap second smeared at time t.
+func (s *leapSmearer) progress(t, at time.Time) float64 {
+	x := (t.Sub(at) + s.cfg.Duration/2).Seconds() / s.cfg.Duration.Seconds()
+	if x <= 0.0 {
+		return 0.0
+	}
+	if x >= 1.0 {
+		return 1.0
+	}
+	if s.cfg.Mode == LeapSmearCosine {
+		return (1.0 - math.Cos(math.Pi*x)) / 2.0
+	}
+	return x
+}
+
+// active reports whether time t is within the smear window.
+func (s *leapSmearer) active(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+	at, ind := s.leap()
+	if ind == leap.None {
+		return false
+	}
+	d := t.Sub(at)
+	return -s.cfg.Duration/2 <= d && d < s.cfg.Duration/2
+}
+
+// ambiguous reports whether time t may be within an inserted leap second, in
+// which the local clock repeats a second and the smeared time is undefined.
+func (s *leapSmearer) ambiguous(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+	at, ind := s.leap()
+	return ind == leap.Insert && !t.Before(at.Add(-time.Second)) && t.Before(at)
+}
+
+// smear returns the smeared time corresponding to the local time t.
+func (s *leapSmearer) smear(t time.Time) time.Time {
+	if s == nil {
+		return t
+	}
+	at, ind := s.leap()
+	switch ind {
+	case leap.Insert:
+		p := s.progress(t, at)
+		if !t.Before(at) {
+			return t.Add(time.Second - time.Duration(p*float64(time.Second)))
+		}
+		return t.Add(-time.Duration(p * float64(time.Second)))
+	case leap.Delete:
+		p := s.progress(t, at)
+		if !t.Before(at) {
+			return t.Add(-time.Second + time.Duration(p*float64(time.Second)))
+		}
+		return t.Add(time.Duration(p * float64(time.Second)))
+	default:
+		return t
+	}
+}
+
+func (s *leapSmearer) time64(t time.Time) ntp.Time64 {
+	return ntp.Time64FromTime(s.smear(t))
+}
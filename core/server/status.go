@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"example.com/scion-time/base/leap"
+
+	"example.com/scion-time/core/sync"
+
+	"example.com/scion-time/net/ntp"
+)
+
+// setSyncStatus sets the synchronization related fields of resp from the
+// current synchronization state of the local clock. It returns false if the
+// local clock is not disciplined by this service but externally. If leap
+// seconds are smeared by sm, they are not announced, and responses to requests
+// received at rxt in the smear window carry a distinct reference ID.
+func setSyncStatus(ctx context.Context, resp *ntp.Packet, sm *leapSmearer, rxt time.Time) bool {
+	s, ok := sync.GetStatus(ctx)
+	if !ok {
+		return false
+	}
+	if sm != nil {
+		sm.observe(rxt, s.Leap)
+		if sm.ambiguous(rxt) {
+			s.Synchronized = false
+		}
+		s.Leap = leap.None
+		if sm.active(rxt) {
+			s.ReferenceID = ntp.ReferenceIDLeapSmear
+		}
+	}
+	if !s.Synchronized {
+		resp.SetLeapIndicator(ntp.LeapIndicatorUnknown)
+		resp.Stratum = ntp.StratumUnsynchronized
+		resp.RootDelay = ntp.Time32{}
+		resp.RootDispersion = ntp.Time32{}
+		resp.ReferenceID = 0
+		resp.ReferenceTime = ntp.Time64{}
+		return true
+	}
+	resp.SetLeapIndicator(uint8(s.Leap))
+	resp.Stratum = s.Stratum
+	resp.RootDelay = ntp.Time32FromDuration(s.RootDelay)
+	resp.RootDispersion = ntp.Time32FromDuration(s.RootDispersion)
+	resp.ReferenceID = s.ReferenceID
+	resp.ReferenceTime = sm.time64(s.ReferenceTime)
+	return true
+}
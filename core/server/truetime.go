@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/core/sync"
+
+	"example.com/scion-time/net/truetime"
+)
+
+func serveTrueTime(ctx context.Context, log *zap.Logger, conn net.Conn) {
+	defer conn.Close()
+	req := make([]byte, truetime.RequestLen)
+	var resp []byte
+	for {
+		_, err := io.ReadFull(conn, req)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Info("failed to read time interval request", zap.Error(err))
+			}
+			return
+		}
+		err = truetime.DecodeRequest(req)
+		if err != nil {
+			log.Info("failed to decode time interval request", zap.Error(err))
+			return
+		}
+		var r truetime.Response
+		var ok bool
+		r.Earliest, r.Latest, ok = sync.GetTimeInterval(ctx)
+		if !ok {
+			r.Status = truetime.StatusUnsynchronized
+		}
+		truetime.EncodeResponse(&resp, &r)
+		_, err = conn.Write(resp)
+		if err != nil {
+			log.Info("failed to write time interval response", zap.Error(err))
+			return
+		}
+	}
+}
+
+func runTrueTimeServer(ctx context.Context, log *zap.Logger, l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Fatal("failed to accept connection", zap.Error(err))
+		}
+		go serveTrueTime(ctx, log, conn)
+	}
+}
+
+// StartTrueTimeServer starts serving time intervals, see sync.GetTimeInterval,
+// to local applications on the Unix domain socket at path.
+func StartTrueTimeServer(ctx context.Context, log *zap.Logger, path string) {
+	log.Info("server listening for time interval requests", zap.String("path", path))
+
+	fi, err := os.Lstat(path)
+	if err == nil && fi.Mode()&os.ModeSocket != 0 {
+		err = os.Remove(path)
+		if err != nil {
+			log.Fatal("failed to remove stale socket", zap.Error(err))
+		}
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatal("failed to listen for connections", zap.Error(err))
+	}
+	go runTrueTimeServer(ctx, log, l)
+}
@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+)
+
+const (
+	AggregationMedian      = "median"
+	AggregationFTM         = "ftm"
+	AggregationMarzullo    = "marzullo"
+	AggregationTrimmedMean = "trimmed_mean"
+
+	defaultTrim = 0.25
+)
+
+var (
+	errUnknownAggregation = errors.New("unknown offset aggregation")
+)
+
+// An Aggregator combines the clock offsets measured to multiple reference
+// clocks into a single clock offset. Aggregate is only called with at least
+// one measurement and may reorder ms.
+type Aggregator interface {
+	Aggregate(ms []client.Measurement) time.Duration
+}
+
+// MedianAggregator selects the median offset.
+type MedianAggregator struct{}
+
+// FTMAggregator selects the fault-tolerant midpoint of the offsets.
+type FTMAggregator struct{}
+
+// MarzulloAggregator selects the midpoint of the smallest interval consistent
+// with the largest number of measurements, where the offset of a measurement
+// is known within half its round trip delay (Marzullo's algorithm).
+type MarzulloAggregator struct{}
+
+// TrimmedMeanAggregator computes the weighted mean of the offsets after
+// discarding the fraction Trim of the total weight at both ends of the
+// offset distribution. Trim must be in range [0, 0.5).
+type TrimmedMeanAggregator struct {
+	Trim float64
+}
+
+type edge struct {
+	at   time.Duration
+	kind int // +1: interval starts, -1: interval ends
+}
+
+// NewAggregator returns the aggregator with the given name. An empty name
+// selects def.
+func NewAggregator(name string, def Aggregator) (Aggregator, error) {
+	switch name {
+	case "":
+		return def, nil
+	case AggregationMedian:
+		return MedianAggregator{}, nil
+	case AggregationFTM:
+		return FTMAggregator{}, nil
+	case AggregationMarzullo:
+		return MarzulloAggregator{}, nil
+	case AggregationTrimmedMean:
+		return TrimmedMeanAggregator{Trim: defaultTrim}, nil
+	default:
+		return nil, errUnknownAggregation
+	}
+}
+
+// aggregate combines ms into a single measurement. Its offset is selected by
+// agg, its delay, error bound and weight are the medians of those of ms.
+func aggregate(agg Aggregator, ms []client.Measurement) client.Measurement {
+	rtd := make([]time.Duration, len(ms))
+	eb := make([]time.Duration, len(ms))
+	w := make([]float64, len(ms))
+	var t time.Time
+	for i := 0; i != len(ms); i++ {
+		rtd[i] = ms[i].Delay
+		eb[i] = ms[i].ErrorBound
+		w[i] = ms[i].Weight
+		if ms[i].Time.After(t) {
+			t = ms[i].Time
+		}
+	}
+	sort.Float64s(w)
+	var weight float64
+	if len(w)%2 != 0 {
+		weight = w[len(w)/2]
+	} else {
+		weight = (w[len(w)/2-1] + w[len(w)/2]) / 2
+	}
+	return client.Measurement{
+		Time:       t,
+		Offset:     agg.Aggregate(ms),
+		Delay:      timemath.Median(rtd),
+		ErrorBound: timemath.Median(eb),
+		Weight:     weight,
+	}
+}
+
+func offsets(ms []client.Measurement) []time.Duration {
+	off := make([]time.Duration, len(ms))
+	for i := 0; i != len(ms); i++ {
+		off[i] = ms[i].Offset
+	}
+	return off
+}
+
+func (MedianAggregator) Aggregate(ms []client.Measurement) time.Duration {
+	return timemath.Median(offsets(ms))
+}
+
+func (FTMAggregator) Aggregate(ms []client.Measurement) time.Duration {
+	return timemath.FaultTolerantMidpoint(offsets(ms))
+}
+
+func (MarzulloAggregator) Aggregate(ms []client.Measurement) time.Duration {
+	if len(ms) == 0 {
+		panic("unexpected number of measurements")
+	}
+	lo, hi, _ := intersection(ms)
+	return lo + (hi-lo)/2
+}
+
+// intersection returns the smallest interval [lo, hi] consistent with the
+// largest number n of measurements, where the offset of a measurement is known
+// within half its round trip delay (Marzullo's algorithm).
+func intersection(ms []client.Measurement) (lo, hi time.Duration, n int) {
+	es := make([]edge, 0, 2*len(ms))
+	for _, m := range ms {
+		r := timemath.Abs(m.Delay) / 2
+		es = append(es, edge{m.Offset - r, 1}, edge{m.Offset + r, -1})
+	}
+	sort.Slice(es, func(i, j int) bool {
+		return es[i].at < es[j].at || es[i].at == es[j].at && es[i].kind > es[j].kind
+	})
+	var cnt int
+	for i := 0; i != len(es); i++ {
+		cnt += es[i].kind
+		if cnt > n {
+			n = cnt
+			lo, hi = es[i].at, es[i+1].at
+		}
+	}
+	return lo, hi, n
+}
+
+func (a TrimmedMeanAggregator) Aggregate(ms []client.Measurement) time.Duration {
+	if len(ms) == 0 {
+		panic("unexpected number of measurements")
+	}
+	if a.Trim < 0.0 || a.Trim >= 0.5 {
+		panic("invalid trim fraction")
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i].Offset < ms[j].Offset
+	})
+	weight := func(m client.Measurement) float64 { return m.Weight }
+	var w float64
+	for _, m := range ms {
+		w += m.Weight
+	}
+	if w <= 0.0 {
+		weight = func(client.Measurement) float64 { return 1.0 }
+		w = float64(len(ms))
+	}
+	lo, hi := a.Trim*w, (1.0-a.Trim)*w
+	var c, sum, sumw float64
+	for _, m := range ms {
+		x0, x1 := c, c+weight(m)
+		c = x1
+		if x0 < lo {
+			x0 = lo
+		}
+		if x1 > hi {
+			x1 = hi
+		}
+		if x1 > x0 {
+			sum += (x1 - x0) * float64(m.Offset)
+			sumw += x1 - x0
+		}
+	}
+	return time.Duration(math.Round(sum / sumw))
+}
@@ -0,0 +1,128 @@
+package sync_test
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/sync"
+)
+
+func TestAggregators(t *testing.T) {
+	ms := func() []client.Measurement {
+		return []client.Measurement{
+			{Offset: 10 * time.Millisecond, Delay: 4 * time.Millisecond, Weight: 1.0},
+			{Offset: 11 * time.Millisecond, Delay: 4 * time.Millisecond, Weight: 1.0},
+			{Offset: 13 * time.Millisecond, Delay: 4 * time.Millisecond, Weight: 2.0},
+			{Offset: 100 * time.Millisecond, Delay: 2 * time.Millisecond, Weight: 1.0},
+		}
+	}
+	for _, tc := range []struct {
+		agg  sync.Aggregator
+		want time.Duration
+	}{
+		{sync.MedianAggregator{}, 12 * time.Millisecond},
+		{sync.FTMAggregator{}, 12 * time.Millisecond},
+		{sync.MarzulloAggregator{}, 11500 * time.Microsecond},
+		{sync.TrimmedMeanAggregator{Trim: 0.2}, 12333333 * time.Nanosecond},
+		{sync.TrimmedMeanAggregator{}, 29400 * time.Microsecond},
+	} {
+		x := tc.agg.Aggregate(ms())
+		if x != tc.want {
+			t.Errorf("%T.Aggregate() == %v; want %v", tc.agg, x, tc.want)
+		}
+	}
+}
+
+func TestNewAggregator(t *testing.T) {
+	a, err := sync.NewAggregator("", sync.FTMAggregator{})
+	if err != nil || a != (sync.FTMAggregator{}) {
+		t.Errorf("NewAggregator(\"\") == %v, %v; want default", a, err)
+	}
+	_, err = sync.NewAggregator("mean", nil)
+	if err == nil {
+		t.Errorf("NewAggregator(\"mean\") succeeded")
+	}
+}
+
+func TestSelectSources(t *testing.T) {
+	ms := []client.Measurement{
+		{Source: "a", Offset: 1 * time.Millisecond, Delay: 4 * time.Millisecond},
+		{Source: "b", Offset: 2 * time.Millisecond, Delay: 4 * time.Millisecond},
+		{Source: "c", Offset: 50 * time.Millisecond, Delay: 4 * time.Millisecond},
+		{Source: "d", Offset: 0, Delay: 2 * time.Millisecond},
+		{Source: "local", Weight: 1000.0},
+	}
+	names := []string{"a", "b", "c", "d", "e"}
+	n, ss := sync.SelectSources(zap.NewNop(), "global", names, ms, nil, time.Time{})
+	if n != 4 {
+		t.Errorf("SelectSources(...) == %d; want 4", n)
+	}
+	for i := 0; i != n; i++ {
+		if ms[i].Source == "c" {
+			t.Errorf("falseticker c selected")
+		}
+	}
+	want := map[string]string{
+		"a": sync.SourceTruechimer,
+		"b": sync.SourceTruechimer,
+		"c": sync.SourceFalseticker,
+		"d": sync.SourceTruechimer,
+		"e": sync.SourceUnreachable,
+	}
+	if len(ss) != len(want) {
+		t.Errorf("len(ss) == %d; want %d", len(ss), len(want))
+	}
+	for _, s := range ss {
+		if s.State != want[s.Source] {
+			t.Errorf("source %s is %s; want %s", s.Source, s.State, want[s.Source])
+		}
+	}
+
+	// no majority
+	ms = []client.Measurement{
+		{Source: "a", Offset: 0, Delay: 2 * time.Millisecond},
+		{Source: "b", Offset: 50 * time.Millisecond, Delay: 2 * time.Millisecond},
+	}
+	n, _ = sync.SelectSources(zap.NewNop(), "local", []string{"a", "b"}, ms, nil, time.Time{})
+	if n != 2 {
+		t.Errorf("SelectSources(...) == %d; want 2", n)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	rep := sync.NewReputation(sync.ReputationConfig{Backoff: time.Minute})
+	names := []string{"a", "b", "c"}
+	round := func(now time.Time, off time.Duration) (int, []sync.SourceState) {
+		ms := []client.Measurement{
+			{Source: "a", Offset: 0, Delay: 2 * time.Millisecond},
+			{Source: "b", Offset: 0, Delay: 2 * time.Millisecond},
+			{Source: "c", Offset: off, Delay: 2 * time.Millisecond},
+		}
+		return sync.SelectSources(zap.NewNop(), "global", names, ms, rep, now)
+	}
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var i int
+	for i = 0; i != 100; i++ {
+		n, ss := round(t0.Add(time.Duration(i)*time.Second), 50*time.Millisecond)
+		if n != 2 {
+			t.Fatalf("round %d: SelectSources(...) == %d; want 2", i, n)
+		}
+		if ss[2].Quarantined {
+			break
+		}
+	}
+	if i == 100 {
+		t.Fatalf("falseticker c not quarantined")
+	}
+	n, ss := round(t0.Add(time.Duration(i+1)*time.Second), 0)
+	if n != 2 || ss[2].State != sync.SourceTruechimer || !ss[2].Quarantined {
+		t.Errorf("quarantined source c selected: %d, %+v", n, ss[2])
+	}
+	n, _ = round(t0.Add(time.Duration(i+1)*time.Second+time.Hour), 0)
+	if n != 3 {
+		t.Errorf("SelectSources(...) == %d after quarantine; want 3", n)
+	}
+}
@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"time"
+)
+
+// FileConfig is the synchronization configuration as specified in the
+// configuration file of a time service instance or of a simulated instance.
+// Config converts it into a Config.
+type FileConfig struct {
+	LocalSync  LoopFileConfig       `toml:"local_sync,omitempty"`
+	GlobalSync LoopFileConfig       `toml:"global_sync,omitempty"`
+	StepPolicy StepPolicyFileConfig `toml:"step_policy,omitempty"`
+	Reputation ReputationFileConfig `toml:"reputation,omitempty"`
+}
+
+// LoopFileConfig configures a synchronization loop, see LoopConfig. An empty
+// Aggregation selects the default aggregator of the loop.
+type LoopFileConfig struct {
+	Aggregation string           `toml:"aggregation,omitempty"`
+	Impact      float64          `toml:"impact,omitempty"`
+	Cutoff      Duration         `toml:"cutoff,omitempty"`
+	Timeout     Duration         `toml:"timeout,omitempty"`
+	Interval    Duration         `toml:"interval,omitempty"`
+	Discipline  string           `toml:"discipline,omitempty"`
+	PLL         PLLFileConfig    `toml:"pll,omitempty"`
+	Kalman      KalmanFileConfig `toml:"kalman,omitempty"`
+}
+
+// PLLFileConfig configures the phase-locked loop, see PLLConfig.
+type PLLFileConfig struct {
+	StepThreshold Duration `toml:"step_threshold,omitempty"`
+	PInit         float64  `toml:"p_init,omitempty"`
+	PIRatio       float64  `toml:"pi_ratio,omitempty"`
+	CaptureTime   Duration `toml:"capture_time,omitempty"`
+	StiffenRate   float64  `toml:"stiffen_rate,omitempty"`
+	PLimit        float64  `toml:"p_limit,omitempty"`
+	LowP          float64  `toml:"low_p,omitempty"`
+	LowI          float64  `toml:"low_i,omitempty"`
+	MidP          float64  `toml:"mid_p,omitempty"`
+	MidI          float64  `toml:"mid_i,omitempty"`
+	MaxSlewRate   float64  `toml:"max_slew_rate,omitempty"`
+}
+
+// KalmanFileConfig configures the Kalman filter, see KalmanConfig.
+type KalmanFileConfig struct {
+	StepThreshold    Duration `toml:"step_threshold,omitempty"`
+	PhaseNoise       float64  `toml:"phase_noise,omitempty"`
+	FrequencyNoise   float64  `toml:"frequency_noise,omitempty"`
+	MeasurementNoise Duration `toml:"measurement_noise,omitempty"`
+	MaxSlewRate      float64  `toml:"max_slew_rate,omitempty"`
+	MaxFrequency     float64  `toml:"max_frequency,omitempty"`
+}
+
+// StepPolicyFileConfig restricts when the local clock may be stepped, see
+// StepPolicy.
+type StepPolicyFileConfig struct {
+	SlewOnly       bool     `toml:"slew_only,omitempty"`
+	MaxSteps       int      `toml:"max_steps,omitempty"`
+	StepUpdates    int      `toml:"step_updates,omitempty"`
+	ForwardOnly    bool     `toml:"forward_only,omitempty"`
+	PanicThreshold Duration `toml:"panic_threshold,omitempty"`
+}
+
+// ReputationFileConfig configures the reputation of reference clocks, see
+// ReputationConfig.
+type ReputationFileConfig struct {
+	Decay      float64  `toml:"decay,omitempty"`
+	Threshold  float64  `toml:"threshold,omitempty"`
+	Backoff    Duration `toml:"backoff,omitempty"`
+	MaxBackoff Duration `toml:"max_backoff,omitempty"`
+}
+
+// Duration is a time.Duration which is specified in configuration files in the
+// format accepted by time.ParseDuration.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	x, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(x)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config returns the synchronization configuration specified by c. The local
+// loop defaults to the median aggregator, the global loop to the FTM
+// aggregator. The remaining fields of the result are left zero and the result
+// is not validated.
+func (c FileConfig) Config() (Config, error) {
+	local, err := c.LocalSync.loopConfig(MedianAggregator{})
+	if err != nil {
+		return Config{}, err
+	}
+	global, err := c.GlobalSync.loopConfig(FTMAggregator{})
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{
+		Local:  local,
+		Global: global,
+		Step: StepPolicy{
+			SlewOnly:       c.StepPolicy.SlewOnly,
+			MaxSteps:       c.StepPolicy.MaxSteps,
+			StepUpdates:    c.StepPolicy.StepUpdates,
+			ForwardOnly:    c.StepPolicy.ForwardOnly,
+			PanicThreshold: time.Duration(c.StepPolicy.PanicThreshold),
+		},
+		Reputation: ReputationConfig{
+			Decay:      c.Reputation.Decay,
+			Threshold:  c.Reputation.Threshold,
+			Backoff:    time.Duration(c.Reputation.Backoff),
+			MaxBackoff: time.Duration(c.Reputation.MaxBackoff),
+		},
+	}, nil
+}
+
+func (c LoopFileConfig) loopConfig(def Aggregator) (LoopConfig, error) {
+	agg, err := NewAggregator(c.Aggregation, def)
+	if err != nil {
+		return LoopConfig{}, err
+	}
+	return LoopConfig{
+		Aggregator: agg,
+		Impact:     c.Impact,
+		Cutoff:     time.Duration(c.Cutoff),
+		Timeout:    time.Duration(c.Timeout),
+		Interval:   time.Duration(c.Interval),
+		Discipline: c.Discipline,
+		PLL: PLLConfig{
+			StepThreshold: time.Duration(c.PLL.StepThreshold),
+			PInit:         c.PLL.PInit,
+			PIRatio:       c.PLL.PIRatio,
+			CaptureTime:   time.Duration(c.PLL.CaptureTime),
+			StiffenRate:   c.PLL.StiffenRate,
+			PLimit:        c.PLL.PLimit,
+			LowP:          c.PLL.LowP,
+			LowI:          c.PLL.LowI,
+			MidP:          c.PLL.MidP,
+			MidI:          c.PLL.MidI,
+			MaxSlewRate:   c.PLL.MaxSlewRate,
+		},
+		Kalman: KalmanConfig{
+			StepThreshold:    time.Duration(c.Kalman.StepThreshold),
+			PhaseNoise:       c.Kalman.PhaseNoise,
+			FrequencyNoise:   c.Kalman.FrequencyNoise,
+			MeasurementNoise: time.Duration(c.Kalman.MeasurementNoise),
+			MaxSlewRate:      c.Kalman.MaxSlewRate,
+			MaxFrequency:     c.Kalman.MaxFrequency,
+		},
+	}, nil
+}
@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/timebase"
+
+	"example.com/scion-time/core/client"
+)
+
+// A discipline steers the local clock towards the measured clock offsets.
+// Do corrects the local clock for the measurement m. The synchronization loop
+// may limit the offset to be corrected to less than m.Offset. This limit
+// applies to phase corrections only, whether to step the local clock is
+// decided on m.Offset. Drift returns the current frequency correction, and
+// SetDrift applies a previously estimated one as the starting frequency.
+type discipline interface {
+	Do(offset time.Duration, m client.Measurement)
+	Holdover() float64
+	Drift() Drift
+	SetDrift(d Drift)
+}
+
+func newDiscipline(log *zap.Logger, clk timebase.LocalClock, cfg LoopConfig,
+	steps *stepper) discipline {
+	if cfg.Discipline == DisciplineKalman {
+		return newKalman(log, clk, cfg.Kalman, steps)
+	}
+	return newPLL(log, clk, cfg.PLL, steps)
+}
@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	driftFileInterval = 1 * time.Hour
+
+	maxDriftFrequency = 500e-6
+)
+
+// Drift is the frequency correction applied to the local clock, in s/s, and
+// the uncertainty of its estimate.
+type Drift struct {
+	Frequency   float64
+	Uncertainty float64
+}
+
+// stabilityClock is implemented by local clocks whose maximum drift depends on
+// the frequency stability measured by the clock discipline.
+type stabilityClock interface {
+	SetStability(stability float64)
+}
+
+var errInvalidDriftFile = errors.New("invalid drift file")
+
+// ReadDriftFile reads the drift stored in the file name. Like chrony's
+// driftfile, the file contains the frequency and its uncertainty in ppm.
+func ReadDriftFile(name string) (Drift, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return Drift{}, err
+	}
+	fs := strings.Fields(string(b))
+	if len(fs) != 2 {
+		return Drift{}, errInvalidDriftFile
+	}
+	freq, err := strconv.ParseFloat(fs[0], 64)
+	if err != nil {
+		return Drift{}, errInvalidDriftFile
+	}
+	unc, err := strconv.ParseFloat(fs[1], 64)
+	if err != nil {
+		return Drift{}, errInvalidDriftFile
+	}
+	d := Drift{Frequency: freq * 1e-6, Uncertainty: unc * 1e-6}
+	if math.IsNaN(d.Frequency) || math.Abs(d.Frequency) > maxDriftFrequency ||
+		math.IsNaN(d.Uncertainty) || math.IsInf(d.Uncertainty, 0) || d.Uncertainty < 0 {
+		return Drift{}, errInvalidDriftFile
+	}
+	return d, nil
+}
+
+// WriteDriftFile atomically replaces the file name with drift d.
+func WriteDriftFile(name string, d Drift) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%.6f %.6f\n", d.Frequency*1e6, d.Uncertainty*1e6)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	err = os.Rename(f.Name(), name)
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// ownsDrift reports whether the local (or else the global) synchronization
+// loop maintains the frequency estimate, i.e., the drift file and the measured
+// stability of the local clock. This is the local loop if there are any
+// reference clocks.
+func (c *clocks) ownsDrift(local bool) bool {
+	return local == (len(c.refClks) != 0)
+}
+
+func (c *clocks) restoreDrift(log *zap.Logger, dsc discipline) {
+	d, err := ReadDriftFile(c.cfg.DriftFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Info("failed to read drift file",
+				zap.String("file", c.cfg.DriftFile), zap.Error(err))
+		}
+		return
+	}
+	dsc.SetDrift(d)
+	c.drift.Store(&d)
+	log.Info("restored clock frequency from drift file",
+		zap.String("file", c.cfg.DriftFile),
+		zap.Float64("frequency", d.Frequency),
+		zap.Float64("uncertainty", d.Uncertainty))
+}
+
+func (c *clocks) saveDrift(log *zap.Logger) {
+	d := c.drift.Load()
+	if d == nil {
+		return
+	}
+	err := WriteDriftFile(c.cfg.DriftFile, *d)
+	if err != nil {
+		log.Info("failed to write drift file",
+			zap.String("file", c.cfg.DriftFile), zap.Error(err))
+	}
+}
+
+// SaveDrift writes the current frequency estimate to the configured drift
+// file, e.g., on shutdown.
+func SaveDrift(ctx context.Context, log *zap.Logger) {
+	c := lookupClocks(ctx)
+	if c == nil || c.cfg.DriftFile == "" {
+		return
+	}
+	c.saveDrift(log)
+}
@@ -0,0 +1,21 @@
+package sync
+
+import (
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/timebase"
+)
+
+var SelectSources = selectSources
+
+func NewReputation(cfg ReputationConfig) *reputation {
+	return newReputation("test", cfg.withDefaults())
+}
+
+func NewKalman(clk timebase.LocalClock, cfg KalmanConfig) *kalman {
+	return newKalman(zap.NewNop(), clk, cfg, &stepper{})
+}
+
+func NewPLL(clk timebase.LocalClock, cfg PLLConfig) *pll {
+	return newPLL(zap.NewNop(), clk, cfg, &stepper{})
+}
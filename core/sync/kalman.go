@@ -0,0 +1,244 @@
+package sync
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+)
+
+// KalmanConfig configures the Kalman filter disciplining the local clock. The
+// filter tracks the phase offset and the frequency error of the local clock.
+// PhaseNoise and FrequencyNoise are the spectral densities of the white phase
+// noise, in s²/s, and of the random walk frequency noise, in s²/s³, of the
+// local clock relative to the reference. The measurement noise of a sample is
+// half its round trip delay, i.e., half the spread between the lo and hi
+// offset estimates, but at least MeasurementNoise. The loop steps the clock if
+// the initial offset exceeds StepThreshold. Phase corrections are limited to
+// MaxSlewRate and frequency corrections to MaxFrequency. Zero fields select
+// the default values.
+type KalmanConfig struct {
+	StepThreshold    time.Duration
+	PhaseNoise       float64
+	FrequencyNoise   float64
+	MeasurementNoise time.Duration
+	MaxSlewRate      float64
+	MaxFrequency     float64
+}
+
+type kalman struct {
+	log   *zap.Logger
+	clk   timebase.LocalClock
+	cfg   KalmanConfig
+	steps *stepper
+	hold  bool
+	init  bool
+	epoch uint64
+	t     time.Time
+	x     [2]float64    // phase offset (s), frequency error (s/s)
+	p     [2][2]float64 // estimate covariance
+	freq  float64
+	unc   float64
+}
+
+var (
+	errInvalidKalmanStepThreshold = errors.New("invalid Kalman filter step threshold")
+	errInvalidKalmanNoise         = errors.New("invalid Kalman filter noise")
+	errInvalidKalmanMaxSlewRate   = errors.New("invalid Kalman filter max slew rate")
+	errInvalidKalmanMaxFrequency  = errors.New("invalid Kalman filter max frequency")
+)
+
+// DefaultKalmanConfig returns the default Kalman filter configuration.
+func DefaultKalmanConfig() KalmanConfig {
+	return KalmanConfig{
+		StepThreshold:    1 * time.Millisecond,
+		PhaseNoise:       1e-12,
+		FrequencyNoise:   1e-16,
+		MeasurementNoise: 1 * time.Microsecond,
+		MaxSlewRate:      500e-6,
+		MaxFrequency:     500e-6,
+	}
+}
+
+func (c KalmanConfig) withDefaults() KalmanConfig {
+	d := DefaultKalmanConfig()
+	if c.StepThreshold == 0 {
+		c.StepThreshold = d.StepThreshold
+	}
+	if c.PhaseNoise == 0 {
+		c.PhaseNoise = d.PhaseNoise
+	}
+	if c.FrequencyNoise == 0 {
+		c.FrequencyNoise = d.FrequencyNoise
+	}
+	if c.MeasurementNoise == 0 {
+		c.MeasurementNoise = d.MeasurementNoise
+	}
+	if c.MaxSlewRate == 0 {
+		c.MaxSlewRate = d.MaxSlewRate
+	}
+	if c.MaxFrequency == 0 {
+		c.MaxFrequency = d.MaxFrequency
+	}
+	return c
+}
+
+func (c KalmanConfig) validate() error {
+	if c.StepThreshold < 0 {
+		return errInvalidKalmanStepThreshold
+	}
+	if c.PhaseNoise < 0 || c.FrequencyNoise < 0 || c.MeasurementNoise <= 0 {
+		return errInvalidKalmanNoise
+	}
+	if c.MaxSlewRate <= 0 {
+		return errInvalidKalmanMaxSlewRate
+	}
+	if c.MaxFrequency <= 0 {
+		return errInvalidKalmanMaxFrequency
+	}
+	return nil
+}
+
+func newKalman(log *zap.Logger, clk timebase.LocalClock, cfg KalmanConfig, steps *stepper) *kalman {
+	return &kalman{log: log, clk: clk, cfg: cfg.withDefaults(), steps: steps}
+}
+
+// Holdover stops all phase corrections and keeps the local clock running at
+// the estimated frequency. The next call to Do continues with the prediction
+// over the holdover period, unless the offset then exceeds the step threshold,
+// in which case the filter restarts with the frequency estimate retained.
+func (k *kalman) Holdover() float64 {
+	if !k.hold {
+		k.hold = true
+		if k.init {
+			k.clk.Adjust(0, 0, k.freq)
+		}
+		k.log.Debug("Kalman filter holdover", zap.Float64("freq", k.freq))
+	}
+	return k.freq
+}
+
+// Drift returns the estimated frequency and its standard deviation.
+func (k *kalman) Drift() Drift {
+	if !k.init {
+		return Drift{Frequency: k.freq, Uncertainty: k.unc}
+	}
+	return Drift{Frequency: k.freq, Uncertainty: math.Sqrt(k.p[1][1])}
+}
+
+func (k *kalman) SetDrift(d Drift) {
+	k.freq = math.Max(-k.cfg.MaxFrequency, math.Min(d.Frequency, k.cfg.MaxFrequency))
+	k.unc = d.Uncertainty
+	k.clk.Adjust(0, 0, k.freq)
+}
+
+func (k *kalman) Do(offset time.Duration, m client.Measurement) {
+	resume := k.hold
+	k.hold = false
+	if k.epoch != k.clk.Epoch() {
+		k.epoch = k.clk.Epoch()
+		k.init = false
+	}
+	if resume && k.init && timemath.Abs(m.Offset) > k.cfg.StepThreshold {
+		k.unc = math.Sqrt(k.p[1][1])
+		k.init = false
+	}
+	now := k.clk.Now()
+	// The filter tracks the measured offset, the loop's limit on offset only
+	// applies to the phase correction.
+	z := timemath.Seconds(m.Offset)
+	sigma := timemath.Seconds(timemath.Abs(m.Delay) / 2)
+	if s := timemath.Seconds(k.cfg.MeasurementNoise); sigma < s {
+		sigma = s
+	}
+	r := sigma * sigma
+	if !k.init {
+		if m.Weight > 3 && timemath.Abs(m.Offset) > k.cfg.StepThreshold &&
+			k.steps.allowStep(k.log, m.Offset) {
+			k.clk.Step(m.Offset)
+			z = 0.0
+		}
+		k.x = [2]float64{z, 0.0}
+		u := k.cfg.MaxFrequency
+		if k.unc != 0.0 {
+			u = k.unc
+		}
+		k.p = [2][2]float64{{r, 0.0}, {0.0, u * u}}
+		k.t = now
+		k.init = true
+		k.log.Debug("Kalman filter initialization",
+			zap.Float64("offset", z),
+			zap.Float64("sigma", sigma),
+		)
+		return
+	}
+
+	dt := timemath.Seconds(now.Sub(k.t))
+	if dt < 0.0 {
+		panic("unexpected clock behavior")
+	}
+
+	// predict
+	q0, q1 := k.cfg.PhaseNoise, k.cfg.FrequencyNoise
+	k.x[0] += k.x[1] * dt
+	p00 := k.p[0][0] + dt*(k.p[1][0]+k.p[0][1]) + dt*dt*k.p[1][1] +
+		q0*dt + q1*dt*dt*dt/3
+	p01 := k.p[0][1] + dt*k.p[1][1] + q1*dt*dt/2
+	p10 := k.p[1][0] + dt*k.p[1][1] + q1*dt*dt/2
+	p11 := k.p[1][1] + q1*dt
+	k.p = [2][2]float64{{p00, p01}, {p10, p11}}
+
+	// update
+	y := z - k.x[0]
+	s := k.p[0][0] + r
+	g0, g1 := k.p[0][0]/s, k.p[1][0]/s
+	k.x[0] += g0 * y
+	k.x[1] += g1 * y
+	k.p = [2][2]float64{
+		{(1 - g0) * k.p[0][0], (1 - g0) * k.p[0][1]},
+		{k.p[1][0] - g1*k.p[0][0], k.p[1][1] - g1*k.p[0][1]},
+	}
+
+	// correct
+	d := math.Ceil(dt)
+	p := k.x[0]
+	if l := timemath.Seconds(timemath.Abs(offset)); math.Abs(p) > l {
+		p = math.Copysign(l, p)
+	}
+	if p > d*k.cfg.MaxSlewRate {
+		p = d * k.cfg.MaxSlewRate
+	}
+	if p < d*-k.cfg.MaxSlewRate {
+		p = d * -k.cfg.MaxSlewRate
+	}
+	f := k.freq + k.x[1]
+	if f > k.cfg.MaxFrequency {
+		f = k.cfg.MaxFrequency
+	}
+	if f < -k.cfg.MaxFrequency {
+		f = -k.cfg.MaxFrequency
+	}
+	k.x[0] -= p
+	k.x[1] -= f - k.freq
+	k.freq = f
+	k.t = now
+	k.log.Debug("Kalman filter iteration",
+		zap.Float64("dt", dt),
+		zap.Float64("offset", z),
+		zap.Float64("sigma", sigma),
+		zap.Float64("innovation", y),
+		zap.Float64("p", p),
+		zap.Float64("d", d),
+		zap.Float64("freq", k.freq),
+		zap.Float64("var", k.p[0][0]),
+	)
+	if d > 0.0 {
+		k.clk.Adjust(timemath.Duration(p), timemath.Duration(d), k.freq)
+	}
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/leap"
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/timebase"
+)
+
+const (
+	leapGuard = 10 * time.Second
+
+	leapUnknown = 0xff
+)
+
+// leapClock is implemented by local clocks which can insert or delete leap
+// seconds at the end of the current UTC day.
+type leapClock interface {
+	SetLeap(ind leap.Indicator)
+}
+
+// leapVote returns the leap second announced by the majority of ms, not
+// counting the local clock.
+func leapVote(ms []client.Measurement) leap.Indicator {
+	var n, ins, del int
+	for i := 0; i != len(ms); i++ {
+		if ms[i].Source == localRefClkSource {
+			continue
+		}
+		n++
+		switch ms[i].Leap {
+		case leap.Insert:
+			ins++
+		case leap.Delete:
+			del++
+		}
+	}
+	if 2*ins > n {
+		return leap.Insert
+	}
+	if 2*del > n {
+		return leap.Delete
+	}
+	return leap.None
+}
+
+// pendingLeap returns the leap second pending at the end of the current UTC
+// month. A valid leap seconds table takes precedence over the vote of the
+// reference or network clocks.
+func (c *clocks) pendingLeap(now time.Time, vote leap.Indicator) leap.Indicator {
+	t := c.cfg.LeapSeconds
+	if t != nil && !t.Expired(now) {
+		return t.Pending(now)
+	}
+	return vote
+}
+
+// nearLeap reports whether now is too close to a leap second handled by the
+// local clock to apply clock corrections.
+func (c *clocks) nearLeap(now time.Time) bool {
+	at := c.leapAt.Load()
+	return at != 0 && timemath.Abs(now.Sub(time.Unix(0, at))) < leapGuard
+}
+
+// updateLeap arms the local clock to handle the pending leap second on the
+// last day of the month and disarms it otherwise.
+func updateLeap(ctx context.Context, log *zap.Logger) {
+	c := getClocks(ctx)
+	lclk := timebase.Clock(ctx)
+	now := lclk.Now()
+	t := c.cfg.LeapSeconds
+	if t != nil && t.Expired(now) && !c.leapTableExpired.Swap(true) {
+		log.Info("leap seconds table expired", zap.Time("expires", t.Expires))
+	}
+	s, _ := GetStatus(ctx)
+	ind := leap.None
+	if leap.LastDay(now) {
+		ind = s.Leap
+	}
+	if c.kernelLeap.Swap(uint32(ind)) == uint32(ind) {
+		return
+	}
+	if ind != leap.None {
+		at := leap.MonthEnd(now)
+		c.leapAt.Store(at.UnixNano())
+		log.Info("leap second pending", zap.Stringer("leap", ind), zap.Time("at", at))
+	}
+	lc, ok := lclk.(leapClock)
+	if ok {
+		lc.SetLeap(ind)
+	}
+}
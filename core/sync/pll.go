@@ -3,6 +3,7 @@ package sync
 // Based on Ntimed by Poul-Henning Kamp, https://github.com/bsdphk/Ntimed
 
 import (
+	"errors"
 	"math"
 	"time"
 
@@ -10,27 +11,187 @@ import (
 
 	"example.com/scion-time/base/timebase"
 	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
 )
 
+// pllDriftSamples is the number of frequency estimates over which the PLL
+// averages the variance of its frequency estimate.
+const pllDriftSamples = 32
+
+// PLLConfig configures the phase-locked loop disciplining the local clock. The
+// loop steps the clock if the initial offset exceeds StepThreshold. It then
+// tracks the offset with the proportional gain PInit and the integral gain
+// PInit/PIRatio. After CaptureTime, both gains decay by the factor StiffenRate
+// per second until the proportional gain reaches PLimit. Measurements with
+// weights below 50 and 150 use the fixed gains LowP, LowI and MidP, MidI
+// respectively. Phase corrections are limited to MaxSlewRate. Zero fields
+// select the default values.
+type PLLConfig struct {
+	StepThreshold time.Duration
+	PInit         float64
+	PIRatio       float64
+	CaptureTime   time.Duration
+	StiffenRate   float64
+	PLimit        float64
+	LowP, LowI    float64
+	MidP, MidI    float64
+	MaxSlewRate   float64
+}
+
 type pll struct {
 	log     *zap.Logger
 	clk     timebase.LocalClock
+	cfg     PLLConfig
+	steps   *stepper
+	hold    bool
 	epoch   uint64
 	mode    uint64
 	t0, t   time.Time
 	a, b, i float64
+	fm, fv  float64
+}
+
+var (
+	errInvalidPLLStepThreshold = errors.New("invalid PLL step threshold")
+	errInvalidPLLGain          = errors.New("invalid PLL gain")
+	errInvalidPLLCaptureTime   = errors.New("invalid PLL capture time")
+	errInvalidPLLStiffenRate   = errors.New("invalid PLL stiffen rate")
+	errInvalidPLLMaxSlewRate   = errors.New("invalid PLL max slew rate")
+)
+
+// DefaultPLLConfig returns the default PLL configuration.
+func DefaultPLLConfig() PLLConfig {
+	return PLLConfig{
+		StepThreshold: 1 * time.Millisecond,
+		PInit:         0.33, // initial proportional term
+		PIRatio:       60,   // initial p/i ratio
+		CaptureTime:   300 * time.Second,
+		StiffenRate:   0.999,
+		PLimit:        0.03,
+		LowP:          3e-2,
+		LowI:          5e-4,
+		MidP:          6e-2,
+		MidI:          1e-3,
+		MaxSlewRate:   500e-6,
+	}
+}
+
+func (c PLLConfig) withDefaults() PLLConfig {
+	d := DefaultPLLConfig()
+	if c.StepThreshold == 0 {
+		c.StepThreshold = d.StepThreshold
+	}
+	if c.PInit == 0 {
+		c.PInit = d.PInit
+	}
+	if c.PIRatio == 0 {
+		c.PIRatio = d.PIRatio
+	}
+	if c.CaptureTime == 0 {
+		c.CaptureTime = d.CaptureTime
+	}
+	if c.StiffenRate == 0 {
+		c.StiffenRate = d.StiffenRate
+	}
+	if c.PLimit == 0 {
+		c.PLimit = d.PLimit
+	}
+	if c.LowP == 0 {
+		c.LowP = d.LowP
+	}
+	if c.LowI == 0 {
+		c.LowI = d.LowI
+	}
+	if c.MidP == 0 {
+		c.MidP = d.MidP
+	}
+	if c.MidI == 0 {
+		c.MidI = d.MidI
+	}
+	if c.MaxSlewRate == 0 {
+		c.MaxSlewRate = d.MaxSlewRate
+	}
+	return c
 }
 
-func newPLL(log *zap.Logger, clk timebase.LocalClock) *pll {
-	return &pll{log: log, clk: clk}
+func (c PLLConfig) validate() error {
+	if c.StepThreshold < 0 {
+		return errInvalidPLLStepThreshold
+	}
+	if c.PInit <= 0 || c.PIRatio <= 0 || c.PLimit <= 0 ||
+		c.LowP <= 0 || c.LowI < 0 || c.MidP <= 0 || c.MidI < 0 {
+		return errInvalidPLLGain
+	}
+	if c.CaptureTime < 0 {
+		return errInvalidPLLCaptureTime
+	}
+	if c.StiffenRate <= 0 || c.StiffenRate > 1 {
+		return errInvalidPLLStiffenRate
+	}
+	if c.MaxSlewRate <= 0 {
+		return errInvalidPLLMaxSlewRate
+	}
+	return nil
 }
 
-func (l *pll) Do(offset time.Duration, weight float64) {
+func newPLL(log *zap.Logger, clk timebase.LocalClock, cfg PLLConfig, steps *stepper) *pll {
+	return &pll{log: log, clk: clk, cfg: cfg.withDefaults(), steps: steps}
+}
+
+// Holdover stops all phase corrections and keeps the local clock running at
+// the frequency estimated by the integral term. If the loop was tracking, the
+// next call to Do resumes tracking with the frequency estimate and the gains
+// retained, unless the offset then exceeds the step threshold, in which case
+// the loop restarts. Otherwise, the loop restarts in any case.
+func (l *pll) Holdover() float64 {
+	if !l.hold {
+		l.hold = true
+		if l.mode == 3 {
+			l.clk.Adjust(0, 0, l.i)
+		} else {
+			l.mode = 0
+		}
+		l.log.Debug("PLL holdover", zap.Float64("l.i", l.i))
+	}
+	return l.i
+}
+
+// Drift returns the frequency estimated by the integral term. Its uncertainty
+// is the standard deviation of the frequency estimate, tracked as an
+// exponentially weighted moving variance over the last pllDriftSamples
+// integral updates.
+func (l *pll) Drift() Drift {
+	return Drift{Frequency: l.i, Uncertainty: math.Sqrt(l.fv)}
+}
+
+func (l *pll) SetDrift(d Drift) {
+	l.i = d.Frequency
+	l.fm = d.Frequency
+	l.fv = d.Uncertainty * d.Uncertainty
+	l.clk.Adjust(0, 0, l.i)
+}
+
+func (l *pll) updateDrift() {
+	const alpha = 1.0 / pllDriftSamples
+	diff := l.i - l.fm
+	incr := alpha * diff
+	l.fm += incr
+	l.fv = (1 - alpha) * (l.fv + diff*incr)
+}
+
+func (l *pll) Do(offset time.Duration, m client.Measurement) {
 	offset = timemath.Inv(offset)
+	weight := m.Weight
+	resume := l.hold
+	l.hold = false
 	if l.epoch != l.clk.Epoch() {
 		l.epoch = l.clk.Epoch()
 		l.mode = 0
 	}
+	if resume && l.mode == 3 && timemath.Abs(m.Offset) > l.cfg.StepThreshold {
+		l.mode = 0
+	}
 	var dt, p, d, a, b float64
 	now := l.clk.Now()
 	switch l.mode {
@@ -43,8 +204,9 @@ func (l *pll) Do(offset time.Duration, weight float64) {
 			panic("unexpected clock behavior")
 		}
 		if mdt > 2*time.Second && weight > 3 {
-			if timemath.Abs(offset) > 1*time.Millisecond {
-				l.clk.Step(timemath.Inv(offset))
+			if timemath.Abs(m.Offset) > l.cfg.StepThreshold &&
+				l.steps.allowStep(l.log, m.Offset) {
+				l.clk.Step(m.Offset)
 			}
 			l.t0 = now
 			l.mode++
@@ -55,12 +217,8 @@ func (l *pll) Do(offset time.Duration, weight float64) {
 			panic("unexpected clock behavior")
 		}
 		if mdt > 6*time.Second {
-			const (
-				pInit = 0.33 // initial proportional term
-				iInit = 60   // initial p/i ratio
-			)
-			l.a = pInit
-			l.b = l.a / iInit
+			l.a = l.cfg.PInit
+			l.b = l.a / l.cfg.PIRatio
 			l.t0 = now
 			l.mode++
 		}
@@ -74,20 +232,16 @@ func (l *pll) Do(offset time.Duration, weight float64) {
 			panic("unexpected clock behavior")
 		}
 		if weight < 50 {
-			a = 3e-2
-			b = 5e-4
+			a = l.cfg.LowP
+			b = l.cfg.LowI
 		} else if weight < 150 {
-			a = 6e-2
-			b = 1e-3
+			a = l.cfg.MidP
+			b = l.cfg.MidI
 		} else {
-			const (
-				captureTime = 300 * time.Second
-				stiffenRate = 0.999
-				pLimit      = 0.03
-			)
-			if mdt > captureTime && l.a > pLimit {
-				l.a *= math.Pow(stiffenRate, dt)
-				l.b *= math.Pow(stiffenRate, dt)
+			// The gains do not stiffen over the time spent in holdover.
+			if !resume && mdt > l.cfg.CaptureTime && l.a > l.cfg.PLimit {
+				l.a *= math.Pow(l.cfg.StiffenRate, dt)
+				l.b *= math.Pow(l.cfg.StiffenRate, dt)
 			}
 			a = l.a
 			b = l.b
@@ -95,11 +249,12 @@ func (l *pll) Do(offset time.Duration, weight float64) {
 		p = timemath.Seconds(timemath.Inv(offset)) * a
 		d = math.Ceil(dt)
 		l.i += p * b
-		if p > d*500e-6 {
-			p = d * 500e-6
+		l.updateDrift()
+		if p > d*l.cfg.MaxSlewRate {
+			p = d * l.cfg.MaxSlewRate
 		}
-		if p < d*-500e-6 {
-			p = d * -500e-6
+		if p < d*-l.cfg.MaxSlewRate {
+			p = d * -l.cfg.MaxSlewRate
 		}
 	default:
 		panic("unexpected PLL mode")
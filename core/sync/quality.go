@@ -0,0 +1,32 @@
+package sync
+
+import (
+	"context"
+
+	"example.com/scion-time/base/timebase"
+)
+
+// syncQuality returns the synchronization quality of the local clock in state
+// s. The maximum error is the root distance, i.e., half the root delay plus
+// the root dispersion, see RFC 5905, Section 11.2.
+func syncQuality(s Status) timebase.SyncQuality {
+	if !s.Synchronized {
+		return timebase.SyncQuality{}
+	}
+	return timebase.SyncQuality{
+		Synchronized: true,
+		MaxError:     s.RootDelay/2 + s.RootDispersion,
+		EstError:     s.EstimatedError,
+	}
+}
+
+// reportSyncQuality passes the current synchronization quality to lclk if it
+// supports reporting it.
+func reportSyncQuality(ctx context.Context, lclk timebase.LocalClock) {
+	qc, ok := lclk.(timebase.SyncQualityClock)
+	if !ok {
+		return
+	}
+	s, _ := GetStatus(ctx)
+	qc.SetSyncQuality(syncQuality(s))
+}
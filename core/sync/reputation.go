@@ -0,0 +1,231 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/metrics"
+
+	"example.com/scion-time/core/timebase"
+)
+
+const (
+	falsetickerPenalty = 1.0
+	unreachablePenalty = 0.1
+)
+
+// ReputationConfig configures the long-term reputation of reference clocks.
+// Every round, the score of a source decays by the factor Decay and grows by
+// one if the source was a falseticker, or by a tenth if it was unreachable. A
+// source whose score reaches Threshold is quarantined for Backoff, doubled for
+// each repeated quarantine up to MaxBackoff. Zero fields select the default
+// values.
+type ReputationConfig struct {
+	Decay      float64
+	Threshold  float64
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// SourceStatus describes the reputation of a reference clock of the local or
+// global synchronization loop. State and Reason refer to the last round, in
+// which the source may have been excluded anyway if it is Quarantined.
+// Reachability is the exponentially weighted fraction of rounds in which the
+// source was reachable, and MeanError the weighted mean distance of its
+// offsets from the intersection of a majority of sources in the rounds in
+// which it was a falseticker.
+type SourceStatus struct {
+	Loop              string
+	Source            string
+	State             string
+	Reason            string
+	Rounds            int
+	FalsetickerRounds int
+	UnreachableRounds int
+	Reachability      float64
+	MeanError         time.Duration
+	Score             float64
+	Quarantined       bool
+	Quarantines       int
+	QuarantinedUntil  time.Time
+}
+
+type reputation struct {
+	cfg  ReputationConfig
+	loop string
+	mu   sync.Mutex
+	srcs map[string]*SourceStatus
+}
+
+var (
+	errInvalidReputationDecay     = errors.New("invalid reputation decay")
+	errInvalidReputationThreshold = errors.New("invalid reputation threshold")
+	errInvalidReputationBackoff   = errors.New("invalid reputation backoff")
+
+	sourceScores = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metrics.SyncSourceScoreN,
+		Help: metrics.SyncSourceScoreH,
+	}, []string{"loop", "source"})
+	sourceQuarantined = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metrics.SyncSourceQuarantinedN,
+		Help: metrics.SyncSourceQuarantinedH,
+	}, []string{"loop", "source"})
+	sourceQuarantines = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: metrics.SyncSourceQuarantinesN,
+		Help: metrics.SyncSourceQuarantinesH,
+	}, []string{"loop", "source"})
+)
+
+// DefaultReputationConfig returns the default reputation configuration.
+func DefaultReputationConfig() ReputationConfig {
+	return ReputationConfig{
+		Decay:      0.95,
+		Threshold:  5.0,
+		Backoff:    10 * time.Minute,
+		MaxBackoff: 24 * time.Hour,
+	}
+}
+
+func (c ReputationConfig) withDefaults() ReputationConfig {
+	d := DefaultReputationConfig()
+	if c.Decay == 0 {
+		c.Decay = d.Decay
+	}
+	if c.Threshold == 0 {
+		c.Threshold = d.Threshold
+	}
+	if c.Backoff == 0 {
+		c.Backoff = d.Backoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = d.MaxBackoff
+	}
+	return c
+}
+
+func (c ReputationConfig) validate() error {
+	if c.Decay <= 0 || c.Decay >= 1 {
+		return errInvalidReputationDecay
+	}
+	if c.Threshold <= 0 {
+		return errInvalidReputationThreshold
+	}
+	if c.Backoff <= 0 || c.MaxBackoff < c.Backoff {
+		return errInvalidReputationBackoff
+	}
+	return nil
+}
+
+func newReputation(loop string, cfg ReputationConfig) *reputation {
+	return &reputation{cfg: cfg, loop: loop, srcs: make(map[string]*SourceStatus)}
+}
+
+// quarantined reports whether source is quarantined at time now.
+func (r *reputation) quarantined(source string, now time.Time) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.srcs[source]
+	return ok && now.Before(s.QuarantinedUntil)
+}
+
+// update records the classification ss of the sources in the round at time
+// now and quarantines sources whose score reaches the threshold.
+func (r *reputation) update(log *zap.Logger, now time.Time, ss []SourceState) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, x := range ss {
+		s, ok := r.srcs[x.Source]
+		if !ok {
+			s = &SourceStatus{Loop: r.loop, Source: x.Source, Reachability: 1.0}
+			r.srcs[x.Source] = s
+		}
+		s.State, s.Reason = x.State, x.Reason
+		s.Rounds++
+		s.Score *= r.cfg.Decay
+		s.Reachability *= r.cfg.Decay
+		switch x.State {
+		case SourceFalseticker:
+			s.FalsetickerRounds++
+			s.Score += falsetickerPenalty
+			s.Reachability += 1 - r.cfg.Decay
+			if s.FalsetickerRounds == 1 {
+				s.MeanError = x.Error
+			} else {
+				s.MeanError = time.Duration(r.cfg.Decay*float64(s.MeanError) +
+					(1-r.cfg.Decay)*float64(x.Error))
+			}
+		case SourceUnreachable:
+			s.UnreachableRounds++
+			s.Score += unreachablePenalty
+		default:
+			s.Reachability += 1 - r.cfg.Decay
+		}
+		if s.Score >= r.cfg.Threshold && !now.Before(s.QuarantinedUntil) {
+			backoff := r.cfg.Backoff
+			for i := 0; i != s.Quarantines && backoff < r.cfg.MaxBackoff; i++ {
+				backoff *= 2
+			}
+			if backoff > r.cfg.MaxBackoff {
+				backoff = r.cfg.MaxBackoff
+			}
+			s.Quarantines++
+			s.QuarantinedUntil = now.Add(backoff)
+			log.Info("quarantining source",
+				zap.String("loop", r.loop),
+				zap.String("source", s.Source),
+				zap.Float64("score", s.Score),
+				zap.Int("falseticker rounds", s.FalsetickerRounds),
+				zap.Int("unreachable rounds", s.UnreachableRounds),
+				zap.Duration("mean error", s.MeanError),
+				zap.Duration("backoff", backoff),
+			)
+			sourceQuarantines.WithLabelValues(r.loop, s.Source).Inc()
+		}
+		sourceScores.WithLabelValues(r.loop, s.Source).Set(s.Score)
+		if now.Before(s.QuarantinedUntil) {
+			sourceQuarantined.WithLabelValues(r.loop, s.Source).Set(1)
+		} else {
+			sourceQuarantined.WithLabelValues(r.loop, s.Source).Set(0)
+		}
+	}
+}
+
+func (r *reputation) status(now time.Time) []SourceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ss := make([]SourceStatus, 0, len(r.srcs))
+	for _, s := range r.srcs {
+		x := *s
+		x.Quarantined = now.Before(x.QuarantinedUntil)
+		ss = append(ss, x)
+	}
+	sort.Slice(ss, func(i, j int) bool {
+		return ss[i].Source < ss[j].Source
+	})
+	return ss
+}
+
+// GetSourceStatus returns the reputation of the reference and network clocks
+// associated with ctx, ordered by loop and source.
+func GetSourceStatus(ctx context.Context) []SourceStatus {
+	c := lookupClocks(ctx)
+	if c == nil {
+		return nil
+	}
+	now := timebase.Now(ctx)
+	return append(c.localRep.status(now), c.globalRep.status(now)...)
+}
@@ -0,0 +1,153 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/metrics"
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+)
+
+const (
+	SourceTruechimer  = "truechimer"
+	SourceFalseticker = "falseticker"
+	SourceUnreachable = "unreachable"
+
+	reasonNoResponse          = "no_response"
+	reasonOutsideIntersection = "outside_intersection"
+	reasonNoMajority          = "no_majority"
+	reasonNoDelay             = "no_delay"
+)
+
+// SourceState is the classification of a reference clock in a round of
+// offset measurements. Falsetickers and unreachable sources are excluded from
+// the round, Reason states why.
+// Error is the distance of a falseticker's offset interval from the
+// intersection. Measurements of Quarantined sources are excluded in any case.
+type SourceState struct {
+	Source      string
+	State       string
+	Reason      string
+	Error       time.Duration
+	Quarantined bool
+}
+
+var sourceSelections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: metrics.SyncSourceSelectionsN,
+	Help: metrics.SyncSourceSelectionsH,
+}, []string{"loop", "source", "state", "reason"})
+
+// clockName returns the name of refclk, which becomes the Source of its
+// measurements. Reference clocks are named by implementing fmt.Stringer.
+func clockName(refclk client.ReferenceClock, i int) string {
+	s, ok := refclk.(fmt.Stringer)
+	if ok {
+		return s.String()
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// selectSources classifies the sources of the measurements ms taken in a
+// round from the reference clocks with the given names. Measurements with a
+// round trip delay are falsetickers if their offset intervals do not overlap
+// with the intersection of the intervals of a majority of them. Reference
+// clocks without a measurement are unreachable, and sources quarantined by
+// rep are excluded regardless of their measurements. selectSources moves the
+// measurements of the remaining sources to the front of ms and returns their
+// number together with the classification of all sources.
+func selectSources(log *zap.Logger, loop string, names []string, ms []client.Measurement,
+	rep *reputation, now time.Time) (int, []SourceState) {
+	var cs []client.Measurement
+	var reachable int
+	for i := 0; i != len(ms); i++ {
+		if ms[i].Source != localRefClkSource {
+			reachable++
+			if ms[i].Delay != 0 && !rep.quarantined(ms[i].Source, now) {
+				cs = append(cs, ms[i])
+			}
+		}
+	}
+
+	ss := make([]SourceState, 0, len(names))
+	for _, name := range names {
+		if reachable == len(names) {
+			break
+		}
+		var found bool
+		for i := 0; i != len(ms); i++ {
+			if ms[i].Source == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ss = append(ss, SourceState{
+				Source: name, State: SourceUnreachable, Reason: reasonNoResponse})
+		}
+	}
+
+	lo, hi, n := intersection(cs)
+	majority := 2*n > len(cs)
+
+	k := 0
+	for i := 0; i != len(ms); i++ {
+		m := ms[i]
+		if m.Source == localRefClkSource {
+			ms[k] = m
+			k++
+			continue
+		}
+		s := SourceState{Source: m.Source, State: SourceTruechimer}
+		if m.Delay == 0 {
+			s.Reason = reasonNoDelay
+		} else if !majority {
+			s.Reason = reasonNoMajority
+		} else {
+			r := timemath.Abs(m.Delay) / 2
+			if m.Offset+r < lo {
+				s.Error = lo - (m.Offset + r)
+			} else if m.Offset-r > hi {
+				s.Error = (m.Offset - r) - hi
+			}
+			if s.Error != 0 {
+				s.State = SourceFalseticker
+				s.Reason = reasonOutsideIntersection
+				log.Info("excluding falseticker",
+					zap.String("loop", loop),
+					zap.String("source", m.Source),
+					zap.Duration("offset", m.Offset),
+					zap.Duration("delay", m.Delay),
+					zap.Duration("intersection lo", lo),
+					zap.Duration("intersection hi", hi),
+					zap.Int("agreeing", n),
+					zap.Int("candidates", len(cs)),
+				)
+			}
+		}
+		if rep.quarantined(m.Source, now) {
+			s.Quarantined = true
+		}
+		ss = append(ss, s)
+		if s.State == SourceTruechimer && !s.Quarantined {
+			ms[k] = m
+			k++
+		}
+	}
+
+	for _, s := range ss {
+		if s.State == SourceUnreachable {
+			log.Info("excluding unreachable source",
+				zap.String("loop", loop), zap.String("source", s.Source))
+		}
+		sourceSelections.WithLabelValues(loop, s.Source, s.State, s.Reason).Inc()
+	}
+	rep.update(log, now, ss)
+	return k, ss
+}
@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"example.com/scion-time/base/leap"
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/timebase"
+
+	"example.com/scion-time/net/ntp"
+)
+
+const (
+	maxDispersion  = 16 * time.Second
+	dispersionRate = 15e-6 // see RFC 5905, PHI
+)
+
+// Status describes the synchronization state of the local clock as reported
+// to clients, see RFC 5905, Section 7.3. ReferenceTime is the local time at
+// which the clock was last synchronized. Leap is the leap second pending at
+// the end of the current UTC month. Offset is the clock offset measured at
+// ReferenceTime and EstimatedError its estimated error.
+type Status struct {
+	Synchronized   bool
+	Stratum        uint8
+	ReferenceID    uint32
+	ReferenceTime  time.Time
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	Offset         time.Duration
+	EstimatedError time.Duration
+	Leap           leap.Indicator
+	epoch          uint64
+}
+
+// newStatus returns the synchronization state after the local clock has been
+// synchronized at time now in epoch to the aggregated measurement agg of ms.
+// The measurement closest to agg determines the reference.
+func newStatus(now time.Time, epoch uint64, agg client.Measurement, ms []client.Measurement) *Status {
+	var p *client.Measurement
+	for i := 0; i != len(ms); i++ {
+		if ms[i].Source == localRefClkSource {
+			continue
+		}
+		if p == nil || timemath.Abs(ms[i].Offset-agg.Offset) < timemath.Abs(p.Offset-agg.Offset) {
+			p = &ms[i]
+		}
+	}
+	if p == nil || p.Stratum > ntp.StratumMax {
+		return &Status{}
+	}
+	// Peers which synchronize with each other without any reference clock
+	// derive their strata from each other. Like in NTP's orphan mode, the
+	// stratum then stays at StratumMax instead of counting up to
+	// StratumUnsynchronized.
+	return &Status{
+		Synchronized:   true,
+		Stratum:        min(p.Stratum+1, ntp.StratumMax),
+		ReferenceID:    p.RefID,
+		ReferenceTime:  now,
+		RootDelay:      p.RootDelay + timemath.Abs(p.Delay),
+		RootDispersion: p.RootDispersion + timemath.Abs(p.Offset-agg.Offset),
+		Offset:         agg.Offset,
+		EstimatedError: agg.ErrorBound,
+		Leap:           leapVote(ms),
+		epoch:          epoch,
+	}
+}
+
+// at returns the synchronization state at time now, taking into account the
+// dispersion accumulated since the last synchronization.
+func (s Status) at(now time.Time) Status {
+	if !s.Synchronized {
+		return Status{}
+	}
+	d := now.Sub(s.ReferenceTime)
+	if d > 0 {
+		s.RootDispersion += time.Duration(dispersionRate * float64(d))
+	}
+	if s.RootDispersion > maxDispersion {
+		return Status{}
+	}
+	if !leap.MonthEnd(s.ReferenceTime).Equal(leap.MonthEnd(now)) {
+		s.Leap = leap.None
+	}
+	return s
+}
+
+// setStatus stores the synchronization state s of a synchronization loop in
+// status and records whether the local clock has ever been synchronized.
+func (c *clocks) setStatus(status *atomic.Pointer[Status], s *Status) {
+	status.Store(s)
+	if s.Synchronized {
+		c.synced.Store(true)
+	}
+}
+
+// GetStatus returns the current synchronization state of the local clock
+// associated with ctx. The state based on reference clocks takes precedence
+// over the state based on network clocks. If no reference or network clocks
+// are registered, or if the local clock has not been synchronized yet, ok is
+// false. In the latter case, servers keep answering like a primary server, so
+// that peers which all start without a working reference clock can still
+// synchronize with each other.
+func GetStatus(ctx context.Context) (s Status, ok bool) {
+	c := lookupClocks(ctx)
+	if c == nil || len(c.refClks) == 0 && len(c.netClks) == 0 || !c.synced.Load() {
+		return Status{}, false
+	}
+	now := timebase.Now(ctx)
+	if x := c.localStatus.Load(); x != nil {
+		s = x.at(now)
+	}
+	if !s.Synchronized {
+		if x := c.globalStatus.Load(); x != nil {
+			s = x.at(now)
+		}
+	}
+	s.Leap = c.pendingLeap(now, s.Leap)
+	return s, true
+}
+
+// GetTimeInterval returns an interval [earliest, latest] that contains the
+// true time when it is called, like TrueTime's TT.now(). Its radius is the
+// root distance at the last synchronization of the local clock associated with
+// ctx, plus the offset measured at that time, which the clock discipline may
+// not have corrected yet, plus the maximum drift of the local clock since at
+// the configured IntervalTolerance. If the local clock is not synchronized or
+// has been stepped since, ok is false.
+func GetTimeInterval(ctx context.Context) (earliest, latest time.Time, ok bool) {
+	c := lookupClocks(ctx)
+	if c == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	lclk := timebase.Clock(ctx)
+	epoch := lclk.Epoch()
+	now := lclk.Now()
+	s := c.localStatus.Load()
+	if s == nil || !s.at(now).Synchronized || s.epoch != epoch {
+		s = c.globalStatus.Load()
+	}
+	if s == nil || !s.at(now).Synchronized || s.epoch != epoch {
+		return time.Time{}, time.Time{}, false
+	}
+	var drift time.Duration
+	if d := now.Sub(s.ReferenceTime); d > 0 {
+		drift = time.Duration(c.cfg.IntervalTolerance * float64(d))
+	}
+	if drift > maxDispersion {
+		return time.Time{}, time.Time{}, false
+	}
+	r := s.RootDelay/2 + s.RootDispersion + timemath.Abs(s.Offset) + drift
+	return now.Add(-r), now.Add(r), true
+}
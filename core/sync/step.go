@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/metrics"
+	"example.com/scion-time/base/timemath"
+)
+
+// StepPolicy restricts when the local clock may be stepped. If SlewOnly is
+// set, the clock is never stepped. Otherwise, at most MaxSteps steps are made,
+// and only during the first StepUpdates clock updates (like chrony's makestep
+// directive). If ForwardOnly is set, the clock is never stepped backwards.
+// Offsets that would have been stepped are slewed instead. Corrections of
+// offsets larger than PanicThreshold are refused altogether. Zero fields
+// remove the corresponding restriction.
+type StepPolicy struct {
+	SlewOnly       bool
+	MaxSteps       int
+	StepUpdates    int
+	ForwardOnly    bool
+	PanicThreshold time.Duration
+}
+
+type stepper struct {
+	cfg     StepPolicy
+	mu      sync.Mutex
+	steps   int
+	updates int
+}
+
+var (
+	errInvalidStepPolicy = errors.New("invalid step policy")
+
+	stepsCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: metrics.SyncStepsN,
+		Help: metrics.SyncStepsH,
+	})
+	stepsDeniedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: metrics.SyncStepsDeniedN,
+		Help: metrics.SyncStepsDeniedH,
+	})
+	corrsRefusedCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: metrics.SyncCorrsRefusedN,
+		Help: metrics.SyncCorrsRefusedH,
+	})
+)
+
+func (c StepPolicy) validate() error {
+	if c.MaxSteps < 0 || c.StepUpdates < 0 || c.PanicThreshold < 0 {
+		return errInvalidStepPolicy
+	}
+	return nil
+}
+
+// allowStep reports whether the clock may be stepped by offset and counts the
+// step if so.
+func (s *stepper) allowStep(log *zap.Logger, offset time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var reason string
+	switch {
+	case s.cfg.SlewOnly:
+		reason = "slew-only mode"
+	case s.cfg.MaxSteps != 0 && s.steps >= s.cfg.MaxSteps:
+		reason = "step limit reached"
+	case s.cfg.StepUpdates != 0 && s.updates >= s.cfg.StepUpdates:
+		reason = "step window passed"
+	case s.cfg.ForwardOnly && offset < 0:
+		reason = "backward step"
+	}
+	if reason != "" {
+		log.Info("not stepping clock, slewing instead",
+			zap.Duration("offset", offset), zap.String("reason", reason))
+		stepsDeniedCounter.Inc()
+		return false
+	}
+	s.steps++
+	log.Info("stepping clock", zap.Duration("offset", offset), zap.Int("steps", s.steps))
+	stepsCounter.Inc()
+	return true
+}
+
+// allowCorrection reports whether the clock may be corrected by offset at all.
+func (s *stepper) allowCorrection(log *zap.Logger, offset time.Duration) bool {
+	if s.cfg.PanicThreshold != 0 && timemath.Abs(offset) > s.cfg.PanicThreshold {
+		log.Info("refusing clock correction, offset exceeds panic threshold",
+			zap.Duration("offset", offset),
+			zap.Duration("threshold", s.cfg.PanicThreshold))
+		corrsRefusedCounter.Inc()
+		return false
+	}
+	return true
+}
+
+// update counts a clock update.
+func (s *stepper) update() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates++
+}
@@ -2,6 +2,8 @@ package sync
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,11 +11,12 @@ import (
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
 	"example.com/scion-time/base/metrics"
-	"example.com/scion-time/base/timebase"
 	"example.com/scion-time/base/timemath"
 
 	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/timebase"
 )
 
 const (
@@ -25,127 +28,546 @@ const (
 	netClkCutoff   = time.Microsecond
 	netClkTimeout  = 5 * time.Second
 	netClkInterval = 60 * time.Second
+
+	// intervalTolerance is NTP's maximum frequency tolerance, see RFC 5905,
+	// Section 11.3.
+	intervalTolerance = 500e-6
+)
+
+const localRefClkSource = "local"
+
+const (
+	DisciplinePLL    = "pll"
+	DisciplineKalman = "kalman"
 )
 
 type localReferenceClock struct{}
 
+// LoopConfig configures a clock synchronization loop. Every Interval, the loop
+// measures the clock offsets with the given Timeout and corrects offsets
+// larger than Cutoff, limited to Impact times the maximum drift of the local
+// clock during Interval. Zero fields select the default values. In
+// particular, if Aggregator is nil, the local loop selects the median and the
+// global loop the fault-tolerant midpoint of the measured clock offsets. The
+// Discipline, either DisciplinePLL (default) or DisciplineKalman, selects
+// whether the corrections are applied by a PLL configured by PLL or by a
+// Kalman filter configured by Kalman.
+type LoopConfig struct {
+	Aggregator Aggregator
+	Impact     float64
+	Cutoff     time.Duration
+	Timeout    time.Duration
+	Interval   time.Duration
+	Discipline string
+	PLL        PLLConfig
+	Kalman     KalmanConfig
+}
+
+// Config configures the local synchronization to reference clocks and the
+// global synchronization to network clocks. If LeapSeconds is set and not
+// expired, it determines the pending leap seconds instead of the leap
+// indicators of the reference and network clocks. If DriftFile is set, the
+// estimated frequency correction of the local clock is periodically stored in
+// and restored from this file. Step restricts when the local clock may be
+// stepped, and Reputation configures the quarantine of misbehaving sources.
+// GetTimeInterval bounds the drift of the local clock since its last
+// synchronization by IntervalTolerance, the frequency tolerance of its
+// oscillator, which defaults to 500 ppm. Unlike the maximum drift of the local
+// clock, it does not depend on the frequency stability measured by the clock
+// discipline, which is an estimate rather than a bound.
+type Config struct {
+	Local             LoopConfig
+	Global            LoopConfig
+	LeapSeconds       *leap.Table
+	DriftFile         string
+	Step              StepPolicy
+	Reputation        ReputationConfig
+	IntervalTolerance float64
+}
+
+type clocks struct {
+	cfg          Config
+	refClks      []client.ReferenceClock
+	refClkNames  []string
+	refClkMeas   []client.Measurement
+	refClkClient client.ReferenceClockClient
+	netClks      []client.ReferenceClock
+	netClkNames  []string
+	netClkMeas   []client.Measurement
+	netClkClient client.ReferenceClockClient
+	localStatus  atomic.Pointer[Status]
+	globalStatus atomic.Pointer[Status]
+	synced       atomic.Bool
+
+	kernelLeap       atomic.Uint32
+	leapAt           atomic.Int64
+	leapTableExpired atomic.Bool
+
+	drift atomic.Pointer[Drift]
+	steps stepper
+
+	localRep  *reputation
+	globalRep *reputation
+}
+
+type clocksKey struct{}
+
 var (
-	refClks       []client.ReferenceClock
-	refClkOffsets []time.Duration
-	refClkClient  client.ReferenceClockClient
-	netClks       []client.ReferenceClock
-	netClkOffsets []time.Duration
-	netClkClient  client.ReferenceClockClient
+	errInvalidRefClkImpact   = errors.New("invalid reference clock impact factor")
+	errInvalidRefClkCutoff   = errors.New("invalid reference clock cutoff")
+	errInvalidRefClkInterval = errors.New("invalid reference clock sync interval")
+	errInvalidRefClkTimeout  = errors.New("invalid reference clock sync timeout")
+	errInvalidNetClkImpact   = errors.New("invalid network clock impact factor")
+	errInvalidNetClkCutoff   = errors.New("invalid network clock cutoff")
+	errInvalidNetClkInterval = errors.New("invalid network clock sync interval")
+	errInvalidNetClkTimeout  = errors.New("invalid network clock sync timeout")
+	errUnknownDiscipline     = errors.New("unknown clock discipline")
+	errInvalidTolerance      = errors.New("invalid interval tolerance")
+
+	registeredClocks atomic.Pointer[clocks]
+
+	localCorrGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncLocalCorrN,
+		Help: metrics.SyncLocalCorrH,
+	})
+	globalCorrGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncGlobalCorrN,
+		Help: metrics.SyncGlobalCorrH,
+	})
+	localHoldoverGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncLocalHoldoverN,
+		Help: metrics.SyncLocalHoldoverH,
+	})
+	localHoldoverFreqGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncLocalHoldoverFreqN,
+		Help: metrics.SyncLocalHoldoverFreqH,
+	})
+	localHoldoversCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: metrics.SyncLocalHoldoversN,
+		Help: metrics.SyncLocalHoldoversH,
+	})
+	globalHoldoverGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncGlobalHoldoverN,
+		Help: metrics.SyncGlobalHoldoverH,
+	})
+	globalHoldoverFreqGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: metrics.SyncGlobalHoldoverFreqN,
+		Help: metrics.SyncGlobalHoldoverFreqH,
+	})
+	globalHoldoversCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: metrics.SyncGlobalHoldoversN,
+		Help: metrics.SyncGlobalHoldoversH,
+	})
 )
 
 func (c *localReferenceClock) MeasureClockOffset(context.Context, *zap.Logger) (
-	time.Duration, error) {
-	return 0, nil
+	client.Measurement, error) {
+	return client.Measurement{Source: localRefClkSource, Weight: 1000.0}, nil
 }
 
-func RegisterClocks(refClocks, netClocks []client.ReferenceClock) {
-	if refClks != nil || netClks != nil {
-		panic("reference clocks already registered")
+// DefaultConfig returns the default clock synchronization configuration.
+func DefaultConfig() Config {
+	return Config{
+		Local: LoopConfig{
+			Aggregator: MedianAggregator{},
+			Impact:     refClkImpact,
+			Cutoff:     refClkCutoff,
+			Timeout:    refClkTimeout,
+			Interval:   refClkInterval,
+			Discipline: DisciplinePLL,
+			PLL:        DefaultPLLConfig(),
+			Kalman:     DefaultKalmanConfig(),
+		},
+		Global: LoopConfig{
+			Aggregator: FTMAggregator{},
+			Impact:     netClkImpact,
+			Cutoff:     netClkCutoff,
+			Timeout:    netClkTimeout,
+			Interval:   netClkInterval,
+			Discipline: DisciplinePLL,
+			PLL:        DefaultPLLConfig(),
+			Kalman:     DefaultKalmanConfig(),
+		},
+		IntervalTolerance: intervalTolerance,
 	}
+}
 
-	refClks = refClocks
-	refClkOffsets = make([]time.Duration, len(refClks))
+func (c LoopConfig) withDefaults(d LoopConfig) LoopConfig {
+	if c.Aggregator == nil {
+		c.Aggregator = d.Aggregator
+	}
+	if c.Impact == 0 {
+		c.Impact = d.Impact
+	}
+	if c.Cutoff == 0 {
+		c.Cutoff = d.Cutoff
+	}
+	if c.Timeout == 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.Interval == 0 {
+		c.Interval = d.Interval
+	}
+	if c.Discipline == "" {
+		c.Discipline = d.Discipline
+	}
+	c.PLL = c.PLL.withDefaults()
+	c.Kalman = c.Kalman.withDefaults()
+	return c
+}
 
-	netClks = netClocks
-	if len(netClks) != 0 {
-		netClks = append(netClks, &localReferenceClock{})
+func (c LoopConfig) validateDiscipline() error {
+	if c.Discipline != DisciplinePLL && c.Discipline != DisciplineKalman {
+		return errUnknownDiscipline
+	}
+	err := c.PLL.validate()
+	if err != nil {
+		return err
 	}
-	netClkOffsets = make([]time.Duration, len(netClks))
+	return c.Kalman.validate()
 }
 
-func measureOffsetToRefClocks(log *zap.Logger, timeout time.Duration) time.Duration {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	refClkClient.MeasureClockOffsets(ctx, log, refClks, refClkOffsets)
-	return timemath.Median(refClkOffsets)
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	c.Local = c.Local.withDefaults(d.Local)
+	c.Global = c.Global.withDefaults(d.Global)
+	c.Reputation = c.Reputation.withDefaults()
+	if c.IntervalTolerance == 0 {
+		c.IntervalTolerance = d.IntervalTolerance
+	}
+	return c
 }
 
-func SyncToRefClocks(log *zap.Logger, lclk timebase.LocalClock) {
-	corr := measureOffsetToRefClocks(log, refClkTimeout)
-	if corr != 0 {
-		lclk.Step(corr)
+// Validate checks c after replacing its zero fields with the default values.
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	if c.Local.Impact <= 1.0 {
+		return errInvalidRefClkImpact
+	}
+	if c.Local.Cutoff < 0 {
+		return errInvalidRefClkCutoff
 	}
+	if c.Local.Interval <= 0 {
+		return errInvalidRefClkInterval
+	}
+	if c.Local.Timeout < 0 || c.Local.Timeout > c.Local.Interval/2 {
+		return errInvalidRefClkTimeout
+	}
+	if c.IntervalTolerance < 0 || c.IntervalTolerance >= 1 {
+		return errInvalidTolerance
+	}
+	err := c.Step.validate()
+	if err != nil {
+		return err
+	}
+	err = c.Reputation.validate()
+	if err != nil {
+		return err
+	}
+	err = c.Local.validateDiscipline()
+	if err != nil {
+		return err
+	}
+	if c.Global.Impact <= 1.0 {
+		return errInvalidNetClkImpact
+	}
+	if c.Global.Impact-1.0 <= c.Local.Impact {
+		return errInvalidNetClkImpact
+	}
+	if c.Global.Cutoff < 0 {
+		return errInvalidNetClkCutoff
+	}
+	if c.Global.Interval < c.Local.Interval {
+		return errInvalidNetClkInterval
+	}
+	if c.Global.Timeout < 0 || c.Global.Timeout > c.Global.Interval/2 {
+		return errInvalidNetClkTimeout
+	}
+	return c.Global.validateDiscipline()
 }
 
-func RunLocalClockSync(log *zap.Logger, lclk timebase.LocalClock) {
-	if refClkImpact <= 1.0 {
-		panic("invalid reference clock impact factor")
+func newClocks(refClocks, netClocks []client.ReferenceClock, cfg Config) *clocks {
+	err := cfg.Validate()
+	if err != nil {
+		panic(err)
 	}
-	if refClkInterval <= 0 {
-		panic("invalid reference clock sync interval")
+
+	c := &clocks{}
+	c.cfg = cfg.withDefaults()
+	c.steps.cfg = c.cfg.Step
+	c.localRep = newReputation("local", c.cfg.Reputation)
+	c.globalRep = newReputation("global", c.cfg.Reputation)
+	c.kernelLeap.Store(leapUnknown)
+
+	c.refClks = refClocks
+	c.refClkNames = make([]string, len(c.refClks))
+	for i := 0; i != len(c.refClks); i++ {
+		c.refClkNames[i] = clockName(c.refClks[i], i)
 	}
-	if refClkTimeout < 0 || refClkTimeout > refClkInterval/2 {
-		panic("invalid reference clock sync timeout")
+	c.refClkMeas = make([]client.Measurement, len(c.refClks))
+
+	c.netClks = netClocks
+	if len(c.netClks) != 0 {
+		c.netClks = append(c.netClks, &localReferenceClock{})
 	}
-	maxCorr := refClkImpact * float64(lclk.MaxDrift(refClkInterval))
-	if maxCorr <= 0 {
+	c.netClkNames = make([]string, len(netClocks))
+	for i := 0; i != len(netClocks); i++ {
+		c.netClkNames[i] = clockName(netClocks[i], i)
+	}
+	c.netClkMeas = make([]client.Measurement, len(c.netClks))
+
+	return c
+}
+
+func RegisterClocks(refClocks, netClocks []client.ReferenceClock, cfg Config) {
+	swapped := registeredClocks.CompareAndSwap(nil, newClocks(refClocks, netClocks, cfg))
+	if !swapped {
+		panic("reference clocks already registered")
+	}
+}
+
+// WithClocks returns a copy of ctx in which refClocks and netClocks replace
+// the registered reference clocks.
+func WithClocks(ctx context.Context, refClocks, netClocks []client.ReferenceClock,
+	cfg Config) context.Context {
+	return context.WithValue(ctx, clocksKey{}, newClocks(refClocks, netClocks, cfg))
+}
+
+func lookupClocks(ctx context.Context) *clocks {
+	c, ok := ctx.Value(clocksKey{}).(*clocks)
+	if ok {
+		return c
+	}
+	return registeredClocks.Load()
+}
+
+func getClocks(ctx context.Context) *clocks {
+	c := lookupClocks(ctx)
+	if c == nil {
+		panic("no reference clocks registered")
+	}
+	return c
+}
+
+// measureOffsetToRefClocks returns the aggregated measurement and the number
+// of reference clocks to which the clock offset could be measured.
+func measureOffsetToRefClocks(ctx context.Context, log *zap.Logger) (client.Measurement, int) {
+	c := getClocks(ctx)
+	ctx, cancel := timebase.WithTimeout(ctx, c.cfg.Local.Timeout)
+	defer cancel()
+	n := c.refClkClient.MeasureClockOffsets(ctx, log, c.refClks, c.refClkNames, c.refClkMeas)
+	if n == 0 {
+		log.Info("failed to measure clock offset to any reference clock")
+		return client.Measurement{}, 0
+	}
+	n, _ = selectSources(log, "local", c.refClkNames, c.refClkMeas[:n],
+		c.localRep, timebase.Now(ctx))
+	if n == 0 {
+		log.Info("excluded all reference clocks from synchronization")
+		return client.Measurement{}, 0
+	}
+	m := aggregate(c.cfg.Local.Aggregator, c.refClkMeas[:n])
+	c.setStatus(&c.localStatus,
+		newStatus(timebase.Now(ctx), timebase.Epoch(ctx), m, c.refClkMeas[:n]))
+	return m, n
+}
+
+func SyncToRefClocks(ctx context.Context, log *zap.Logger) {
+	lclk := timebase.Clock(ctx)
+	c := getClocks(ctx)
+	m, _ := measureOffsetToRefClocks(ctx, log)
+	corr := m.Offset
+	if corr != 0 && c.steps.allowCorrection(log, corr) {
+		if c.steps.allowStep(log, corr) {
+			lclk.Step(corr)
+		}
+		c.steps.update()
+	}
+}
+
+func RunLocalClockSync(ctx context.Context, log *zap.Logger) {
+	lclk := timebase.Clock(ctx)
+	c := getClocks(ctx)
+	cfg := c.cfg.Local
+	if lclk.MaxDrift(cfg.Interval) <= 0 {
 		panic("invalid reference clock max correction")
 	}
-	corrGauge := promauto.NewGauge(prometheus.GaugeOpts{
-		Name: metrics.SyncLocalCorrN,
-		Help: metrics.SyncLocalCorrH,
-	})
-	pll := newPLL(log, lclk)
+	dsc := newDiscipline(log, lclk, cfg, &c.steps)
+	ownsDrift := c.ownsDrift(true)
+	if ownsDrift && c.cfg.DriftFile != "" {
+		c.restoreDrift(log, dsc)
+	}
+	driftSaved := lclk.Now()
+	var holdover bool
+	var holdoverStart time.Time
 	for {
-		corrGauge.Set(0)
-		corr := measureOffsetToRefClocks(log, refClkTimeout)
-		if timemath.Abs(corr) > refClkCutoff {
+		localCorrGauge.Set(0)
+		updateLeap(ctx, log)
+		m, n := measureOffsetToRefClocks(ctx, log)
+		reportSyncQuality(ctx, lclk)
+		if n == 0 {
+			if !holdover {
+				holdover = true
+				holdoverStart = lclk.Now()
+				freq := dsc.Holdover()
+				log.Info("entering holdover, lost all reference clocks",
+					zap.Float64("frequency", freq))
+				localHoldoverGauge.Set(1)
+				localHoldoverFreqGauge.Set(freq)
+				localHoldoversCounter.Inc()
+			}
+			lclk.Sleep(cfg.Interval)
+			continue
+		}
+		if holdover {
+			holdover = false
+			log.Info("leaving holdover, re-acquiring reference clocks",
+				zap.Duration("duration", lclk.Now().Sub(holdoverStart)))
+			localHoldoverGauge.Set(0)
+		}
+		if c.nearLeap(lclk.Now()) {
+			log.Debug("skipping clock correction close to leap second")
+			lclk.Sleep(cfg.Interval)
+			continue
+		}
+		corr := m.Offset
+		if timemath.Abs(corr) > cfg.Cutoff && c.steps.allowCorrection(log, corr) {
+			maxCorr := cfg.Impact * float64(lclk.MaxDrift(cfg.Interval))
 			if float64(timemath.Abs(corr)) > maxCorr {
+				log.Debug("limiting clock correction",
+					zap.Duration("offset", corr), zap.Float64("max", maxCorr))
 				corr = time.Duration(float64(timemath.Sign(corr)) * maxCorr)
 			}
-			// lclk.Adjust(corr, refClkInterval, 0)
-			pll.Do(corr, 1000.0 /* weight */)
-			corrGauge.Set(float64(corr))
+			// lclk.Adjust(corr, cfg.Interval, 0)
+			dsc.Do(corr, m)
+			c.steps.update()
+			localCorrGauge.Set(float64(corr))
+		}
+		if ownsDrift {
+			d := dsc.Drift()
+			c.drift.Store(&d)
+			sc, ok := lclk.(stabilityClock)
+			if ok {
+				sc.SetStability(d.Uncertainty)
+			}
+			if c.cfg.DriftFile != "" &&
+				timemath.Abs(lclk.Now().Sub(driftSaved)) >= driftFileInterval {
+				c.saveDrift(log)
+				driftSaved = lclk.Now()
+			}
 		}
-		lclk.Sleep(refClkInterval)
+		lclk.Sleep(cfg.Interval)
 	}
 }
 
-func measureOffsetToNetClocks(log *zap.Logger, timeout time.Duration) time.Duration {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-	netClkClient.MeasureClockOffsets(ctx, log, netClks, netClkOffsets)
-	return timemath.FaultTolerantMidpoint(netClkOffsets)
+// numNetClocks returns the number of measurements in ms not taken from the
+// local clock.
+func numNetClocks(ms []client.Measurement) int {
+	k := 0
+	for i := 0; i != len(ms); i++ {
+		if ms[i].Source != localRefClkSource {
+			k++
+		}
+	}
+	return k
 }
 
-func RunGlobalClockSync(log *zap.Logger, lclk timebase.LocalClock) {
-	if netClkImpact <= 1.0 {
-		panic("invalid network clock impact factor")
-	}
-	if netClkImpact-1.0 <= refClkImpact {
-		panic("invalid network clock impact factor")
-	}
-	if netClkInterval < refClkInterval {
-		panic("invalid network clock sync interval")
+// measureOffsetToNetClocks returns the aggregated measurement and the number
+// of network clocks, not counting the local clock, to which the clock offset
+// could be measured.
+func measureOffsetToNetClocks(ctx context.Context, log *zap.Logger) (client.Measurement, int) {
+	c := getClocks(ctx)
+	ctx, cancel := timebase.WithTimeout(ctx, c.cfg.Global.Timeout)
+	defer cancel()
+	n := c.netClkClient.MeasureClockOffsets(ctx, log, c.netClks, c.netClkNames, c.netClkMeas)
+	k := numNetClocks(c.netClkMeas[:n])
+	if k == 0 {
+		log.Info("failed to measure clock offset to any network clock")
+		return client.Measurement{}, 0
 	}
-	if netClkTimeout < 0 || netClkTimeout > netClkInterval/2 {
-		panic("invalid network clock sync timeout")
+	n, _ = selectSources(log, "global", c.netClkNames, c.netClkMeas[:n],
+		c.globalRep, timebase.Now(ctx))
+	if numNetClocks(c.netClkMeas[:n]) == 0 {
+		log.Info("excluded all network clocks from synchronization")
+		return client.Measurement{}, 0
 	}
-	maxCorr := netClkImpact * float64(lclk.MaxDrift(netClkInterval))
-	if maxCorr <= 0 {
+	m := aggregate(c.cfg.Global.Aggregator, c.netClkMeas[:n])
+	c.setStatus(&c.globalStatus,
+		newStatus(timebase.Now(ctx), timebase.Epoch(ctx), m, c.netClkMeas[:n]))
+	return m, k
+}
+
+func RunGlobalClockSync(ctx context.Context, log *zap.Logger) {
+	lclk := timebase.Clock(ctx)
+	c := getClocks(ctx)
+	cfg := c.cfg.Global
+	if lclk.MaxDrift(cfg.Interval) <= 0 {
 		panic("invalid network clock max correction")
 	}
-	corrGauge := promauto.NewGauge(prometheus.GaugeOpts{
-		Name: metrics.SyncGlobalCorrN,
-		Help: metrics.SyncGlobalCorrH,
-	})
-	pll := newPLL(log, lclk)
+	dsc := newDiscipline(log, lclk, cfg, &c.steps)
+	ownsDrift := c.ownsDrift(false)
+	if ownsDrift && c.cfg.DriftFile != "" {
+		c.restoreDrift(log, dsc)
+	}
+	driftSaved := lclk.Now()
+	var holdover bool
+	var holdoverStart time.Time
 	for {
-		corrGauge.Set(0)
-		corr := measureOffsetToNetClocks(log, netClkTimeout)
-		if timemath.Abs(corr) > netClkCutoff {
+		globalCorrGauge.Set(0)
+		updateLeap(ctx, log)
+		m, n := measureOffsetToNetClocks(ctx, log)
+		reportSyncQuality(ctx, lclk)
+		if n == 0 {
+			if !holdover {
+				holdover = true
+				holdoverStart = lclk.Now()
+				freq := dsc.Holdover()
+				log.Info("entering holdover, lost all network clocks",
+					zap.Float64("frequency", freq))
+				globalHoldoverGauge.Set(1)
+				globalHoldoverFreqGauge.Set(freq)
+				globalHoldoversCounter.Inc()
+			}
+			lclk.Sleep(cfg.Interval)
+			continue
+		}
+		if holdover {
+			holdover = false
+			log.Info("leaving holdover, re-acquiring network clocks",
+				zap.Duration("duration", lclk.Now().Sub(holdoverStart)))
+			globalHoldoverGauge.Set(0)
+		}
+		if c.nearLeap(lclk.Now()) {
+			log.Debug("skipping clock correction close to leap second")
+			lclk.Sleep(cfg.Interval)
+			continue
+		}
+		corr := m.Offset
+		if timemath.Abs(corr) > cfg.Cutoff && c.steps.allowCorrection(log, corr) {
+			maxCorr := cfg.Impact * float64(lclk.MaxDrift(cfg.Interval))
 			if float64(timemath.Abs(corr)) > maxCorr {
+				log.Debug("limiting clock correction",
+					zap.Duration("offset", corr), zap.Float64("max", maxCorr))
 				corr = time.Duration(float64(timemath.Sign(corr)) * maxCorr)
 			}
-			// lclk.Adjust(corr, netClkInterval, 0)
-			pll.Do(corr, 1000.0 /* weight */)
-			corrGauge.Set(float64(corr))
+			// lclk.Adjust(corr, cfg.Interval, 0)
+			dsc.Do(corr, m)
+			c.steps.update()
+			globalCorrGauge.Set(float64(corr))
+		}
+		if ownsDrift {
+			d := dsc.Drift()
+			c.drift.Store(&d)
+			sc, ok := lclk.(stabilityClock)
+			if ok {
+				sc.SetStability(d.Uncertainty)
+			}
+			if c.cfg.DriftFile != "" &&
+				timemath.Abs(lclk.Now().Sub(driftSaved)) >= driftFileInterval {
+				c.saveDrift(log)
+				driftSaved = lclk.Now()
+			}
 		}
-		lclk.Sleep(netClkInterval)
+		lclk.Sleep(cfg.Interval)
 	}
 }
@@ -0,0 +1,405 @@
+package sync_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/sync"
+	"example.com/scion-time/core/timebase"
+)
+
+func TestConfigValidate(t *testing.T) {
+	err := sync.Config{}.Validate()
+	if err != nil {
+		t.Errorf("Config{}.Validate() == %v; want nil", err)
+	}
+	err = sync.DefaultConfig().Validate()
+	if err != nil {
+		t.Errorf("DefaultConfig().Validate() == %v; want nil", err)
+	}
+	for _, cfg := range []sync.Config{
+		{Local: sync.LoopConfig{Impact: 0.5}},
+		{Local: sync.LoopConfig{Impact: 2.0}},
+		{Local: sync.LoopConfig{Interval: -time.Second}},
+		{Local: sync.LoopConfig{Timeout: 2 * time.Second, Interval: 3 * time.Second}},
+		{Global: sync.LoopConfig{Interval: time.Second}},
+		{Global: sync.LoopConfig{Cutoff: -time.Microsecond}},
+		{Global: sync.LoopConfig{PLL: sync.PLLConfig{StiffenRate: 1.5}}},
+		{Local: sync.LoopConfig{PLL: sync.PLLConfig{PInit: -0.1}}},
+		{Local: sync.LoopConfig{Discipline: "fll"}},
+		{Step: sync.StepPolicy{MaxSteps: -1}},
+		{Reputation: sync.ReputationConfig{Decay: 1.5}},
+		{Global: sync.LoopConfig{Kalman: sync.KalmanConfig{MeasurementNoise: -time.Microsecond}}},
+		{IntervalTolerance: -100e-6},
+	} {
+		err := cfg.Validate()
+		if err == nil {
+			t.Errorf("%+v.Validate() succeeded", cfg)
+		}
+	}
+}
+
+func TestDriftFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "drift")
+	_, err := sync.ReadDriftFile(name)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadDriftFile(%q) == %v; want ErrNotExist", name, err)
+	}
+	d0 := sync.Drift{Frequency: -12.345678e-6, Uncertainty: 0.25e-6}
+	err = sync.WriteDriftFile(name, d0)
+	if err != nil {
+		t.Fatalf("WriteDriftFile(%q) failed: %v", name, err)
+	}
+	d1, err := sync.ReadDriftFile(name)
+	if err != nil {
+		t.Fatalf("ReadDriftFile(%q) failed: %v", name, err)
+	}
+	if math.Abs(d1.Frequency-d0.Frequency) > 1e-12 ||
+		math.Abs(d1.Uncertainty-d0.Uncertainty) > 1e-12 {
+		t.Errorf("ReadDriftFile(%q) == %+v; want %+v", name, d1, d0)
+	}
+	for _, s := range []string{"", "1.0", "1.0 x", "1000.0 0.1", "1.0 -0.1"} {
+		err = os.WriteFile(name, []byte(s), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = sync.ReadDriftFile(name)
+		if err == nil {
+			t.Errorf("ReadDriftFile succeeded for %q", s)
+		}
+	}
+}
+
+type testClock struct {
+	steps []time.Duration
+}
+
+func (c *testClock) Epoch() uint64                                { return uint64(len(c.steps)) }
+func (c *testClock) Now() time.Time                               { return time.Now() }
+func (c *testClock) MaxDrift(time.Duration) time.Duration         { return math.MaxInt64 }
+func (c *testClock) Step(offset time.Duration)                    { c.steps = append(c.steps, offset) }
+func (c *testClock) Adjust(time.Duration, time.Duration, float64) {}
+func (c *testClock) Sleep(time.Duration)                          {}
+
+type testReferenceClock struct {
+	offset time.Duration
+}
+
+func (c *testReferenceClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	return client.Measurement{Offset: c.offset, Weight: 1000.0}, nil
+}
+
+type sourceTestClock struct {
+	name   string
+	source string // reported source, if different from name
+	offset time.Duration
+	err    error
+}
+
+func (c *sourceTestClock) String() string { return c.name }
+
+func (c *sourceTestClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	if c.err != nil {
+		return client.Measurement{}, c.err
+	}
+	source := c.name
+	if c.source != "" {
+		source = c.source
+	}
+	return client.Measurement{
+		Source: source,
+		Offset: c.offset,
+		Delay:  2 * time.Millisecond,
+		Weight: 1000.0,
+	}, nil
+}
+
+func newSourceTestClocks(names ...string) ([]*sourceTestClock, []client.ReferenceClock) {
+	clks := make([]*sourceTestClock, len(names))
+	refclks := make([]client.ReferenceClock, len(names))
+	for i := 0; i != len(names); i++ {
+		clks[i] = &sourceTestClock{name: names[i]}
+		refclks[i] = clks[i]
+	}
+	return clks, refclks
+}
+
+func TestSyncToRefClocksOnlyFalsetickers(t *testing.T) {
+	clks, refclks := newSourceTestClocks("a", "b", "c", "d")
+	clk := &testClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx = sync.WithClocks(ctx, refclks, nil, sync.Config{
+		Reputation: sync.ReputationConfig{Threshold: 0.25, Backoff: time.Hour},
+	})
+
+	// d and then c are excluded as falsetickers and quarantined.
+	clks[3].offset = 50 * time.Millisecond
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	clks[2].offset = 50 * time.Millisecond
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+
+	// Only the falsetickers respond, leaving no source to select.
+	clks[0].err = errors.New("unreachable")
+	clks[1].err = errors.New("unreachable")
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	if len(clk.steps) != 0 {
+		t.Errorf("clock stepped by %v without selected sources", clk.steps)
+	}
+}
+
+func TestSyncToRefClocksRewrittenSource(t *testing.T) {
+	// d measures a different address than the one it was registered with,
+	// e.g., the server returned by NTS-KE, and is a falseticker.
+	clks, refclks := newSourceTestClocks("a", "b", "c", "d")
+	clks[3].source = "e"
+	clks[3].offset = 50 * time.Millisecond
+	clk := &testClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx = sync.WithClocks(ctx, refclks, nil, sync.Config{})
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+
+	ss := sync.GetSourceStatus(ctx)
+	if len(ss) != len(clks) {
+		t.Fatalf("got status of %d sources; want %d: %+v", len(ss), len(clks), ss)
+	}
+	for i, s := range ss {
+		if s.Source != clks[i].name {
+			t.Errorf("ss[%d].Source == %q; want %q", i, s.Source, clks[i].name)
+		}
+		if s.UnreachableRounds != 0 {
+			t.Errorf("source %s unreachable: %+v", s.Source, s)
+		}
+	}
+	if ss[3].FalsetickerRounds != 1 {
+		t.Errorf("source d not a falseticker: %+v", ss[3])
+	}
+}
+
+func TestSyncToRefClocksAllQuarantined(t *testing.T) {
+	clks, refclks := newSourceTestClocks("a", "b")
+	clk := &testClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx = sync.WithClocks(ctx, refclks, nil, sync.Config{
+		Reputation: sync.ReputationConfig{Threshold: 0.25, Backoff: time.Hour},
+	})
+
+	// a and then b are quarantined for being unreachable.
+	for i := 0; i != len(clks); i++ {
+		clks[i].err = errors.New("unreachable")
+		for j := 0; j != 3; j++ {
+			sync.SyncToRefClocks(ctx, zap.NewNop())
+		}
+		clks[i].err = nil
+	}
+
+	for i := 0; i != len(clks); i++ {
+		clks[i].offset = time.Second
+	}
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	if len(clk.steps) != 0 {
+		t.Errorf("clock stepped by %v to quarantined sources", clk.steps)
+	}
+	ss := sync.GetSourceStatus(ctx)
+	if len(ss) != len(clks) {
+		t.Fatalf("got status of %d sources; want %d", len(ss), len(clks))
+	}
+	for _, s := range ss {
+		if !s.Quarantined {
+			t.Errorf("source %s not quarantined: %+v", s.Source, s)
+		}
+	}
+}
+
+func TestStepPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy  sync.StepPolicy
+		offsets []time.Duration
+		steps   int
+	}{
+		{sync.StepPolicy{}, []time.Duration{time.Second, -time.Second}, 2},
+		{sync.StepPolicy{SlewOnly: true}, []time.Duration{time.Second}, 0},
+		{sync.StepPolicy{MaxSteps: 1}, []time.Duration{time.Second, time.Second}, 1},
+		{sync.StepPolicy{StepUpdates: 2}, []time.Duration{time.Second, time.Second, time.Second}, 2},
+		{sync.StepPolicy{ForwardOnly: true}, []time.Duration{-time.Second, time.Second}, 1},
+		{sync.StepPolicy{PanicThreshold: time.Minute}, []time.Duration{time.Hour, time.Second}, 1},
+	} {
+		clk := &testClock{}
+		refclk := &testReferenceClock{}
+		ctx := timebase.WithClock(context.Background(), clk)
+		ctx = sync.WithClocks(ctx, []client.ReferenceClock{refclk}, nil,
+			sync.Config{Step: tc.policy})
+		for _, off := range tc.offsets {
+			refclk.offset = off
+			sync.SyncToRefClocks(ctx, zap.NewNop())
+		}
+		if len(clk.steps) != tc.steps {
+			t.Errorf("%+v: %d steps; want %d", tc.policy, len(clk.steps), tc.steps)
+		}
+	}
+}
+
+type kalmanTestClock struct {
+	now    time.Time
+	offset time.Duration
+}
+
+func (c *kalmanTestClock) Epoch() uint64                        { return 0 }
+func (c *kalmanTestClock) Now() time.Time                       { return c.now }
+func (c *kalmanTestClock) MaxDrift(time.Duration) time.Duration { return math.MaxInt64 }
+func (c *kalmanTestClock) Step(offset time.Duration)            { c.offset -= offset }
+func (c *kalmanTestClock) Adjust(offset, _ time.Duration, _ float64) {
+	c.offset -= offset
+}
+func (c *kalmanTestClock) Sleep(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestKalmanLimitedCorrections(t *testing.T) {
+	// The loop limits corrections to maxCorr while the measured offset is
+	// larger. The filter must not mistake the limit for a frequency error.
+	const maxCorr = 1 * time.Millisecond
+	clk := &kalmanTestClock{now: time.Unix(0, 0), offset: 10 * time.Millisecond}
+	k := sync.NewKalman(clk, sync.KalmanConfig{StepThreshold: time.Hour})
+	for i := 0; i != 8; i++ {
+		m := client.Measurement{Offset: clk.offset, Weight: 1000.0}
+		corr := m.Offset
+		if corr > maxCorr {
+			corr = maxCorr
+		}
+		k.Do(corr, m)
+		clk.Sleep(10 * time.Second)
+	}
+	if f := k.Drift().Frequency; math.Abs(f) > 1e-6 {
+		t.Errorf("k.Drift().Frequency == %v; want 0", f)
+	}
+	if clk.offset < 2*time.Millisecond || clk.offset > 4*time.Millisecond {
+		t.Errorf("remaining offset == %v; want 3ms", clk.offset)
+	}
+}
+
+func TestPLLHoldover(t *testing.T) {
+	const offset = 500 * time.Microsecond
+	clk := &kalmanTestClock{now: time.Unix(0, 0)}
+	l := sync.NewPLL(clk, sync.PLLConfig{})
+	track := func(n int) {
+		for i := 0; i != n; i++ {
+			l.Do(clk.offset, client.Measurement{Offset: clk.offset, Weight: 1000.0})
+			clk.Sleep(1 * time.Second)
+		}
+	}
+	track(15)
+	l.SetDrift(sync.Drift{Frequency: 1e-6})
+
+	// After a short holdover, the loop resumes tracking with the gains it had
+	// before, even though the capture time passed in the meantime.
+	l.Holdover()
+	clk.Sleep(1 * time.Hour)
+	clk.offset = offset
+	track(1)
+	if corr := offset - clk.offset; corr < 150*time.Microsecond || corr > 180*time.Microsecond {
+		t.Errorf("correction after holdover == %v; want 165µs", corr)
+	}
+	if f := l.Drift().Frequency; math.Abs(f-1.9075e-6) > 1e-9 {
+		t.Errorf("l.Drift().Frequency == %v; want 1.9075e-6", f)
+	}
+
+	// After a holdover with an offset beyond the step threshold, the loop
+	// restarts and steps the clock.
+	l.Holdover()
+	clk.Sleep(1 * time.Hour)
+	clk.offset = 10 * time.Millisecond
+	track(1)
+	if clk.offset != 10*time.Millisecond {
+		t.Errorf("offset == %v after restart; want no correction", clk.offset)
+	}
+	track(3)
+	if clk.offset != 0 {
+		t.Errorf("offset == %v after restart; want step to 0", clk.offset)
+	}
+}
+
+func TestPLLDriftUncertainty(t *testing.T) {
+	clk := &kalmanTestClock{now: time.Unix(0, 0)}
+	l := sync.NewPLL(clk, sync.PLLConfig{})
+	l.SetDrift(sync.Drift{Frequency: 1e-6, Uncertainty: 2e-7})
+	track := func(n int) {
+		for i := 0; i != n; i++ {
+			l.Do(clk.offset, client.Measurement{Offset: clk.offset, Weight: 1000.0})
+			clk.Sleep(1 * time.Second)
+		}
+	}
+
+	// Measurements without any offset do not change the frequency estimate,
+	// but they must not discard the uncertainty of the previous estimates.
+	track(20)
+	if u := l.Drift().Uncertainty; u <= 1e-7 || u > 2e-7 {
+		t.Errorf("l.Drift().Uncertainty == %v; want (1e-7, 2e-7]", u)
+	}
+
+	// Frequency corrections increase the uncertainty.
+	for i := 0; i != 3; i++ {
+		clk.offset = 500 * time.Microsecond
+		track(1)
+	}
+	if u := l.Drift().Uncertainty; u <= 2e-7 {
+		t.Errorf("l.Drift().Uncertainty == %v; want > 2e-7", u)
+	}
+}
+
+type testDiscipline interface {
+	Do(offset time.Duration, m client.Measurement)
+	Holdover() float64
+}
+
+func TestDisciplineStepLimitedCorrections(t *testing.T) {
+	// The loop limits corrections to maxCorr, which is below the step
+	// threshold. Steps must nevertheless remove the full measured offset, both
+	// at startup and after holdover.
+	const maxCorr = 500 * time.Microsecond
+	for _, tc := range []struct {
+		name string
+		new  func(clk *kalmanTestClock) testDiscipline
+	}{
+		{"pll", func(clk *kalmanTestClock) testDiscipline {
+			return sync.NewPLL(clk, sync.PLLConfig{})
+		}},
+		{"kalman", func(clk *kalmanTestClock) testDiscipline {
+			return sync.NewKalman(clk, sync.KalmanConfig{})
+		}},
+	} {
+		clk := &kalmanTestClock{now: time.Unix(0, 0), offset: time.Second}
+		dsc := tc.new(clk)
+		track := func(n int) {
+			for i := 0; i != n; i++ {
+				m := client.Measurement{Offset: clk.offset, Weight: 1000.0}
+				corr := m.Offset
+				if timemath.Abs(corr) > maxCorr {
+					corr = time.Duration(timemath.Sign(corr)) * maxCorr
+				}
+				dsc.Do(corr, m)
+				clk.Sleep(1 * time.Second)
+			}
+		}
+		track(15)
+		if timemath.Abs(clk.offset) > maxCorr {
+			t.Errorf("%s: offset == %v after startup; want step to 0", tc.name, clk.offset)
+		}
+		dsc.Holdover()
+		clk.Sleep(1 * time.Hour)
+		clk.offset = -time.Second
+		track(5)
+		if timemath.Abs(clk.offset) > maxCorr {
+			t.Errorf("%s: offset == %v after holdover; want step to 0", tc.name, clk.offset)
+		}
+	}
+}
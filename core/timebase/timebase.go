@@ -1,12 +1,26 @@
 package timebase
 
 import (
+	"context"
+	"errors"
 	"sync/atomic"
 	"time"
 
 	"example.com/scion-time/base/timebase"
 )
 
+type clockKey struct{}
+
+type clockContextKey struct{}
+
+type clockContext struct {
+	context.Context
+	deadline    time.Time
+	parent      *clockContext
+	cancelCause context.CancelCauseFunc
+	waiters     []*waiter
+}
+
 var (
 	lclk atomic.Value
 )
@@ -21,18 +35,95 @@ func RegisterClock(c timebase.LocalClock) {
 	}
 }
 
-func Now() time.Time {
-	c := lclk.Load().(timebase.LocalClock)
+// WithClock returns a copy of ctx in which c replaces the registered local
+// clock. This allows multiple time service instances, each with its own local
+// clock, to run in the same process.
+func WithClock(ctx context.Context, c timebase.LocalClock) context.Context {
 	if c == nil {
-		panic("no local clock registered")
+		panic("local clock must not be nil")
 	}
-	return c.Now()
+	return context.WithValue(ctx, clockKey{}, c)
 }
 
-func Epoch() uint64 {
-	c := lclk.Load().(timebase.LocalClock)
-	if c == nil {
+// Clock returns the local clock associated with ctx or, if there is none, the
+// registered local clock.
+func Clock(ctx context.Context) timebase.LocalClock {
+	c, ok := ctx.Value(clockKey{}).(timebase.LocalClock)
+	if ok {
+		return c
+	}
+	c, ok = lclk.Load().(timebase.LocalClock)
+	if !ok {
 		panic("no local clock registered")
 	}
-	return c.Epoch()
+	return c
+}
+
+func Now(ctx context.Context) time.Time {
+	return Clock(ctx).Now()
+}
+
+func Epoch(ctx context.Context) uint64 {
+	return Clock(ctx).Epoch()
+}
+
+// FromSystemTime converts the system clock timestamp t, e.g., a kernel packet
+// timestamp, into the time scale of the local clock associated with ctx.
+func FromSystemTime(ctx context.Context, t time.Time) time.Time {
+	c, ok := Clock(ctx).(timebase.SystemTimeClock)
+	if !ok {
+		return t
+	}
+	return c.FromSystemTime(t)
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockContext) Value(key any) any {
+	if key == (clockContextKey{}) {
+		return c
+	}
+	return c.Context.Value(key)
+}
+
+func (c *clockContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// WithTimeout is like context.WithTimeout but, if ctx is associated with a
+// local clock, the timeout is measured by that clock. Clocks that do not
+// provide timers are assumed to advance in real time.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	c, ok := ctx.Value(clockKey{}).(timebase.LocalClock)
+	if !ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	deadline := c.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	cctx, cancel := context.WithCancelCause(ctx)
+	cc := &clockContext{
+		Context:     cctx,
+		deadline:    deadline,
+		parent:      lookupClockContext(ctx),
+		cancelCause: cancel,
+	}
+	expire := func() { cc.cancel(context.DeadlineExceeded) }
+	var stop func() bool
+	if tc, ok := c.(timebase.TimerClock); ok {
+		stop = tc.AfterFunc(timeout, expire)
+	} else {
+		stop = time.AfterFunc(timeout, expire).Stop
+	}
+	return cc, func() {
+		stop()
+		cc.cancel(context.Canceled)
+	}
 }
@@ -0,0 +1,144 @@
+package timebase_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"example.com/scion-time/core/timebase"
+)
+
+type testClock struct{}
+
+func (c *testClock) Epoch() uint64                                { return 0 }
+func (c *testClock) Now() time.Time                               { return time.Now() }
+func (c *testClock) MaxDrift(time.Duration) time.Duration         { return 0 }
+func (c *testClock) Step(time.Duration)                           {}
+func (c *testClock) Adjust(time.Duration, time.Duration, float64) {}
+func (c *testClock) Sleep(time.Duration)                          {}
+
+type testTimerClock struct {
+	testClock
+	f       func()
+	stopped bool
+}
+
+func (c *testTimerClock) AfterFunc(d time.Duration, f func()) func() bool {
+	c.f = f
+	return func() bool {
+		c.stopped = c.f != nil
+		c.f = nil
+		return c.stopped
+	}
+}
+
+func TestWithTimeoutDeadlineExceeded(t *testing.T) {
+	clk := &testTimerClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx, cancel := timebase.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	if ctx.Err() != nil {
+		t.Fatalf("ctx.Err() = %v before timeout; want nil", ctx.Err())
+	}
+	clk.f()
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("ctx.Err() = %v after timeout; want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+func TestWithTimeoutCancel(t *testing.T) {
+	clk := &testTimerClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx, cancel := timebase.WithTimeout(ctx, time.Hour)
+	cancel()
+	<-ctx.Done()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("ctx.Err() = %v after cancel; want %v", ctx.Err(), context.Canceled)
+	}
+	if !clk.stopped {
+		t.Errorf("cancel did not stop the clock timer")
+	}
+}
+
+func TestWithTimeoutRealTime(t *testing.T) {
+	clk := &testClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx, cancel := timebase.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	// Sleep returns immediately, the timeout must still take real time.
+	select {
+	case <-ctx.Done():
+		t.Fatalf("ctx done before timeout")
+	case <-time.After(time.Millisecond):
+	}
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("ctx.Err() = %v after timeout; want %v", ctx.Err(), context.DeadlineExceeded)
+	}
+}
+
+type trackingTestClock struct {
+	testTimerClock
+	mu     sync.Mutex
+	active int
+}
+
+func (c *trackingTestClock) Go(f func()) {
+	c.Unblock(1)
+	go func() {
+		defer c.Block()
+		f()
+	}()
+}
+
+func (c *trackingTestClock) Block() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+}
+
+func (c *trackingTestClock) Unblock(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active += n
+}
+
+func (c *trackingTestClock) numActive() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+func TestReceiveTracking(t *testing.T) {
+	clk := &trackingTestClock{}
+	ctx := timebase.WithClock(context.Background(), clk)
+	ctx, cancel := timebase.WithTimeout(ctx, time.Hour)
+	defer cancel()
+
+	ch := make(chan int)
+	res := make(chan bool, 2)
+	timebase.Go(ctx, func() {
+		v, ok := timebase.Receive(ctx, ch)
+		res <- ok && v == 1
+		_, ok = timebase.Receive(ctx, ch)
+		res <- !ok
+	})
+	timebase.Send(ctx, ch, 1)
+	if !<-res {
+		t.Fatalf("Receive did not return the value sent")
+	}
+	for clk.numActive() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The expiring timeout accounts for the goroutine it wakes up.
+	clk.f()
+	if clk.numActive() != 1 {
+		t.Errorf("%d active goroutines after timeout; want 1", clk.numActive())
+	}
+	if !<-res {
+		t.Fatalf("Receive did not return after timeout")
+	}
+}
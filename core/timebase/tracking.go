@@ -0,0 +1,117 @@
+package timebase
+
+import (
+	"context"
+	"sync"
+
+	"example.com/scion-time/base/timebase"
+)
+
+// A waiter is a goroutine blocked in Receive. It is woken either by a value
+// sent with Send or by the cancellation of its context.
+type waiter struct {
+	woken bool
+}
+
+var waitMu sync.Mutex
+
+func trackingClock(ctx context.Context) (timebase.TrackingClock, bool) {
+	c, ok := ctx.Value(clockKey{}).(timebase.TrackingClock)
+	if ok {
+		return c, true
+	}
+	c, ok = lclk.Load().(timebase.TrackingClock)
+	return c, ok
+}
+
+func lookupClockContext(ctx context.Context) *clockContext {
+	c, _ := ctx.Value(clockContextKey{}).(*clockContext)
+	return c
+}
+
+// cancel cancels c with the given cause. Goroutines waiting in Receive for c
+// or a context derived from it are unblocked before they are woken up.
+func (c *clockContext) cancel(cause error) {
+	waitMu.Lock()
+	defer waitMu.Unlock()
+	n := 0
+	for _, w := range c.waiters {
+		if !w.woken {
+			w.woken = true
+			n++
+		}
+	}
+	c.waiters = nil
+	if n != 0 {
+		tc, ok := trackingClock(c)
+		if !ok {
+			panic("unexpected waiters")
+		}
+		tc.Unblock(n)
+	}
+	c.cancelCause(cause)
+}
+
+// Go calls f in a new goroutine. If the local clock associated with ctx is a
+// TrackingClock, the goroutine is tracked by that clock and must only wait for
+// other goroutines by means of Receive and the local clock.
+func Go(ctx context.Context, f func()) {
+	tc, ok := trackingClock(ctx)
+	if !ok {
+		go f()
+		return
+	}
+	tc.Go(f)
+}
+
+// Send sends v on ch to a goroutine receiving with Receive.
+func Send[T any](ctx context.Context, ch chan<- T, v T) {
+	tc, ok := trackingClock(ctx)
+	if ok {
+		tc.Unblock(1)
+	}
+	ch <- v
+}
+
+// Receive receives a value sent with Send on ch. It returns false if ctx is
+// done before a value is received.
+func Receive[T any](ctx context.Context, ch <-chan T) (v T, ok bool) {
+	tc, tracked := trackingClock(ctx)
+	if !tracked {
+		select {
+		case v = <-ch:
+			return v, true
+		case <-ctx.Done():
+			return v, false
+		}
+	}
+	if ctx.Done() == nil {
+		tc.Block()
+		return <-ch, true
+	}
+	w := &waiter{}
+	waitMu.Lock()
+	if ctx.Err() != nil {
+		waitMu.Unlock()
+		return v, false
+	}
+	for c := lookupClockContext(ctx); c != nil; c = c.parent {
+		c.waiters = append(c.waiters, w)
+	}
+	waitMu.Unlock()
+	tc.Block()
+	select {
+	case v = <-ch:
+		ok = true
+	case <-ctx.Done():
+	}
+	waitMu.Lock()
+	woken := w.woken
+	w.woken = true
+	waitMu.Unlock()
+	if !ok && !woken {
+		// ctx was not canceled by WithTimeout, e.g., by a parent context.
+		tc.Unblock(1)
+	}
+	return v, ok
+}
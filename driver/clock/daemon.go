@@ -0,0 +1,60 @@
+package clock
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/metrics"
+)
+
+// TimeDaemon is a running time synchronization daemon other than this process.
+type TimeDaemon struct {
+	PID  int
+	Name string
+}
+
+// timeDaemons are the process names of well-known daemons that adjust the
+// system clock. Linux truncates process names to 15 characters.
+var timeDaemons = []string{
+	"chronyd",
+	"ntpd",
+	"openntpd",
+	"ntpdate",
+	"sntp",
+	"htpdate",
+	"phc2sys",
+	"timemaster",
+	"systemd-timesyn",
+}
+
+var timeDaemonsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: metrics.ClockTimeDaemonsN,
+	Help: metrics.ClockTimeDaemonsH,
+})
+
+func isTimeDaemon(name string) bool {
+	for _, d := range timeDaemons {
+		if name == d {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTimeDaemons warns about other time synchronization daemons running on
+// the host, which compete with this service for the control of the system
+// clock.
+func CheckTimeDaemons(log *zap.Logger) {
+	ds, err := FindTimeDaemons()
+	if err != nil {
+		log.Info("failed to look for other time synchronization daemons", zap.Error(err))
+		return
+	}
+	for _, d := range ds {
+		log.Info("competing time synchronization daemon running",
+			zap.String("name", d.Name), zap.Int("pid", d.PID))
+	}
+	timeDaemonsGauge.Set(float64(len(ds)))
+}
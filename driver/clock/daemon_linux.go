@@ -0,0 +1,40 @@
+//go:build linux
+
+package clock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// FindTimeDaemons returns the well-known time synchronization daemons running
+// on the host.
+func FindTimeDaemons() ([]TimeDaemon, error) {
+	es, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	self := os.Getpid()
+	var ds []TimeDaemon
+	for _, e := range es {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue // process exited
+			}
+			return nil, err
+		}
+		name := strings.TrimSpace(string(b))
+		if isTimeDaemon(name) {
+			ds = append(ds, TimeDaemon{PID: pid, Name: name})
+		}
+	}
+	return ds, nil
+}
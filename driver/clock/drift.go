@@ -0,0 +1,31 @@
+package clock
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// stabilityFactor scales the measured frequency stability to a bound on
+	// the frequency error of the disciplined clock.
+	stabilityFactor = 3.0
+	minDriftRate    = 1e-6
+)
+
+// maxDrift returns the maximum drift during duration of a clock with the
+// configured frequency tolerance tol and the measured frequency stability
+// stab. Zero values denote unknown bounds.
+func maxDrift(tol, stab float64, duration time.Duration) time.Duration {
+	rate := math.Inf(1)
+	if tol > 0.0 {
+		rate = tol
+	}
+	if stab > 0.0 {
+		rate = math.Min(rate, math.Max(stabilityFactor*stab, minDriftRate))
+	}
+	d := rate * float64(duration)
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(d)
+}
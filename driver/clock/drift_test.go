@@ -0,0 +1,26 @@
+package clock
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMaxDrift(t *testing.T) {
+	for _, tc := range []struct {
+		tol, stab float64
+		want      time.Duration
+	}{
+		{0.0, 0.0, math.MaxInt64},
+		{100e-6, 0.0, 100 * time.Microsecond},
+		{0.0, 2e-6, 6 * time.Microsecond},
+		{0.0, 1e-9, 1 * time.Microsecond},
+		{100e-6, 2e-6, 6 * time.Microsecond},
+		{5e-6, 2e-6, 5 * time.Microsecond},
+	} {
+		d := maxDrift(tc.tol, tc.stab, time.Second)
+		if d != tc.want {
+			t.Errorf("maxDrift(%v, %v, 1s) == %v; want %v", tc.tol, tc.stab, d, tc.want)
+		}
+	}
+}
@@ -7,30 +7,62 @@ package clock
 import (
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
 	"go.uber.org/zap"
 
 	"golang.org/x/sys/unix"
 
+	"example.com/scion-time/base/leap"
+	"example.com/scion-time/base/metrics"
 	"example.com/scion-time/base/timebase"
 	"example.com/scion-time/base/timemath"
 )
 
+const (
+	// maxError is the largest maximum error accepted by the kernel, see
+	// NTP_PHASE_LIMIT in include/linux/timex.h.
+	maxError = 16 * time.Second
+
+	stepTimerInterval = 24 * time.Hour
+	leapMargin        = 2 * time.Second
+)
+
 type adjustment struct {
 	clock     *SystemClock
 	duration  time.Duration
 	afterFreq float64
 }
 
+// SystemClock is the local system clock. Its maximum drift is bounded by the
+// frequency Tolerance of the oscillator, e.g., 100e-6 == 100 ppm, and, if
+// MeasuredDrift is set, by the frequency stability measured by the clock
+// discipline. Without any bound, the drift is unlimited.
 type SystemClock struct {
-	Log        *zap.Logger
-	mu         sync.Mutex
-	epoch      uint64
-	adjustment *adjustment
+	Log           *zap.Logger
+	Tolerance     float64
+	MeasuredDrift bool
+	stability     atomic.Uint64
+	mu            sync.Mutex
+	epoch         uint64
+	adjustment    *adjustment
+	steps         int
+	leap          leap.Indicator
 }
 
-var _ timebase.LocalClock = (*SystemClock)(nil)
+var foreignStepsCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: metrics.ClockForeignStepsN,
+	Help: metrics.ClockForeignStepsH,
+})
+
+var (
+	_ timebase.LocalClock       = (*SystemClock)(nil)
+	_ timebase.SyncQualityClock = (*SystemClock)(nil)
+)
 
 func now(log *zap.Logger) time.Time {
 	var ts unix.Timespec
@@ -112,6 +144,71 @@ func setFrequency(log *zap.Logger, frequency float64) {
 	}
 }
 
+func setLeap(log *zap.Logger, ind leap.Indicator) {
+	log.Debug("setting leap status", zap.Stringer("leap", ind))
+	var tx unix.Timex
+	_, err := unix.ClockAdjtime(unix.CLOCK_REALTIME, &tx)
+	if err != nil {
+		log.Fatal("unix.ClockAdjtime failed", zap.Error(err))
+	}
+	tx.Modes = unix.ADJ_STATUS
+	tx.Status &^= unix.STA_INS | unix.STA_DEL
+	switch ind {
+	case leap.Insert:
+		tx.Status |= unix.STA_INS
+	case leap.Delete:
+		tx.Status |= unix.STA_DEL
+	}
+	_, err = unix.ClockAdjtime(unix.CLOCK_REALTIME, &tx)
+	if err != nil {
+		log.Fatal("unix.ClockAdjtime failed", zap.Error(err))
+	}
+}
+
+func setSyncQuality(log *zap.Logger, q timebase.SyncQuality) {
+	log.Debug("setting sync quality",
+		zap.Bool("synchronized", q.Synchronized),
+		zap.Duration("max error", q.MaxError),
+		zap.Duration("est error", q.EstError))
+	var tx unix.Timex
+	_, err := unix.ClockAdjtime(unix.CLOCK_REALTIME, &tx)
+	if err != nil {
+		log.Fatal("unix.ClockAdjtime failed", zap.Error(err))
+	}
+	maxErr, estErr := q.MaxError, q.EstError
+	if !q.Synchronized || maxErr > maxError {
+		maxErr = maxError
+	}
+	if estErr > maxErr {
+		estErr = maxErr
+	}
+	tx.Modes = unix.ADJ_STATUS | unix.ADJ_MAXERROR | unix.ADJ_ESTERROR
+	tx.Maxerror = maxErr.Microseconds()
+	tx.Esterror = estErr.Microseconds()
+	if q.Synchronized {
+		tx.Status &^= unix.STA_UNSYNC
+	} else {
+		tx.Status |= unix.STA_UNSYNC
+	}
+	_, err = unix.ClockAdjtime(unix.CLOCK_REALTIME, &tx)
+	if err != nil {
+		log.Fatal("unix.ClockAdjtime failed", zap.Error(err))
+	}
+}
+
+func syncQuality(log *zap.Logger) timebase.SyncQuality {
+	var tx unix.Timex
+	_, err := unix.ClockAdjtime(unix.CLOCK_REALTIME, &tx)
+	if err != nil {
+		log.Fatal("unix.ClockAdjtime failed", zap.Error(err))
+	}
+	return timebase.SyncQuality{
+		Synchronized: tx.Status&unix.STA_UNSYNC == 0,
+		MaxError:     time.Duration(tx.Maxerror) * time.Microsecond,
+		EstError:     time.Duration(tx.Esterror) * time.Microsecond,
+	}
+}
+
 func (c *SystemClock) Epoch() uint64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -123,7 +220,17 @@ func (c *SystemClock) Now() time.Time {
 }
 
 func (c *SystemClock) MaxDrift(duration time.Duration) time.Duration {
-	return math.MaxInt64
+	var stab float64
+	if c.MeasuredDrift {
+		stab = math.Float64frombits(c.stability.Load())
+	}
+	return maxDrift(c.Tolerance, stab, duration)
+}
+
+// SetStability records the frequency stability measured by the clock
+// discipline, i.e., the uncertainty of its frequency estimate.
+func (c *SystemClock) SetStability(stability float64) {
+	c.stability.Store(math.Float64bits(stability))
 }
 
 func (c *SystemClock) Step(offset time.Duration) {
@@ -134,6 +241,11 @@ func (c *SystemClock) Step(offset time.Duration) {
 		c.adjustment = nil
 	}
 	setTime(c.Log, offset)
+	c.steps++
+	c.incrementEpoch()
+}
+
+func (c *SystemClock) incrementEpoch() {
 	if c.epoch == math.MaxUint64 {
 		panic("epoch overflow")
 	}
@@ -169,6 +281,30 @@ func (c *SystemClock) Adjust(offset, duration time.Duration, frequency float64)
 	}(c.Log, c.adjustment)
 }
 
+// SetLeap arms the kernel to insert or delete a leap second at the end of the
+// current UTC day, or disarms it.
+func (c *SystemClock) SetLeap(ind leap.Indicator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	setLeap(c.Log, ind)
+	c.leap = ind
+}
+
+// SetSyncQuality sets the kernel's maximum and estimated error and clears the
+// STA_UNSYNC status bit if the clock is synchronized, or sets it otherwise.
+// The kernel increases the maximum error by 500 ppm and sets STA_UNSYNC once
+// the maximum error exceeds 16 s.
+func (c *SystemClock) SetSyncQuality(q timebase.SyncQuality) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	setSyncQuality(c.Log, q)
+}
+
+// SyncQuality returns the synchronization quality maintained by the kernel.
+func (c *SystemClock) SyncQuality() timebase.SyncQuality {
+	return syncQuality(c.Log)
+}
+
 func (c *SystemClock) Sleep(duration time.Duration) {
 	c.Log.Debug("sleeping", zap.Duration("duration", duration))
 	if duration < 0 {
@@ -176,3 +312,74 @@ func (c *SystemClock) Sleep(duration time.Duration) {
 	}
 	sleep(c.Log, duration)
 }
+
+func (c *SystemClock) armStepTimer(fd int) {
+	ts, err := unix.TimeToTimespec(now(c.Log).Add(stepTimerInterval))
+	if err != nil {
+		c.Log.Fatal("unix.TimeToTimespec failed", zap.Error(err))
+	}
+	err = unix.TimerfdSettime(fd, unix.TFD_TIMER_ABSTIME|unix.TFD_TIMER_CANCEL_ON_SET,
+		&unix.ItimerSpec{Value: ts}, nil /* oldValue */)
+	if err != nil {
+		c.Log.Fatal("unix.TimerfdSettime failed", zap.Error(err))
+	}
+}
+
+// clockSet handles a discontinuous change of the system clock. Steps made by
+// c itself and the insertion or deletion of an armed leap second are expected,
+// all other steps are foreign. c.mu must be held.
+func (c *SystemClock) clockSet() {
+	if c.steps != 0 {
+		// Any number of own steps may be reported as a single change.
+		c.steps = 0
+		return
+	}
+	t := now(c.Log)
+	if c.leap != leap.None {
+		d := t.Sub(t.Truncate(24 * time.Hour))
+		if d < leapMargin || d > 24*time.Hour-leapMargin {
+			return
+		}
+	}
+	if c.adjustment != nil {
+		setFrequency(c.Log, c.adjustment.afterFreq)
+		c.adjustment = nil
+	}
+	c.incrementEpoch()
+	c.Log.Info("detected foreign clock step", zap.Uint64("epoch", c.epoch))
+	foreignStepsCounter.Inc()
+	CheckTimeDaemons(c.Log)
+}
+
+// DetectForeignSteps watches the system clock for steps not made by c, e.g.,
+// by an administrator, another time synchronization daemon or the migration
+// of a virtual machine, and increments the epoch of c for each of them, which
+// resets all state derived from earlier clock readings. DetectForeignSteps
+// does not return.
+func (c *SystemClock) DetectForeignSteps() {
+	fd, err := unix.TimerfdCreate(unix.CLOCK_REALTIME, 0 /* flags */)
+	if err != nil {
+		c.Log.Fatal("unix.TimerfdCreate failed", zap.Error(err))
+	}
+	c.mu.Lock()
+	c.steps = 0
+	c.armStepTimer(fd)
+	c.mu.Unlock()
+	var buf [8]byte
+	for {
+		_, err := unix.Read(fd, buf[:])
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil && err != unix.ECANCELED {
+			c.Log.Fatal("unix.Read failed", zap.Error(err))
+		}
+		c.mu.Lock()
+		if err == unix.ECANCELED {
+			c.clockSet()
+		}
+		// Rearm while holding c.mu so that no own step goes unnoticed.
+		c.armStepTimer(fd)
+		c.mu.Unlock()
+	}
+}
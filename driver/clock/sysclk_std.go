@@ -4,18 +4,33 @@ package clock
 
 import (
 	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"example.com/scion-time/base/leap"
 	"example.com/scion-time/base/timebase"
 )
 
+// SystemClock is the local system clock. Its maximum drift is bounded by the
+// frequency Tolerance of the oscillator, e.g., 100e-6 == 100 ppm, and, if
+// MeasuredDrift is set, by the frequency stability measured by the clock
+// discipline. Without any bound, the drift is unlimited.
 type SystemClock struct {
-	Log *zap.Logger
+	Log           *zap.Logger
+	Tolerance     float64
+	MeasuredDrift bool
+	stability     atomic.Uint64
+	mu            sync.Mutex
+	quality       timebase.SyncQuality
 }
 
-var _ timebase.LocalClock = (*SystemClock)(nil)
+var (
+	_ timebase.LocalClock       = (*SystemClock)(nil)
+	_ timebase.SyncQualityClock = (*SystemClock)(nil)
+)
 
 func (c *SystemClock) Epoch() uint64 {
 	return 0
@@ -26,7 +41,17 @@ func (c *SystemClock) Now() time.Time {
 }
 
 func (c *SystemClock) MaxDrift(duration time.Duration) time.Duration {
-	return math.MaxInt64
+	var stab float64
+	if c.MeasuredDrift {
+		stab = math.Float64frombits(c.stability.Load())
+	}
+	return maxDrift(c.Tolerance, stab, duration)
+}
+
+// SetStability records the frequency stability measured by the clock
+// discipline, i.e., the uncertainty of its frequency estimate.
+func (c *SystemClock) SetStability(stability float64) {
+	c.stability.Store(math.Float64bits(stability))
 }
 
 func (c *SystemClock) Step(offset time.Duration) {
@@ -41,6 +66,31 @@ func (c *SystemClock) Adjust(offset, duration time.Duration, frequency float64)
 	)
 }
 
+func (c *SystemClock) SetLeap(ind leap.Indicator) {
+	c.Log.Debug("SystemClock.SetLeap, not yet implemented", zap.Stringer("leap", ind))
+}
+
+func (c *SystemClock) SetSyncQuality(q timebase.SyncQuality) {
+	c.Log.Debug("SystemClock.SetSyncQuality",
+		zap.Bool("synchronized", q.Synchronized),
+		zap.Duration("max error", q.MaxError),
+		zap.Duration("est error", q.EstError),
+	)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quality = q
+}
+
+func (c *SystemClock) SyncQuality() timebase.SyncQuality {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quality
+}
+
+func (c *SystemClock) DetectForeignSteps() {
+	c.Log.Debug("SystemClock.DetectForeignSteps, not yet implemented")
+}
+
 func (c *SystemClock) Sleep(duration time.Duration) {
 	c.Log.Debug("SystemClock.Sleep", zap.Duration("duration", duration))
 	time.Sleep(duration)
@@ -0,0 +1,327 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/leap"
+	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/base/timemath"
+)
+
+// VirtualClock is a local clock that keeps its own offset and frequency
+// correction on top of CLOCK_MONOTONIC instead of adjusting the system clock.
+// It therefore requires no privileges and leaves the system clock to other
+// time synchronization daemons. Its maximum drift is bounded by the frequency
+// tolerance of the oscillator like that of SystemClock.
+//
+// If a file name is given, the clock publishes its mapping from
+// CLOCK_MONOTONIC to UTC to that file after every correction, see
+// ReadVirtualTime.
+//
+// Like the kernel, the clock inserts a leap second by stepping back by one
+// second at the end of the UTC day, repeating 23:59:59, and deletes one by
+// stepping forward by one second at 23:59:59. Unlike Step, leap seconds do not
+// change the epoch of the clock.
+type VirtualClock struct {
+	log       *zap.Logger
+	tolerance float64
+	file      string
+	mu        sync.Mutex
+	epoch     uint64
+	m0        time.Duration // monotonic time at the start of the current segment
+	t0        time.Time     // virtual time at m0
+	freq      float64       // frequency correction during the current segment
+	adjEnd    time.Duration // monotonic time at which the current adjustment ends
+	afterFreq float64
+	leap      leap.Indicator
+	leapAt    time.Time // virtual time at which the pending leap second is applied
+	quality   timebase.SyncQuality
+}
+
+// VirtualTime is the mapping from CLOCK_MONOTONIC to UTC published by a
+// VirtualClock: at monotonic time m, UTC is
+// UTC + (m - Monotonic) * (1 + Frequency). Quality is the synchronization
+// quality of the clock at the time of publication.
+type VirtualTime struct {
+	Monotonic time.Duration
+	UTC       time.Time
+	Frequency float64
+	Quality   timebase.SyncQuality
+}
+
+var (
+	_ timebase.LocalClock       = (*VirtualClock)(nil)
+	_ timebase.SyncQualityClock = (*VirtualClock)(nil)
+	_ timebase.SystemTimeClock  = (*VirtualClock)(nil)
+
+	errInvalidVirtualTime = errors.New("invalid virtual time file")
+)
+
+// NewVirtualClock returns a virtual clock that starts at the current system
+// time. If file is not empty, the clock publishes its time to file.
+func NewVirtualClock(log *zap.Logger, tolerance float64, file string) *VirtualClock {
+	c := &VirtualClock{
+		log:       log,
+		tolerance: tolerance,
+		file:      file,
+		m0:        monotonic(log),
+		t0:        time.Now().UTC(),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.publish()
+	return c
+}
+
+// At returns UTC at monotonic time m.
+func (v VirtualTime) At(m time.Duration) time.Time {
+	d := m - v.Monotonic
+	return v.UTC.Add(d + time.Duration(v.Frequency*float64(d)))
+}
+
+// at returns the virtual time at monotonic time m of the current segment.
+// c.mu must be held.
+func (c *VirtualClock) at(m time.Duration) time.Time {
+	return VirtualTime{Monotonic: c.m0, UTC: c.t0, Frequency: c.freq}.At(m)
+}
+
+// settle ends the current adjustment if it has elapsed at monotonic time m
+// and applies the pending leap second if it is due. c.mu must be held.
+func (c *VirtualClock) settle(m time.Duration) {
+	if c.adjEnd != 0 && m >= c.adjEnd {
+		c.t0 = c.at(c.adjEnd)
+		c.m0 = c.adjEnd
+		c.freq = c.afterFreq
+		c.adjEnd = 0
+		c.publish()
+	}
+	if c.leap != leap.None && !c.at(m).Before(c.leapAt) {
+		c.t0 = c.at(m)
+		c.m0 = m
+		if c.leap == leap.Insert {
+			c.t0 = c.t0.Add(-time.Second)
+		} else {
+			c.t0 = c.t0.Add(time.Second)
+		}
+		c.log.Info("applied leap second to virtual clock", zap.Stringer("leap", c.leap))
+		c.leap = leap.None
+		c.publish()
+	}
+}
+
+// rebase starts a new segment at monotonic time m. c.mu must be held.
+func (c *VirtualClock) rebase(m time.Duration) {
+	c.settle(m)
+	c.t0 = c.at(m)
+	c.m0 = m
+}
+
+// publish writes the current segment to the file of c. c.mu must be held.
+func (c *VirtualClock) publish() {
+	if c.file == "" {
+		return
+	}
+	var synced int
+	if c.quality.Synchronized {
+		synced = 1
+	}
+	b := fmt.Sprintf("%d %d %.12e %d %d %d\n",
+		c.m0.Nanoseconds(), c.t0.UnixNano(), c.freq,
+		synced, c.quality.MaxError.Nanoseconds(), c.quality.EstError.Nanoseconds())
+	err := writeFile(c.file, []byte(b))
+	if err != nil {
+		c.log.Info("failed to write virtual time file",
+			zap.String("file", c.file), zap.Error(err))
+	}
+}
+
+func (c *VirtualClock) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := monotonic(c.log)
+	c.settle(m)
+	return c.at(m)
+}
+
+func (c *VirtualClock) MaxDrift(duration time.Duration) time.Duration {
+	return maxDrift(c.tolerance, 0, duration)
+}
+
+func (c *VirtualClock) Step(offset time.Duration) {
+	c.log.Debug("stepping virtual clock", zap.Duration("offset", offset))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rebase(monotonic(c.log))
+	if c.adjEnd != 0 {
+		c.freq = c.afterFreq
+		c.adjEnd = 0
+	}
+	c.t0 = c.t0.Add(offset)
+	if c.epoch == math.MaxUint64 {
+		panic("epoch overflow")
+	}
+	c.epoch++
+	c.publish()
+}
+
+func (c *VirtualClock) Adjust(offset, duration time.Duration, frequency float64) {
+	c.log.Debug("adjusting virtual clock",
+		zap.Duration("offset", offset),
+		zap.Duration("duration", duration),
+		zap.Float64("frequency", frequency),
+	)
+	if duration < 0 {
+		panic("invalid duration value")
+	}
+	duration = duration / time.Second * time.Second
+	if duration == 0 {
+		duration = time.Second
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := monotonic(c.log)
+	c.rebase(m)
+	c.freq = frequency + timemath.Seconds(offset)/timemath.Seconds(duration)
+	c.adjEnd = m + duration
+	c.afterFreq = frequency
+	c.publish()
+	time.AfterFunc(duration, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.settle(monotonic(c.log))
+	})
+}
+
+// SetLeap arms the clock to insert or delete a leap second at the end of the
+// current UTC day, or disarms it.
+func (c *VirtualClock) SetLeap(ind leap.Indicator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := monotonic(c.log)
+	c.settle(m)
+	c.leap = ind
+	if ind == leap.None {
+		return
+	}
+	now := c.at(m)
+	c.leapAt = now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if ind == leap.Delete {
+		c.leapAt = c.leapAt.Add(-time.Second)
+	}
+	time.AfterFunc(max(c.leapAt.Sub(now), 0), func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.settle(monotonic(c.log))
+	})
+}
+
+func (c *VirtualClock) Sleep(duration time.Duration) {
+	c.log.Debug("sleeping", zap.Duration("duration", duration))
+	if duration < 0 {
+		panic("invalid duration value")
+	}
+	time.Sleep(duration)
+}
+
+// FromSystemTime converts the system clock timestamp t into virtual time.
+func (c *VirtualClock) FromSystemTime(t time.Time) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := monotonic(c.log)
+	now := time.Now()
+	c.settle(m)
+	return c.at(m).Add(-now.Sub(t))
+}
+
+func (c *VirtualClock) SetSyncQuality(q timebase.SyncQuality) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quality = q
+	c.publish()
+}
+
+func (c *VirtualClock) SyncQuality() timebase.SyncQuality {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quality
+}
+
+// ReadVirtualTime reads the virtual time published by a VirtualClock to the
+// file name. The file contains a single line with the monotonic time and UTC
+// at the start of the current segment, in ns, the frequency correction, the
+// synchronization status (0 or 1), and the maximum and estimated error in ns.
+func ReadVirtualTime(name string) (VirtualTime, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return VirtualTime{}, err
+	}
+	fs := strings.Fields(string(b))
+	if len(fs) != 6 {
+		return VirtualTime{}, errInvalidVirtualTime
+	}
+	var ns [6]int64
+	for i := 0; i != len(fs); i++ {
+		if i == 2 {
+			continue
+		}
+		ns[i], err = strconv.ParseInt(fs[i], 10, 64)
+		if err != nil {
+			return VirtualTime{}, errInvalidVirtualTime
+		}
+	}
+	freq, err := strconv.ParseFloat(fs[2], 64)
+	if err != nil || math.IsNaN(freq) || math.IsInf(freq, 0) {
+		return VirtualTime{}, errInvalidVirtualTime
+	}
+	return VirtualTime{
+		Monotonic: time.Duration(ns[0]),
+		UTC:       time.Unix(0, ns[1]).UTC(),
+		Frequency: freq,
+		Quality: timebase.SyncQuality{
+			Synchronized: ns[3] != 0,
+			MaxError:     time.Duration(ns[4]),
+			EstError:     time.Duration(ns[5]),
+		},
+	}, nil
+}
+
+// writeFile atomically replaces the file name with data.
+func writeFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	err = os.Rename(f.Name(), name)
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package clock
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"golang.org/x/sys/unix"
+)
+
+// monotonic returns the current value of CLOCK_MONOTONIC.
+func monotonic(log *zap.Logger) time.Duration {
+	var ts unix.Timespec
+	err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	if err != nil {
+		log.Fatal("unix.ClockGettime failed", zap.Error(err))
+	}
+	return time.Duration(ts.Nano())
+}
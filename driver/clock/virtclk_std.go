@@ -0,0 +1,17 @@
+//go:build !linux
+
+package clock
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var monotonicStart = time.Now()
+
+// monotonic returns the time elapsed on Go's monotonic clock since the start
+// of the process. Unlike on Linux, it cannot be shared with other processes.
+func monotonic(log *zap.Logger) time.Duration {
+	return time.Since(monotonicStart)
+}
@@ -0,0 +1,91 @@
+package clock
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/leap"
+	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/base/timemath"
+)
+
+func TestVirtualClock(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "time")
+	c := NewVirtualClock(zap.NewNop(), 100e-6, name)
+
+	c.Step(time.Hour)
+	if c.Epoch() != 1 {
+		t.Errorf("c.Epoch() == %d; want 1", c.Epoch())
+	}
+	if d := c.Now().Sub(time.Now()); timemath.Abs(d-time.Hour) > 10*time.Millisecond {
+		t.Errorf("c.Step(1h) failed: offset %v", d)
+	}
+	ts := time.Now().Add(-time.Second)
+	if d := c.FromSystemTime(ts).Sub(c.Now()); timemath.Abs(d+time.Second) > 10*time.Millisecond {
+		t.Errorf("c.FromSystemTime(now-1s) == now%v", d)
+	}
+
+	c.Adjust(0, 10*time.Second, 1e-3)
+	q := timebase.SyncQuality{Synchronized: true, MaxError: time.Millisecond, EstError: time.Microsecond}
+	c.SetSyncQuality(q)
+	v, err := ReadVirtualTime(name)
+	if err != nil {
+		t.Fatalf("ReadVirtualTime failed: %v", err)
+	}
+	if v.Frequency != 1e-3 || v.Quality != q {
+		t.Errorf("ReadVirtualTime(...) == %+v", v)
+	}
+	if d := c.Now().Sub(v.At(monotonic(zap.NewNop()))); timemath.Abs(d) > 10*time.Millisecond {
+		t.Errorf("published virtual time off by %v", d)
+	}
+	if got, want := v.At(v.Monotonic+time.Second), v.UTC.Add(time.Second+time.Millisecond); !got.Equal(want) {
+		t.Errorf("v.At(+1s) == %v; want %v", got, want)
+	}
+}
+
+func TestVirtualClockLeap(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "time")
+	c := NewVirtualClock(zap.NewNop(), 100e-6, name)
+	for _, tc := range []struct {
+		ind    leap.Indicator
+		before time.Duration // offset from midnight when the leap is armed
+		want   time.Duration // offset from midnight 100ms later
+	}{
+		{leap.Insert, -50 * time.Millisecond, -950 * time.Millisecond},
+		{leap.Delete, -1050 * time.Millisecond, 50 * time.Millisecond},
+	} {
+		now := c.Now()
+		midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		c.Step(midnight.Add(tc.before).Sub(now))
+		epoch := c.Epoch()
+		c.SetLeap(tc.ind)
+		time.Sleep(100 * time.Millisecond)
+		if d := c.Now().Sub(midnight); timemath.Abs(d-tc.want) > 20*time.Millisecond {
+			t.Errorf("%v: c.Now() == midnight%+v; want midnight%+v", tc.ind, d, tc.want)
+		}
+		if c.Epoch() != epoch {
+			t.Errorf("%v: c.Epoch() == %d; want %d", tc.ind, c.Epoch(), epoch)
+		}
+		v, err := ReadVirtualTime(name)
+		if err != nil {
+			t.Fatalf("ReadVirtualTime failed: %v", err)
+		}
+		if d := c.Now().Sub(v.At(monotonic(zap.NewNop()))); timemath.Abs(d) > 10*time.Millisecond {
+			t.Errorf("%v: published virtual time off by %v", tc.ind, d)
+		}
+	}
+
+	// A disarmed leap second is not applied.
+	now := c.Now()
+	midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	c.Step(midnight.Add(-50 * time.Millisecond).Sub(now))
+	c.SetLeap(leap.Insert)
+	c.SetLeap(leap.None)
+	time.Sleep(100 * time.Millisecond)
+	if d := c.Now().Sub(midnight); timemath.Abs(d-50*time.Millisecond) > 20*time.Millisecond {
+		t.Errorf("c.Now() == midnight%+v after disarming; want midnight+50ms", d)
+	}
+}
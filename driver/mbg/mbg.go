@@ -14,6 +14,8 @@ import (
 	"go.uber.org/zap"
 
 	"golang.org/x/sys/unix"
+
+	"example.com/scion-time/base/leap"
 )
 
 const (
@@ -36,6 +38,11 @@ const (
 	ioctlTypeShift = ioctlSNShift + ioctlSNBits
 	ioctlSizeShift = ioctlTypeShift + ioctlTypeBits
 	ioctlDirShift  = ioctlSizeShift + ioctlSizeBits
+
+	// See PCPS_TIME_STATUS_X in mbglib pcpsdefs.h
+
+	statusLeapAnnounced    = 0x0020 // PCPS_LS_ANN
+	statusLeapAnnouncedNeg = 0x0400 // PCPS_LS_ANN_NEG
 )
 
 func ioctlRequest(d, s, t, n int) uint {
@@ -56,11 +63,24 @@ func nanoseconds(frac uint32) int64 {
 	return int64((uint64(frac) * uint64(time.Second)) / (1 << 32))
 }
 
-func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (time.Duration, error) {
+func leapIndicator(status uint16) leap.Indicator {
+	if status&statusLeapAnnounced == 0 {
+		return leap.None
+	}
+	if status&statusLeapAnnouncedNeg != 0 {
+		return leap.Delete
+	}
+	return leap.Insert
+}
+
+// MeasureClockOffset returns the offset of the reference clock dev relative to
+// the system clock and the leap second announced by the reference clock.
+func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (
+	time.Duration, leap.Indicator, error) {
 	fd, err := unix.Open(dev, unix.O_RDWR, 0)
 	if err != nil {
 		log.Error("unix.Open failed", zap.String("dev", dev), zap.Error(err))
-		return 0, err
+		return 0, leap.None, err
 	}
 	defer func(log *zap.Logger, dev string) {
 		err = unix.Close(fd)
@@ -81,7 +101,7 @@ func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (time.
 		uintptr(unsafe.Pointer(&featureData[0])))
 	if errno != 0 {
 		log.Error("ioctl failed (features) or HR time not supported", zap.String("dev", dev), zap.Error(errno))
-		return 0, errno
+		return 0, leap.None, errno
 	}
 
 	cycleFrequencyData := make([]byte, 8)
@@ -90,7 +110,7 @@ func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (time.
 		uintptr(unsafe.Pointer(&cycleFrequencyData[0])))
 	if errno != 0 {
 		log.Error("ioctl failed (cycle frequency)", zap.String("dev", dev), zap.Error(errno))
-		return 0, errno
+		return 0, leap.None, errno
 	}
 
 	cycleFrequency := binary.LittleEndian.Uint64(cycleFrequencyData[0:])
@@ -102,7 +122,7 @@ func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (time.
 		uintptr(unsafe.Pointer(&timeData[0])))
 	if errno != 0 {
 		log.Error("ioctl failed (time)", zap.String("dev", dev), zap.Error(errno))
-		return 0, errno
+		return 0, leap.None, errno
 	}
 
 	refTimeCycles := int64(binary.LittleEndian.Uint64(timeData[0:]))
@@ -133,5 +153,5 @@ func MeasureClockOffset(ctx context.Context, log *zap.Logger, dev string) (time.
 	)
 	log.Debug("mbg clock offset", zap.Duration("offset", refTime.Sub(sysTime)))
 
-	return refTime.Sub(sysTime), nil
+	return refTime.Sub(sysTime), leapIndicator(refTimeStatus), nil
 }
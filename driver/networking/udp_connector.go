@@ -4,7 +4,10 @@ import (
 	"example.com/scion-time/base/netprovider"
 	"example.com/scion-time/net/udp"
 	"net"
+	"strconv"
 	"time"
+
+	"github.com/libp2p/go-reuseport"
 )
 
 type UDPConnector struct {
@@ -15,6 +18,15 @@ func (U *UDPConnector) ListenUDP(network string, laddr *net.UDPAddr) (netprovide
 	return net.ListenUDP(network, laddr)
 }
 
+func (U *UDPConnector) ListenUDPReusePort(network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
+	conn, err := reuseport.ListenPacket(network,
+		net.JoinHostPort(laddr.IP.String(), strconv.Itoa(laddr.Port)))
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
 func (U *UDPConnector) EnableTimestamping(n netprovider.Connection, localHostIface string) error {
 	return udp.EnableTimestamping(n.(*net.UDPConn), localHostIface)
 }
@@ -1,8 +1,10 @@
 package ntp
 
 import (
+	"crypto/md5"
 	"encoding/binary"
 	"errors"
+	"net"
 	"time"
 )
 
@@ -19,6 +21,14 @@ const (
 	LeapIndicatorDeleteSecond = 2
 	LeapIndicatorUnknown      = 3
 
+	StratumUnspecified    = 0
+	StratumPrimary        = 1
+	StratumMax            = 15
+	StratumUnsynchronized = 16
+
+	ReferenceIDGNSS      = 0x474e5353 // "GNSS"
+	ReferenceIDLeapSmear = 0x534d4552 // "SMER"
+
 	VersionMin = 1
 	VersionMax = 4
 
@@ -62,6 +72,39 @@ var (
 	errUnexpectedPacketSize = errors.New("unexpected packet size")
 )
 
+func Time32FromDuration(d time.Duration) Time32 {
+	if d < 0 {
+		panic("unexpected negative duration")
+	}
+	n := d.Nanoseconds()
+	f := (n%nanosecondsPerSecond<<16 + nanosecondsPerSecond/2) / nanosecondsPerSecond
+	s := n/nanosecondsPerSecond + f>>16
+	if s >= 1<<16 {
+		return Time32{Seconds: 1<<16 - 1, Fraction: 1<<16 - 1}
+	}
+	return Time32{
+		Seconds:  uint16(s),
+		Fraction: uint16(f),
+	}
+}
+
+func DurationFromTime32(t Time32) time.Duration {
+	return time.Duration(
+		int64(t.Seconds)*nanosecondsPerSecond +
+			(int64(t.Fraction)*nanosecondsPerSecond+1<<15)>>16)
+}
+
+// ReferenceIDFromIP returns the reference ID of a server with address ip, see
+// RFC 5905, Section 7.3.
+func ReferenceIDFromIP(ip net.IP) uint32 {
+	ip4 := ip.To4()
+	if ip4 != nil {
+		return binary.BigEndian.Uint32(ip4)
+	}
+	h := md5.Sum(ip.To16())
+	return binary.BigEndian.Uint32(h[:4])
+}
+
 func Time64FromTime(t time.Time) Time64 {
 	d := t.Sub(epoch).Nanoseconds()
 	return Time64{
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"example.com/scion-time/base/timemath"
 	"example.com/scion-time/net/ntp"
 )
 
@@ -17,6 +18,21 @@ func TestTime64Conversion(t *testing.T) {
 	}
 }
 
+func TestTime32Conversion(t *testing.T) {
+	for _, d0 := range []time.Duration{
+		0, 15 * time.Microsecond, 1500 * time.Millisecond, 1<<16*time.Second - time.Millisecond,
+	} {
+		d1 := ntp.DurationFromTime32(ntp.Time32FromDuration(d0))
+		if timemath.Abs(d1-d0) > 8*time.Microsecond {
+			t.Errorf("DurationFromTime32(Time32FromDuration(%v)) == %v", d0, d1)
+		}
+	}
+	d := ntp.DurationFromTime32(ntp.Time32FromDuration(1 << 20 * time.Second))
+	if d >= 1<<16*time.Second {
+		t.Errorf("Time32FromDuration must saturate, got %v", d)
+	}
+}
+
 func TestBeforeAfter(t *testing.T) {
 	t0 := ntp.Time64{Seconds: 10, Fraction: 0}
 	t1 := ntp.Time64{Seconds: 20, Fraction: 0}
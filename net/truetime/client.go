@@ -0,0 +1,68 @@
+package truetime
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client queries the local time interval service. It is safe for concurrent
+// use.
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	buf  []byte
+}
+
+// ErrUnsynchronized is returned by Now if the time service cannot bound the
+// current time because the local clock is not synchronized.
+var ErrUnsynchronized = errors.New("local clock not synchronized")
+
+var errUnexpectedStatus = errors.New("unexpected response status")
+
+// Dial connects to the time interval service listening on the Unix domain
+// socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, buf: make([]byte, ResponseLen)}, nil
+}
+
+// Now returns an interval [earliest, latest] that contains the true time at
+// some instant during the call.
+func (c *Client) Now() (earliest, latest time.Time, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	EncodeRequest(&c.buf)
+	_, err = c.conn.Write(c.buf)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	c.buf = c.buf[:ResponseLen]
+	_, err = io.ReadFull(c.conn, c.buf)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	var resp Response
+	err = DecodeResponse(&resp, c.buf)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	switch resp.Status {
+	case StatusOK:
+		return resp.Earliest, resp.Latest, nil
+	case StatusUnsynchronized:
+		return time.Time{}, time.Time{}, ErrUnsynchronized
+	default:
+		return time.Time{}, time.Time{}, errUnexpectedStatus
+	}
+}
+
+// Close closes the connection to the service.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,87 @@
+// Package truetime implements the local time interval service. A client
+// connected to the service's Unix domain socket sends requests of RequestLen
+// bytes and receives a response of ResponseLen bytes for each of them: the
+// protocol version, a status, and, if the status is StatusOK, an interval
+// [Earliest, Latest] of Unix times in ns that contains the true time at which
+// the request was served.
+package truetime
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	Version = 1
+
+	RequestLen  = 1
+	ResponseLen = 18
+
+	StatusOK             = 0
+	StatusUnsynchronized = 1
+)
+
+type Response struct {
+	Status   uint8
+	Earliest time.Time
+	Latest   time.Time
+}
+
+var (
+	errUnexpectedRequestSize  = errors.New("unexpected request size")
+	errUnexpectedResponseSize = errors.New("unexpected response size")
+	errUnexpectedVersion      = errors.New("unexpected protocol version")
+)
+
+func EncodeRequest(b *[]byte) {
+	if cap(*b) < RequestLen {
+		*b = make([]byte, RequestLen)
+	} else {
+		*b = (*b)[:RequestLen]
+	}
+
+	(*b)[0] = Version
+}
+
+func DecodeRequest(b []byte) error {
+	if len(b) < RequestLen {
+		return errUnexpectedRequestSize
+	}
+	if b[0] != Version {
+		return errUnexpectedVersion
+	}
+	return nil
+}
+
+func EncodeResponse(b *[]byte, resp *Response) {
+	if cap(*b) < ResponseLen {
+		*b = make([]byte, ResponseLen)
+	} else {
+		*b = (*b)[:ResponseLen]
+	}
+
+	(*b)[0] = Version
+	(*b)[1] = resp.Status
+	var earliest, latest int64
+	if resp.Status == StatusOK {
+		earliest, latest = resp.Earliest.UnixNano(), resp.Latest.UnixNano()
+	}
+	binary.BigEndian.PutUint64((*b)[2:], uint64(earliest))
+	binary.BigEndian.PutUint64((*b)[10:], uint64(latest))
+}
+
+func DecodeResponse(resp *Response, b []byte) error {
+	if len(b) < ResponseLen {
+		return errUnexpectedResponseSize
+	}
+	if b[0] != Version {
+		return errUnexpectedVersion
+	}
+
+	resp.Status = b[1]
+	resp.Earliest = time.Unix(0, int64(binary.BigEndian.Uint64(b[2:]))).UTC()
+	resp.Latest = time.Unix(0, int64(binary.BigEndian.Uint64(b[10:]))).UTC()
+
+	return nil
+}
@@ -49,6 +49,20 @@ func TimestampFromOOBData(oob []byte) (time.Time, error) {
 	return time.Time{}, errTimestampNotFound
 }
 
+func EncodeTimestampOOBData(oob []byte, ts time.Time) int {
+	n := unix.CmsgSpace(int(unsafe.Sizeof(unix.Timeval{})))
+	if len(oob) < n {
+		panic("invalid argument to EncodeTimestampOOBData: insufficient buffer size")
+	}
+	clear(oob[:n])
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = unix.SOL_SOCKET
+	h.Type = unix.SCM_TIMESTAMP
+	h.SetLen(n)
+	*(*unix.Timeval)(unsafe.Pointer(&oob[unix.CmsgSpace(0)])) = unix.NsecToTimeval(ts.UnixNano())
+	return n
+}
+
 func EnableTimestamping(conn *net.UDPConn, iface string) error {
 	return errUnsupportedOperation
 }
@@ -71,6 +71,21 @@ func TimestampFromOOBData(oob []byte) (time.Time, error) {
 	return time.Time{}, errTimestampNotFound
 }
 
+func EncodeTimestampOOBData(oob []byte, ts time.Time) int {
+	n := unix.CmsgSpace(3 * 16)
+	if len(oob) < n {
+		panic("invalid argument to EncodeTimestampOOBData: insufficient buffer size")
+	}
+	clear(oob[:n])
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = unix.SOL_SOCKET
+	h.Type = unix.SO_TIMESTAMPING_NEW
+	h.SetLen(n)
+	*(*int64)(unsafe.Pointer(&oob[unix.CmsgSpace(0)])) = ts.Unix()
+	*(*int64)(unsafe.Pointer(&oob[unix.CmsgSpace(8)])) = int64(ts.Nanosecond())
+	return n
+}
+
 // For details on hardware timestamping configuration, see
 // - https://docs.kernel.org/networking/timestamping.html
 // - https://github.com/torvalds/linux/blob/master/include/uapi/linux/net_tstamp.h
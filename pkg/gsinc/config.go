@@ -0,0 +1,78 @@
+package gsinc
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/driver/clock"
+)
+
+const defaultTimeout = 1 * time.Second
+
+// Config configures a Client. LocalAddr and RemoteAddr are SCION UDP
+// addresses of the form "ISD-AS,host:port", where the ISD-AS 0-0 selects a
+// query via IP. Queries via SCION require the address of a SCION daemon in
+// DaemonAddr. If NTS is set, queries are authenticated with NTS, with the
+// keys established by the NTS-KE server NTSKEServer, which defaults to the
+// host and port of RemoteAddr. If SPAO is set, queries via SCION are
+// authenticated with the SCION packet authenticator option. Clock is the
+// local clock used to timestamp queries and defaults to the system clock.
+// Queries time out after Timeout. Zero fields select the default values.
+type Config struct {
+	Log                     *zap.Logger
+	Clock                   timebase.LocalClock
+	LocalAddr               string
+	RemoteAddr              string
+	DaemonAddr              string
+	DSCP                    uint8
+	Timeout                 time.Duration
+	NTS                     bool
+	NTSKEServer             string
+	NTSKEInsecureSkipVerify bool
+	SPAO                    bool
+}
+
+// Result is the result of a query at local time Time. Offset is the offset of
+// the local clock from the server clock, Delay is the round trip delay of the
+// query and Authenticated reports whether the response was authenticated.
+type Result struct {
+	Time          time.Time
+	Offset        time.Duration
+	Delay         time.Duration
+	Authenticated bool
+}
+
+var (
+	errInvalidTimeout    = errors.New("invalid query timeout")
+	errInvalidDSCP       = errors.New("invalid DSCP value")
+	errNoDaemonAddr      = errors.New("SCION daemon address not specified")
+	errSPAORequiresSCION = errors.New("SPAO requires a SCION remote address")
+	errNoPaths           = errors.New("no paths available")
+	errNoLocalIA         = errors.New("SCION queries require a SCION local address")
+)
+
+func (c Config) withDefaults() Config {
+	if c.Log == nil {
+		c.Log = zap.NewNop()
+	}
+	if c.Clock == nil {
+		c.Clock = &clock.SystemClock{Log: c.Log}
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	return c
+}
+
+func (c Config) validate() error {
+	if c.Timeout < 0 {
+		return errInvalidTimeout
+	}
+	if c.DSCP > 63 {
+		return errInvalidDSCP
+	}
+	return nil
+}
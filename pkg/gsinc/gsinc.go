@@ -0,0 +1,226 @@
+// Package gsinc implements an embeddable client for querying G-SINC time
+// servers via IP or SCION, optionally authenticated with NTS or, via SCION,
+// with SPAO. Unlike the time service, it does not rely on any globally
+// registered clock, crypto or network provider.
+package gsinc
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/scionproto/scion/pkg/daemon"
+	"github.com/scionproto/scion/pkg/snet"
+	"github.com/scionproto/scion/pkg/snet/path"
+
+	"example.com/scion-time/base/crypto"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/cryptobase"
+	"example.com/scion-time/core/netbase"
+	"example.com/scion-time/core/timebase"
+
+	"example.com/scion-time/driver/networking"
+
+	"example.com/scion-time/net/ntske"
+	"example.com/scion-time/net/scion"
+	"example.com/scion-time/net/udp"
+)
+
+const numSCIONClients = 5
+
+// Client queries a single G-SINC time server. Offsets are not filtered across
+// queries. A Client is safe for concurrent use, queries are serialized.
+type Client struct {
+	mu         sync.Mutex
+	cfg        Config
+	localAddr  *snet.UDPAddr
+	remoteAddr *snet.UDPAddr
+	dc         daemon.Connector
+	ipc        *client.IPClient
+	scioncs    []*client.SCIONClient
+	lcrypt     *crypto.SafeCrypto
+	lnet       *networking.UDPConnector
+}
+
+// New returns a client for the time server at cfg.RemoteAddr. Queries via
+// SCION connect to the SCION daemon, ctx only applies to the connection setup.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	cfg = cfg.withDefaults()
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+	localAddr, err := snet.ParseUDPAddr(cfg.LocalAddr)
+	if err != nil {
+		return nil, err
+	}
+	remoteAddr, err := snet.ParseUDPAddr(cfg.RemoteAddr)
+	if err != nil {
+		return nil, err
+	}
+	ntskeServer := cfg.NTSKEServer
+	if ntskeServer == "" {
+		ntskeServer = remoteAddr.Host.String()
+	}
+	c := &Client{
+		cfg:        cfg,
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		lcrypt:     &crypto.SafeCrypto{},
+		lnet:       &networking.UDPConnector{},
+	}
+
+	if remoteAddr.IA.IsZero() {
+		if cfg.SPAO {
+			return nil, errSPAORequiresSCION
+		}
+		c.ipc = &client.IPClient{
+			DSCP:            cfg.DSCP,
+			InterleavedMode: true,
+			Raw:             true,
+		}
+		if cfg.NTS {
+			c.ipc.Auth.Enabled = true
+			err = configureNTSKEFetcher(&c.ipc.Auth.NTSKEFetcher, cfg, ntskeServer)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+
+	if localAddr.IA.IsZero() {
+		return nil, errNoLocalIA
+	}
+	if cfg.DaemonAddr == "" {
+		return nil, errNoDaemonAddr
+	}
+	s := &daemon.Service{
+		Address: cfg.DaemonAddr,
+	}
+	c.dc, err = s.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var drkeyFetcher *scion.Fetcher
+	if cfg.SPAO {
+		drkeyFetcher = scion.NewFetcher(c.dc)
+	}
+	laddr := udp.UDPAddrFromSnet(localAddr)
+	raddr := udp.UDPAddrFromSnet(remoteAddr)
+	c.scioncs = make([]*client.SCIONClient, numSCIONClients)
+	for i := 0; i != len(c.scioncs); i++ {
+		c.scioncs[i] = &client.SCIONClient{
+			DSCP:            cfg.DSCP,
+			InterleavedMode: true,
+			Raw:             true,
+		}
+		if drkeyFetcher != nil {
+			c.scioncs[i].Auth.Enabled = true
+			c.scioncs[i].Auth.DRKeyFetcher = drkeyFetcher
+		}
+		if cfg.NTS {
+			c.scioncs[i].Auth.NTSEnabled = true
+			f := &c.scioncs[i].Auth.NTSKEFetcher
+			err = configureNTSKEFetcher(f, cfg, ntskeServer)
+			if err != nil {
+				_ = c.dc.Close()
+				return nil, err
+			}
+			f.QUIC.Enabled = true
+			f.QUIC.DaemonAddr = cfg.DaemonAddr
+			f.QUIC.LocalAddr = laddr
+			f.QUIC.RemoteAddr = raddr
+		}
+	}
+	return c, nil
+}
+
+func configureNTSKEFetcher(f *ntske.Fetcher, cfg Config, ntskeServer string) error {
+	ntskeHost, ntskePort, err := net.SplitHostPort(ntskeServer)
+	if err != nil {
+		return err
+	}
+	f.TLSConfig = tls.Config{
+		NextProtos:         []string{"ntske/1"},
+		InsecureSkipVerify: cfg.NTSKEInsecureSkipVerify,
+		ServerName:         ntskeHost,
+		MinVersion:         tls.VersionTLS13,
+	}
+	f.Port = ntskePort
+	f.Log = cfg.Log
+	return nil
+}
+
+// Query measures the offset of the local clock from the server clock. If ctx
+// has no earlier deadline, the query times out after the configured Timeout.
+func (c *Client) Query(ctx context.Context) (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx = timebase.WithClock(ctx, c.cfg.Clock)
+	ctx = cryptobase.WithCrypto(ctx, c.lcrypt)
+	ctx = netbase.WithNetProvider(ctx, c.lnet)
+	ctx, cancel := timebase.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	var m client.Measurement
+	var err error
+	if c.ipc != nil {
+		m, err = client.MeasureClockOffsetIP(ctx, c.cfg.Log, c.ipc,
+			c.localAddr.Host, c.remoteAddr.Host)
+	} else {
+		var ps []snet.Path
+		ps, err = c.paths(ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		m, err = client.MeasureClockOffsetSCION(ctx, c.cfg.Log, c.scioncs,
+			udp.UDPAddrFromSnet(c.localAddr), udp.UDPAddrFromSnet(c.remoteAddr), ps)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	c.cfg.Log.Debug("queried time server",
+		zap.String("from", c.cfg.RemoteAddr),
+		zap.Duration("offset", m.Offset),
+		zap.Duration("delay", m.Delay),
+		zap.Bool("auth", m.Authenticated),
+	)
+	return Result{
+		Time:          m.Time,
+		Offset:        m.Offset,
+		Delay:         m.Delay,
+		Authenticated: m.Authenticated,
+	}, nil
+}
+
+func (c *Client) paths(ctx context.Context) ([]snet.Path, error) {
+	if c.remoteAddr.IA.Equal(c.localAddr.IA) {
+		return []snet.Path{path.Path{
+			Src:           c.remoteAddr.IA,
+			Dst:           c.remoteAddr.IA,
+			DataplanePath: path.Empty{},
+		}}, nil
+	}
+	ps, err := c.dc.Paths(ctx, c.remoteAddr.IA, c.localAddr.IA, daemon.PathReqFlags{Refresh: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, errNoPaths
+	}
+	return ps, nil
+}
+
+// Close releases the connection to the SCION daemon, if any.
+func (c *Client) Close() error {
+	if c.dc == nil {
+		return nil
+	}
+	return c.dc.Close()
+}
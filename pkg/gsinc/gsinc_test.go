@@ -0,0 +1,105 @@
+package gsinc_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/base/crypto"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/cryptobase"
+	"example.com/scion-time/core/netbase"
+	"example.com/scion-time/core/server"
+	"example.com/scion-time/core/sync"
+	"example.com/scion-time/core/timebase"
+
+	"example.com/scion-time/driver/clock"
+	"example.com/scion-time/driver/networking"
+
+	"example.com/scion-time/pkg/gsinc"
+)
+
+type testReferenceClock struct{}
+
+func (c *testReferenceClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	return client.Measurement{
+		Delay:   2 * time.Millisecond,
+		Weight:  1000.0,
+		Stratum: 1,
+	}, nil
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("net.ListenUDP failed: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, cfg := range []gsinc.Config{
+		{LocalAddr: "0-0,127.0.0.1", RemoteAddr: "0-0,127.0.0.1:123", Timeout: -1},
+		{LocalAddr: "0-0,127.0.0.1", RemoteAddr: "0-0,127.0.0.1:123", DSCP: 64},
+		{LocalAddr: "0-0,127.0.0.1", RemoteAddr: "0-0,127.0.0.1:123", SPAO: true},
+		{LocalAddr: "0-0,127.0.0.1", RemoteAddr: "1-ff00:0:110,127.0.0.1:123"},
+		{LocalAddr: "1-ff00:0:111,127.0.0.1", RemoteAddr: "1-ff00:0:110,127.0.0.1:123"},
+		{LocalAddr: "127.0.0.1", RemoteAddr: "0-0,127.0.0.1:123"},
+	} {
+		c, err := gsinc.New(context.Background(), cfg)
+		if err == nil {
+			c.Close()
+			t.Errorf("gsinc.New(%+v) succeeded; want error", cfg)
+		}
+	}
+}
+
+func TestQueryIP(t *testing.T) {
+	lclk := &clock.SystemClock{Log: zap.NewNop()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx = timebase.WithClock(ctx, lclk)
+	ctx = cryptobase.WithCrypto(ctx, &crypto.SafeCrypto{})
+	ctx = netbase.WithNetProvider(ctx, &networking.UDPConnector{})
+	ctx = sync.WithClocks(ctx,
+		[]client.ReferenceClock{&testReferenceClock{}}, nil, sync.Config{})
+	ctx = server.WithTimestampStore(ctx)
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+
+	port := freeUDPPort(t)
+	server.StartIPServer(ctx, zap.NewNop(),
+		&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 0, nil)
+
+	c, err := gsinc.New(context.Background(), gsinc.Config{
+		LocalAddr:  "0-0,127.0.0.1",
+		RemoteAddr: fmt.Sprintf("0-0,127.0.0.1:%d", port),
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("gsinc.New failed: %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i != 2; i++ {
+		r, err := c.Query(context.Background())
+		if err != nil {
+			t.Fatalf("c.Query() failed: %v", err)
+		}
+		if r.Authenticated {
+			t.Errorf("c.Query() returned authenticated result without authentication")
+		}
+		if r.Offset < -10*time.Millisecond || r.Offset > 10*time.Millisecond {
+			t.Errorf("c.Query() returned offset %v; want ~0", r.Offset)
+		}
+		if r.Delay < 0 || r.Delay > 100*time.Millisecond {
+			t.Errorf("c.Query() returned delay %v; want small positive value", r.Delay)
+		}
+	}
+}
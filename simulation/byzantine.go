@@ -0,0 +1,210 @@
+package simulation
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+
+	"github.com/scionproto/scion/pkg/slayers"
+
+	"example.com/scion-time/net/ntp"
+)
+
+const (
+	BehaviorConstantOffset = "constant_offset"
+	BehaviorRampOffset     = "ramp_offset"
+	BehaviorTwoFaced       = "two_faced"
+	BehaviorReplay         = "replay"
+	BehaviorDelay          = "delay"
+)
+
+// behavior is the adversarial behavior of a malicious instance. It is applied
+// to every NTP response the instance sends, via IP or SCION.
+type behavior interface {
+	// tamper returns the response to be sent instead of b to dst at true time
+	// now, or nil if no response is to be sent, and an additional delay for
+	// the response. path is the ID of the simulated SCION path along which
+	// the response is sent, or 0 if it is not sent along such a path.
+	tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration)
+}
+
+type activeFrom struct {
+	start time.Time
+	b     behavior
+}
+
+type constantOffset struct {
+	offset time.Duration
+}
+
+type rampOffset struct {
+	start  time.Time
+	offset time.Duration
+	rate   float64
+}
+
+type twoFaced struct {
+	offset  time.Duration
+	targets map[netip.Addr]bool
+}
+
+type sentResponse struct {
+	at time.Time
+	b  []byte
+}
+
+type replay struct {
+	age  time.Duration
+	mu   sync.Mutex
+	sent map[netip.Addr][]sentResponse
+}
+
+type selectiveDelay struct {
+	delay   time.Duration
+	targets map[netip.Addr]bool
+	paths   map[uint16]bool
+}
+
+func newBehavior(cfg *MaliciousConfig, start time.Time, targets map[netip.Addr]bool) behavior {
+	var b behavior
+	switch cfg.Behavior {
+	case BehaviorConstantOffset:
+		b = &constantOffset{offset: time.Duration(cfg.Offset)}
+	case BehaviorRampOffset:
+		b = &rampOffset{
+			start:  start.Add(time.Duration(cfg.Start)),
+			offset: time.Duration(cfg.Offset),
+			rate:   cfg.Rate,
+		}
+	case BehaviorTwoFaced:
+		b = &twoFaced{offset: time.Duration(cfg.Offset), targets: targets}
+	case BehaviorReplay:
+		b = &replay{age: time.Duration(cfg.Age), sent: make(map[netip.Addr][]sentResponse)}
+	case BehaviorDelay:
+		paths := make(map[uint16]bool, len(cfg.Paths))
+		for _, p := range cfg.Paths {
+			paths[uint16(p)] = true
+		}
+		b = &selectiveDelay{delay: time.Duration(cfg.Delay), targets: targets, paths: paths}
+	default:
+		panic("unexpected malicious behavior")
+	}
+	if cfg.Start != 0 {
+		b = &activeFrom{start: start.Add(time.Duration(cfg.Start)), b: b}
+	}
+	return b
+}
+
+func shiftTime64(t ntp.Time64, d time.Duration) ntp.Time64 {
+	if t == (ntp.Time64{}) {
+		return t
+	}
+	return ntp.Time64FromTime(ntp.TimeFromTime64(t).Add(d))
+}
+
+// shiftTimestamps shifts the server timestamps of an NTP response by d.
+func shiftTimestamps(b []byte, d time.Duration) []byte {
+	var pkt ntp.Packet
+	err := ntp.DecodePacket(&pkt, b)
+	if err != nil || pkt.Mode() != ntp.ModeServer {
+		return b
+	}
+	pkt.ReferenceTime = shiftTime64(pkt.ReferenceTime, d)
+	pkt.ReceiveTime = shiftTime64(pkt.ReceiveTime, d)
+	pkt.TransmitTime = shiftTime64(pkt.TransmitTime, d)
+	h := b[:ntp.PacketLen]
+	ntp.EncodePacket(&h, &pkt)
+	return b
+}
+
+func (a *activeFrom) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	if now.Before(a.start) {
+		return b, 0
+	}
+	return a.b.tamper(now, dst, path, b)
+}
+
+func (c *constantOffset) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	return shiftTimestamps(b, c.offset), 0
+}
+
+func (r *rampOffset) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	d := r.offset
+	if now.After(r.start) {
+		d += time.Duration(r.rate * float64(now.Sub(r.start)))
+	}
+	return shiftTimestamps(b, d), 0
+}
+
+func (f *twoFaced) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	if f.targets[dst] {
+		return shiftTimestamps(b, f.offset), 0
+	}
+	return shiftTimestamps(b, -f.offset), 0
+}
+
+func (r *replay) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rs := r.sent[dst]
+	i := 0
+	for i != len(rs) && !rs[i].at.After(now.Add(-r.age)) {
+		i++
+	}
+	var old []byte
+	if i != 0 {
+		old = rs[i-1].b
+		rs = rs[i:]
+	}
+	r.sent[dst] = append(rs, sentResponse{at: now, b: append([]byte(nil), b...)})
+	return old, 0
+}
+
+func (s *selectiveDelay) tamper(now time.Time, dst netip.Addr, path uint16, b []byte) ([]byte, time.Duration) {
+	if (len(s.targets) == 0 || s.targets[dst]) && (len(s.paths) == 0 || s.paths[path]) {
+		return b, s.delay
+	}
+	return b, 0
+}
+
+// tamperSCION applies bhv to the NTP response from host src carried in the
+// SCION packet b and returns the packet to be sent instead, or nil, together
+// with the additional delay. Other packets, e.g., responses from other hosts
+// forwarded by src, are returned unchanged.
+func tamperSCION(bhv behavior, now time.Time, src netip.Addr, b []byte) ([]byte, time.Duration) {
+	var scionLayer slayers.SCION
+	var udpLayer slayers.UDP
+	err := scionLayer.DecodeFromBytes(b, gopacket.NilDecodeFeedback)
+	if err != nil || scionLayer.NextHdr != slayers.L4UDP {
+		return b, 0
+	}
+	err = udpLayer.DecodeFromBytes(scionLayer.Payload, gopacket.NilDecodeFeedback)
+	if err != nil || udpLayer.SrcPort != ntp.ServerPortSCION {
+		return b, 0
+	}
+	srcHost, ok := netip.AddrFromSlice(scionLayer.RawSrcAddr)
+	if !ok || srcHost.Unmap() != src {
+		return b, 0
+	}
+	dst, ok := netip.AddrFromSlice(scionLayer.RawDstAddr)
+	if !ok {
+		return b, 0
+	}
+	payload, delay := bhv.tamper(now, dst.Unmap(), pathID(&scionLayer),
+		append([]byte(nil), udpLayer.Payload...))
+	if payload == nil {
+		return nil, delay
+	}
+	udpLayer.SetNetworkLayerForChecksum(&scionLayer)
+	buffer := gopacket.NewSerializeBuffer()
+	err = gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}, &scionLayer, &udpLayer, gopacket.Payload(payload))
+	if err != nil {
+		panic(err)
+	}
+	return buffer.Bytes(), delay
+}
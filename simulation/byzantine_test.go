@@ -0,0 +1,174 @@
+package simulation
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"example.com/scion-time/net/ntp"
+)
+
+func serverResponse(t time.Time) []byte {
+	var pkt ntp.Packet
+	pkt.SetVersion(ntp.VersionMax)
+	pkt.SetMode(ntp.ModeServer)
+	pkt.ReceiveTime = ntp.Time64FromTime(t)
+	pkt.TransmitTime = ntp.Time64FromTime(t.Add(time.Microsecond))
+	var b []byte
+	ntp.EncodePacket(&b, &pkt)
+	return b
+}
+
+func receiveTime(t *testing.T, b []byte) time.Time {
+	var pkt ntp.Packet
+	err := ntp.DecodePacket(&pkt, b)
+	if err != nil {
+		t.Fatalf("DecodePacket failed: %v", err)
+	}
+	return ntp.TimeFromTime64(pkt.ReceiveTime)
+}
+
+func TestBehaviors(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	x := netip.MustParseAddr("10.0.0.1")
+	y := netip.MustParseAddr("10.0.0.2")
+	targets := map[netip.Addr]bool{x: true}
+
+	b := newBehavior(&MaliciousConfig{
+		Behavior: BehaviorConstantOffset,
+		Offset:   Duration(time.Second),
+	}, t0, nil)
+	r, _ := b.tamper(t0, x, 0, serverResponse(t0))
+	if rxt := receiveTime(t, r); !rxt.Equal(t0.Add(time.Second)) {
+		t.Errorf("constant offset: rx time == %v; want %v", rxt, t0.Add(time.Second))
+	}
+
+	b = newBehavior(&MaliciousConfig{
+		Behavior: BehaviorRampOffset,
+		Start:    Duration(time.Minute),
+		Rate:     1e-3,
+	}, t0, nil)
+	t1 := t0.Add(2 * time.Minute)
+	r, _ = b.tamper(t1, x, 0, serverResponse(t1))
+	if rxt := receiveTime(t, r); !rxt.Equal(t1.Add(60 * time.Millisecond)) {
+		t.Errorf("ramp offset: rx time == %v; want %v", rxt, t1.Add(60*time.Millisecond))
+	}
+
+	b = newBehavior(&MaliciousConfig{
+		Behavior: BehaviorTwoFaced,
+		Offset:   Duration(time.Second),
+	}, t0, targets)
+	r, _ = b.tamper(t0, y, 0, serverResponse(t0))
+	if rxt := receiveTime(t, r); !rxt.Equal(t0.Add(-time.Second)) {
+		t.Errorf("two-faced: rx time == %v; want %v", rxt, t0.Add(-time.Second))
+	}
+
+	b = newBehavior(&MaliciousConfig{
+		Behavior: BehaviorReplay,
+		Age:      Duration(time.Minute),
+	}, t0, nil)
+	r, _ = b.tamper(t0, x, 0, serverResponse(t0))
+	if r != nil {
+		t.Errorf("replay: unexpected response before age")
+	}
+	r, _ = b.tamper(t1, x, 0, serverResponse(t1))
+	if rxt := receiveTime(t, r); !rxt.Equal(t0) {
+		t.Errorf("replay: rx time == %v; want %v", rxt, t0)
+	}
+
+	b = newBehavior(&MaliciousConfig{
+		Behavior: BehaviorDelay,
+		Delay:    Duration(time.Millisecond),
+	}, t0, targets)
+	_, d0 := b.tamper(t0, x, 0, serverResponse(t0))
+	_, d1 := b.tamper(t0, y, 0, serverResponse(t0))
+	if d0 != time.Millisecond || d1 != 0 {
+		t.Errorf("delay: delays == %v, %v; want %v, 0", d0, d1, time.Millisecond)
+	}
+}
+
+func scionResponse(t *testing.T, src, dst netip.Addr, p snet.Path, b []byte) []byte {
+	var scionLayer slayers.SCION
+	var udpLayer slayers.UDP
+	scionLayer.SrcIA, scionLayer.DstIA = p.Source(), p.Destination()
+	err := scionLayer.SetSrcAddr(addr.HostIP(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = scionLayer.SetDstAddr(addr.HostIP(dst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Dataplane().SetPath(&scionLayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scionLayer.NextHdr = slayers.L4UDP
+	udpLayer.SrcPort, udpLayer.DstPort = ntp.ServerPortSCION, 32768
+	udpLayer.SetNetworkLayerForChecksum(&scionLayer)
+	buffer := gopacket.NewSerializeBuffer()
+	err = gopacket.SerializeLayers(buffer, gopacket.SerializeOptions{
+		ComputeChecksums: true,
+		FixLengths:       true,
+	}, &scionLayer, &udpLayer, gopacket.Payload(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func scionPayload(t *testing.T, b []byte) []byte {
+	var scionLayer slayers.SCION
+	var udpLayer slayers.UDP
+	err := scionLayer.DecodeFromBytes(b, gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatalf("DecodeFromBytes failed: %v", err)
+	}
+	err = udpLayer.DecodeFromBytes(scionLayer.Payload, gopacket.NilDecodeFeedback)
+	if err != nil {
+		t.Fatalf("DecodeFromBytes failed: %v", err)
+	}
+	return udpLayer.Payload
+}
+
+func TestBehaviorsSCION(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	x := netip.MustParseAddr("10.0.0.1")
+	y := netip.MustParseAddr("10.0.1.1")
+	nw := NewNetwork(NewScheduler(t0), 0)
+	ia0 := addr.MustIAFrom(1, 0xff00_0000_0110)
+	ia1 := addr.MustIAFrom(1, 0xff00_0000_0111)
+	ps := nw.addPaths(ia0, ia1, t0, &PathConfig{Count: 2})
+
+	b := newBehavior(&MaliciousConfig{
+		Behavior: BehaviorConstantOffset,
+		Offset:   Duration(time.Second),
+	}, t0, nil)
+	pkt := scionResponse(t, x, y, ps[0].fwd, serverResponse(t0))
+	r, _ := tamperSCION(b, t0, x, pkt)
+	if rxt := receiveTime(t, scionPayload(t, r)); !rxt.Equal(t0.Add(time.Second)) {
+		t.Errorf("constant offset via SCION: rx time == %v; want %v", rxt, t0.Add(time.Second))
+	}
+	r, _ = tamperSCION(b, t0, y, pkt)
+	if !bytes.Equal(r, pkt) {
+		t.Errorf("constant offset via SCION: forwarded response modified")
+	}
+
+	b = newBehavior(&MaliciousConfig{
+		Behavior: BehaviorDelay,
+		Delay:    Duration(time.Millisecond),
+		Paths:    []int{int(ps[1].id)},
+	}, t0, nil)
+	_, d0 := tamperSCION(b, t0, x, scionResponse(t, x, y, ps[0].fwd, serverResponse(t0)))
+	_, d1 := tamperSCION(b, t0, x, scionResponse(t, x, y, ps[1].fwd, serverResponse(t0)))
+	if d0 != 0 || d1 != time.Millisecond {
+		t.Errorf("delay via SCION: delays == %v, %v; want 0, %v", d0, d1, time.Millisecond)
+	}
+}
@@ -0,0 +1,248 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/base/timemath"
+	"example.com/scion-time/core/client"
+)
+
+const (
+	defaultSampleInterval = 1 * time.Second
+)
+
+// OffsetSample is a sample of the offset of an instance's local clock from
+// true time, together with the sum of all corrections applied to the local
+// clock since the previous sample and the synchronization quality reported to
+// the local clock.
+type OffsetSample struct {
+	Time         time.Time     `json:"time"`
+	Offset       time.Duration `json:"offset_ns"`
+	Correction   time.Duration `json:"correction_ns"`
+	Synchronized bool          `json:"synchronized"`
+	MaxError     time.Duration `json:"max_error_ns"`
+}
+
+// Correction is a step or an adjustment of an instance's local clock as
+// requested by the instance's clock synchronization.
+type Correction struct {
+	Time      time.Time     `json:"time"`
+	Step      bool          `json:"step"`
+	Offset    time.Duration `json:"offset_ns"`
+	Duration  time.Duration `json:"duration_ns,omitempty"`
+	Frequency float64       `json:"frequency,omitempty"`
+}
+
+// PeerMeasurement is a filtered clock offset measurement to a peer.
+type PeerMeasurement struct {
+	Time      time.Time     `json:"time"`
+	Reference string        `json:"reference"`
+	Offset    time.Duration `json:"offset_ns"`
+	Weight    float64       `json:"weight"`
+	Branch    int           `json:"branch"`
+}
+
+type InstanceResults struct {
+	Name         string            `json:"name"`
+	Malicious    bool              `json:"malicious"`
+	Offsets      []OffsetSample    `json:"offsets"`
+	Corrections  []Correction      `json:"corrections"`
+	Measurements []PeerMeasurement `json:"measurements"`
+	MaxDeviation time.Duration     `json:"max_deviation_ns"`
+}
+
+// Summary describes the precision and accuracy achieved by all instances
+// which are not malicious, disregarding the settling time at the beginning of
+// a simulation. MaxSkew is the maximum offset between any two local clocks and
+// MaxDeviation the maximum offset of any local clock from true time.
+type Summary struct {
+	MaxSkew      time.Duration `json:"max_skew_ns"`
+	MaxDeviation time.Duration `json:"max_deviation_ns"`
+}
+
+type Results struct {
+	Seed      int64              `json:"seed"`
+	Start     time.Time          `json:"start"`
+	End       time.Time          `json:"end"`
+	Instances []*InstanceResults `json:"instances"`
+	Summary   Summary            `json:"summary"`
+}
+
+type instanceRecorder struct {
+	sched *Scheduler
+	mu    sync.Mutex
+	res   *InstanceResults
+	corr  time.Duration
+}
+
+// recordingClock is a SimulationClock that records all corrections.
+type recordingClock struct {
+	*SimulationClock
+	rec *instanceRecorder
+}
+
+var _ client.FilterObserver = (*instanceRecorder)(nil)
+
+func newInstanceRecorder(sched *Scheduler, name string, malicious bool) *instanceRecorder {
+	return &instanceRecorder{
+		sched: sched,
+		res:   &InstanceResults{Name: name, Malicious: malicious},
+	}
+}
+
+func (r *instanceRecorder) ObserveFilteredMeasurement(reference string, branch int,
+	offset time.Duration, weight float64) {
+	now := r.sched.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.res.Measurements = append(r.res.Measurements, PeerMeasurement{
+		Time:      now,
+		Reference: reference,
+		Offset:    offset,
+		Weight:    weight,
+		Branch:    branch,
+	})
+}
+
+func (r *instanceRecorder) recordCorrection(c Correction) {
+	c.Time = r.sched.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.res.Corrections = append(r.res.Corrections, c)
+	r.corr += c.Offset
+}
+
+func (r *instanceRecorder) recordOffset(t time.Time, offset time.Duration,
+	q timebase.SyncQuality) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.res.Offsets = append(r.res.Offsets, OffsetSample{
+		Time:         t,
+		Offset:       offset,
+		Correction:   r.corr,
+		Synchronized: q.Synchronized,
+		MaxError:     q.MaxError,
+	})
+	r.corr = 0
+}
+
+func (c *recordingClock) Step(offset time.Duration) {
+	c.rec.recordCorrection(Correction{Step: true, Offset: offset})
+	c.SimulationClock.Step(offset)
+}
+
+func (c *recordingClock) Adjust(offset, duration time.Duration, frequency float64) {
+	c.rec.recordCorrection(Correction{Offset: offset, Duration: duration, Frequency: frequency})
+	c.SimulationClock.Adjust(offset, duration, frequency)
+}
+
+func summarize(res *Results, settled time.Time) {
+	var n int
+	for _, x := range res.Instances {
+		for _, s := range x.Offsets {
+			if !s.Time.Before(settled) && timemath.Abs(s.Offset) > x.MaxDeviation {
+				x.MaxDeviation = timemath.Abs(s.Offset)
+			}
+		}
+		if !x.Malicious {
+			if x.MaxDeviation > res.Summary.MaxDeviation {
+				res.Summary.MaxDeviation = x.MaxDeviation
+			}
+			if n == 0 || len(x.Offsets) < n {
+				n = len(x.Offsets)
+			}
+		}
+	}
+	// All instances are sampled at the same times.
+	for i := 0; i != n; i++ {
+		var lo, hi time.Duration
+		var ok bool
+		for _, x := range res.Instances {
+			if x.Malicious || x.Offsets[i].Time.Before(settled) {
+				continue
+			}
+			off := x.Offsets[i].Offset
+			if !ok || off < lo {
+				lo = off
+			}
+			if !ok || off > hi {
+				hi = off
+			}
+			ok = true
+		}
+		if hi-lo > res.Summary.MaxSkew {
+			res.Summary.MaxSkew = hi - lo
+		}
+	}
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(timemath.Seconds(d), 'g', -1, 64)
+}
+
+func writeCSV(file string, records [][]string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	err = w.WriteAll(records)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteCSV writes the results of each instance to dir: the file <name>.csv
+// contains the time, the offset from true time in seconds, and the correction
+// in seconds of each offset sample; <name>-peers.csv contains the time,
+// reference, offset in seconds, weight, and filter branch of each peer
+// measurement. The files have no header lines.
+func (res *Results) WriteCSV(dir string) error {
+	for _, x := range res.Instances {
+		var records [][]string
+		for _, s := range x.Offsets {
+			records = append(records, []string{
+				s.Time.Format(time.RFC3339Nano),
+				formatSeconds(s.Offset),
+				formatSeconds(s.Correction),
+			})
+		}
+		err := writeCSV(filepath.Join(dir, x.Name+".csv"), records)
+		if err != nil {
+			return err
+		}
+		records = records[:0]
+		for _, m := range x.Measurements {
+			records = append(records, []string{
+				m.Time.Format(time.RFC3339Nano),
+				m.Reference,
+				formatSeconds(m.Offset),
+				strconv.FormatFloat(m.Weight, 'g', -1, 64),
+				strconv.Itoa(m.Branch),
+			})
+		}
+		err = writeCSV(filepath.Join(dir, x.Name+"-peers.csv"), records)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes all results to file. Durations are given in nanoseconds.
+func (res *Results) WriteJSON(file string) error {
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0o644)
+}
@@ -0,0 +1,505 @@
+package simulation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"example.com/scion-time/core/sync"
+)
+
+const (
+	InstanceTypeServer = "server"
+	InstanceTypeRelay  = "relay"
+	InstanceTypeClient = "client"
+)
+
+var (
+	errInvalidScenario = errors.New("invalid scenario")
+)
+
+// Duration is a time.Duration that is specified as a string like "1m30s" in
+// scenario files.
+type Duration time.Duration
+
+// SimConfig describes a simulation scenario. The local clock offsets of all
+// instances are sampled every SampleInterval; samples taken during the
+// SettlingTime at the beginning of a simulation do not count towards the
+// result summary.
+type SimConfig struct {
+	StartTime      time.Time        `toml:"start_time,omitempty"`
+	Duration       Duration         `toml:"duration"`
+	SampleInterval Duration         `toml:"sample_interval,omitempty"`
+	SettlingTime   Duration         `toml:"settling_time,omitempty"`
+	DefaultLink    LinkConfig       `toml:"default_link,omitempty"`
+	Instances      []InstanceConfig `toml:"instances"`
+	Links          []LinkConfig     `toml:"links,omitempty"`
+	Paths          []PathConfig     `toml:"paths,omitempty"`
+	Failures       []FailureConfig  `toml:"failures,omitempty"`
+}
+
+// InstanceConfig describes a simulated time service instance. Reference
+// clocks and peers are specified like in the configuration of a regular time
+// service instance; MBG reference clocks are simulated as sources of true
+// time. Instances in an AS other than 0-0 also serve and measure via SCION.
+type InstanceConfig struct {
+	Name                string           `toml:"name"`
+	Type                string           `toml:"type"`
+	LocalAddr           string           `toml:"local_address"`
+	MBGReferenceClocks  []string         `toml:"mbg_reference_clocks,omitempty"`
+	NTPReferenceClocks  []string         `toml:"ntp_reference_clocks,omitempty"`
+	SCIONPeers          []string         `toml:"scion_peers,omitempty"`
+	Clock               ClockConfig      `toml:"clock,omitempty"`
+	Malicious           *MaliciousConfig `toml:"malicious,omitempty"`
+	FailureChance       float64          `toml:"failure_chance,omitempty"` // per minute
+	MeanFailureDuration Duration         `toml:"mean_failure_duration,omitempty"`
+	MinFailureDuration  Duration         `toml:"min_failure_duration,omitempty"`
+	MaxFailureDuration  Duration         `toml:"max_failure_duration,omitempty"`
+	sync.FileConfig
+}
+
+// ClockConfig overrides the parameters of an instance's clock model which are
+// otherwise drawn from the simulation seed.
+type ClockConfig struct {
+	Offset    *Duration `toml:"offset,omitempty"`
+	Frequency *float64  `toml:"frequency,omitempty"`
+	Wander    *float64  `toml:"wander,omitempty"`
+	Noise     *Duration `toml:"noise,omitempty"`
+	Tolerance *float64  `toml:"tolerance,omitempty"`
+}
+
+// MaliciousConfig makes an instance serve manipulated responses, starting at
+// Start after the beginning of the simulation. Depending on the behavior,
+//   - constant_offset shifts all server timestamps by Offset,
+//   - ramp_offset shifts them by Offset plus Rate times the elapsed time,
+//   - two_faced shifts them by Offset for the Targets and by -Offset for all
+//     other clients,
+//   - replay sends the response sent to the same client at least Age earlier
+//     instead of a fresh one, and
+//   - delay delays the responses to the Targets (or to all clients if there
+//     are no Targets) by Delay. If Paths is set, only responses sent via SCION
+//     along these paths are delayed. Paths are numbered from 1 in the order
+//     of the path configurations, each of which describes Count paths.
+type MaliciousConfig struct {
+	Behavior string   `toml:"behavior"`
+	Start    Duration `toml:"start,omitempty"`
+	Offset   Duration `toml:"offset,omitempty"`
+	Rate     float64  `toml:"rate,omitempty"`
+	Age      Duration `toml:"age,omitempty"`
+	Delay    Duration `toml:"delay,omitempty"`
+	Targets  []string `toml:"targets,omitempty"`
+	Paths    []int    `toml:"paths,omitempty"`
+}
+
+// LinkConfig describes the impairments of the link between two instances.
+type LinkConfig struct {
+	From                            string   `toml:"from,omitempty"`
+	To                              string   `toml:"to,omitempty"`
+	Bidirectional                   bool     `toml:"bidirectional,omitempty"`
+	FailureChance                   float64  `toml:"failure_chance,omitempty"` // per minute
+	MeanFailureDuration             Duration `toml:"mean_failure_duration,omitempty"`
+	MinFailureDuration              Duration `toml:"min_failure_duration,omitempty"`
+	MaxFailureDuration              Duration `toml:"max_failure_duration,omitempty"`
+	DropChance                      float64  `toml:"drop_chance,omitempty"`
+	DuplicationChance               float64  `toml:"duplication_chance,omitempty"`
+	MaxDuplicates                   int32    `toml:"max_duplicates,omitempty"`
+	MultipleDuplicateChanceModifier float64  `toml:"multiple_duplicate_chance_modifier,omitempty"`
+	CorruptionChance                float64  `toml:"corruption_chance,omitempty"`
+	CorruptionSeverity              float64  `toml:"corruption_severity,omitempty"`
+	MeanLatency                     Duration `toml:"mean_latency,omitempty"`
+	MinLatency                      Duration `toml:"min_latency,omitempty"`
+	MaxLatency                      Duration `toml:"max_latency,omitempty"`
+}
+
+// PathConfig describes Count (or one) simulated SCION paths between the ASes
+// From and To which can be used in both directions. Packets sent from To to
+// From take Asymmetry longer than packets sent from From to To, and vice versa
+// if Asymmetry is negative. From AttackStart after the beginning of the
+// simulation on, an on-path attacker delays all packets sent from From to To
+// by AttackDelay. Packets sent along the paths are dropped, duplicated and
+// corrupted like packets sent via a link. A failed path is withdrawn: it is
+// not handed out to instances and does not forward any packets.
+type PathConfig struct {
+	From                            string   `toml:"from"`
+	To                              string   `toml:"to"`
+	Count                           int      `toml:"count,omitempty"`
+	Asymmetry                       Duration `toml:"asymmetry,omitempty"`
+	AttackStart                     Duration `toml:"attack_start,omitempty"`
+	AttackDelay                     Duration `toml:"attack_delay,omitempty"`
+	FailureChance                   float64  `toml:"failure_chance,omitempty"` // per minute
+	MeanFailureDuration             Duration `toml:"mean_failure_duration,omitempty"`
+	MinFailureDuration              Duration `toml:"min_failure_duration,omitempty"`
+	MaxFailureDuration              Duration `toml:"max_failure_duration,omitempty"`
+	DropChance                      float64  `toml:"drop_chance,omitempty"`
+	DuplicationChance               float64  `toml:"duplication_chance,omitempty"`
+	MaxDuplicates                   int32    `toml:"max_duplicates,omitempty"`
+	MultipleDuplicateChanceModifier float64  `toml:"multiple_duplicate_chance_modifier,omitempty"`
+	CorruptionChance                float64  `toml:"corruption_chance,omitempty"`
+	CorruptionSeverity              float64  `toml:"corruption_severity,omitempty"`
+	MeanLatency                     Duration `toml:"mean_latency,omitempty"`
+	MinLatency                      Duration `toml:"min_latency,omitempty"`
+	MaxLatency                      Duration `toml:"max_latency,omitempty"`
+}
+
+// FailureConfig schedules the failure of an instance or, if Peer is set, of
+// the link between Instance and Peer in both directions. A failed instance or
+// link does not forward any packets.
+type FailureConfig struct {
+	Instance string   `toml:"instance"`
+	Peer     string   `toml:"peer,omitempty"`
+	At       Duration `toml:"at"`
+	Duration Duration `toml:"duration"`
+}
+
+type connection struct {
+	dropChance                      float64
+	duplicationChance               float64
+	maxDuplicates                   int32
+	multipleDuplicateChanceModifier float64
+	corruptionChance                float64
+	corruptionSeverity              float64
+	meanLatency                     time.Duration
+	minLatency                      time.Duration
+	maxLatency                      time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	x, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(x)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (c *LinkConfig) connection() connection {
+	return connection{
+		dropChance:                      c.DropChance,
+		duplicationChance:               c.DuplicationChance,
+		maxDuplicates:                   c.MaxDuplicates,
+		multipleDuplicateChanceModifier: c.MultipleDuplicateChanceModifier,
+		corruptionChance:                c.CorruptionChance,
+		corruptionSeverity:              c.CorruptionSeverity,
+		meanLatency:                     time.Duration(c.MeanLatency),
+		minLatency:                      time.Duration(c.MinLatency),
+		maxLatency:                      time.Duration(c.MaxLatency),
+	}
+}
+
+func (c *PathConfig) connection() connection {
+	return connection{
+		dropChance:                      c.DropChance,
+		duplicationChance:               c.DuplicationChance,
+		maxDuplicates:                   c.MaxDuplicates,
+		multipleDuplicateChanceModifier: c.MultipleDuplicateChanceModifier,
+		corruptionChance:                c.CorruptionChance,
+		corruptionSeverity:              c.CorruptionSeverity,
+		meanLatency:                     time.Duration(c.MeanLatency),
+		minLatency:                      time.Duration(c.MinLatency),
+		maxLatency:                      time.Duration(c.MaxLatency),
+	}
+}
+
+func (c *ClockConfig) apply(m *ClockModel) {
+	if c.Offset != nil {
+		m.Offset = time.Duration(*c.Offset)
+	}
+	if c.Frequency != nil {
+		m.Frequency = *c.Frequency
+	}
+	if c.Wander != nil {
+		m.Wander = *c.Wander
+	}
+	if c.Noise != nil {
+		m.Noise = time.Duration(*c.Noise)
+	}
+	if c.Tolerance != nil {
+		m.Tolerance = *c.Tolerance
+	}
+}
+
+func invalidScenario(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", errInvalidScenario, fmt.Sprintf(format, a...))
+}
+
+func validateChance(name string, p float64) error {
+	if p < 0.0 || p > 1.0 {
+		return invalidScenario("%s must be in range [0, 1]", name)
+	}
+	return nil
+}
+
+func validateFailureDurations(name string, mean, min, max Duration) error {
+	if mean < 0 || min < 0 || max < 0 {
+		return invalidScenario("failure durations of %s must not be negative", name)
+	}
+	if max != 0 && max < min {
+		return invalidScenario("max_failure_duration of %s must not be less than min_failure_duration", name)
+	}
+	return nil
+}
+
+func validateLink(name string, l *LinkConfig) error {
+	for _, x := range []struct {
+		name string
+		p    float64
+	}{
+		{"failure_chance", l.FailureChance},
+		{"drop_chance", l.DropChance},
+		{"duplication_chance", l.DuplicationChance},
+		{"corruption_chance", l.CorruptionChance},
+		{"corruption_severity", l.CorruptionSeverity},
+	} {
+		err := validateChance(name+"."+x.name, x.p)
+		if err != nil {
+			return err
+		}
+	}
+	if l.MaxDuplicates < 0 || l.MultipleDuplicateChanceModifier < 0 {
+		return invalidScenario("duplication parameters of %s must not be negative", name)
+	}
+	if l.MinLatency < 0 || l.MeanLatency < 0 || l.MaxLatency < 0 {
+		return invalidScenario("latencies of %s must not be negative", name)
+	}
+	if l.MaxLatency != 0 && l.MaxLatency < l.MinLatency {
+		return invalidScenario("max_latency of %s must not be less than min_latency", name)
+	}
+	return validateFailureDurations(name, l.MeanFailureDuration, l.MinFailureDuration, l.MaxFailureDuration)
+}
+
+func validatePath(i int, p *PathConfig) error {
+	from, err := addr.ParseIA(p.From)
+	if err != nil || from.IsZero() || from.IsWildcard() {
+		return invalidScenario("path %d has invalid AS %q", i, p.From)
+	}
+	to, err := addr.ParseIA(p.To)
+	if err != nil || to.IsZero() || to.IsWildcard() {
+		return invalidScenario("path %d has invalid AS %q", i, p.To)
+	}
+	if from.Equal(to) {
+		return invalidScenario("path %d must connect different ASes", i)
+	}
+	name := fmt.Sprintf("path %d", i)
+	if p.Count < 0 {
+		return invalidScenario("count of %s must not be negative", name)
+	}
+	if p.AttackStart < 0 || p.AttackDelay < 0 {
+		return invalidScenario("attack parameters of %s must not be negative", name)
+	}
+	err = validateChance(name+" failure_chance", p.FailureChance)
+	if err != nil {
+		return err
+	}
+	for _, x := range []struct {
+		name string
+		p    float64
+	}{
+		{"drop_chance", p.DropChance},
+		{"duplication_chance", p.DuplicationChance},
+		{"corruption_chance", p.CorruptionChance},
+		{"corruption_severity", p.CorruptionSeverity},
+	} {
+		err = validateChance(name+" "+x.name, x.p)
+		if err != nil {
+			return err
+		}
+	}
+	if p.MaxDuplicates < 0 || p.MultipleDuplicateChanceModifier < 0 {
+		return invalidScenario("duplication parameters of %s must not be negative", name)
+	}
+	if p.MinLatency < 0 || p.MeanLatency < 0 || p.MaxLatency < 0 {
+		return invalidScenario("latencies of %s must not be negative", name)
+	}
+	if p.MaxLatency != 0 && p.MaxLatency < p.MinLatency {
+		return invalidScenario("max_latency of %s must not be less than min_latency", name)
+	}
+	return validateFailureDurations(name, p.MeanFailureDuration, p.MinFailureDuration, p.MaxFailureDuration)
+}
+
+func validateMalicious(c *InstanceConfig, names map[string]bool, numPaths int) error {
+	m := c.Malicious
+	if c.Type == InstanceTypeClient {
+		return invalidScenario("instance %q of type %q must not be malicious", c.Name, c.Type)
+	}
+	switch m.Behavior {
+	case BehaviorConstantOffset, BehaviorRampOffset:
+	case BehaviorTwoFaced:
+		if len(m.Targets) == 0 {
+			return invalidScenario("instance %q has no targets for behavior %q", c.Name, m.Behavior)
+		}
+	case BehaviorReplay:
+		if m.Age <= 0 {
+			return invalidScenario("instance %q has invalid replay age", c.Name)
+		}
+	case BehaviorDelay:
+		if m.Delay < 0 {
+			return invalidScenario("instance %q has invalid delay", c.Name)
+		}
+	default:
+		return invalidScenario("instance %q has unexpected behavior %q", c.Name, m.Behavior)
+	}
+	if m.Start < 0 {
+		return invalidScenario("instance %q has invalid behavior start", c.Name)
+	}
+	for _, t := range m.Targets {
+		if !names[t] || t == c.Name {
+			return invalidScenario("instance %q has invalid target %q", c.Name, t)
+		}
+	}
+	if len(m.Paths) != 0 && m.Behavior != BehaviorDelay {
+		return invalidScenario("instance %q has paths for behavior %q", c.Name, m.Behavior)
+	}
+	for _, p := range m.Paths {
+		if p < 1 || p > numPaths {
+			return invalidScenario("instance %q has invalid path %d", c.Name, p)
+		}
+	}
+	return nil
+}
+
+func (cfg *SimConfig) Validate() error {
+	if cfg.Duration <= 0 {
+		return invalidScenario("duration must be positive")
+	}
+	if cfg.SampleInterval < 0 || cfg.SettlingTime < 0 {
+		return invalidScenario("sample_interval and settling_time must not be negative")
+	}
+	err := validateLink("default_link", &cfg.DefaultLink)
+	if err != nil {
+		return err
+	}
+	if cfg.DefaultLink.From != "" || cfg.DefaultLink.To != "" {
+		return invalidScenario("default_link must not specify endpoints")
+	}
+	if len(cfg.Instances) == 0 {
+		return invalidScenario("no instances specified")
+	}
+	names := make(map[string]bool)
+	hosts := make(map[netip.Addr]bool)
+	for i := range cfg.Instances {
+		c := &cfg.Instances[i]
+		if c.Name == "" {
+			return invalidScenario("instance %d has no name", i)
+		}
+		if names[c.Name] {
+			return invalidScenario("duplicate instance name %q", c.Name)
+		}
+		names[c.Name] = true
+		if c.Type != InstanceTypeServer && c.Type != InstanceTypeRelay && c.Type != InstanceTypeClient {
+			return invalidScenario("instance %q has unexpected type %q", c.Name, c.Type)
+		}
+		var localAddr snet.UDPAddr
+		err := localAddr.Set(c.LocalAddr)
+		if err != nil {
+			return invalidScenario("instance %q has invalid local_address: %v", c.Name, err)
+		}
+		host, ok := netip.AddrFromSlice(localAddr.Host.IP)
+		if !ok || host.IsUnspecified() {
+			return invalidScenario("instance %q has no host address", c.Name)
+		}
+		if host.Unmap().IsLinkLocalUnicast() {
+			return invalidScenario("instance %q has link-local host address reserved for routers", c.Name)
+		}
+		if hosts[host.Unmap()] {
+			return invalidScenario("duplicate host address %s", host)
+		}
+		hosts[host.Unmap()] = true
+		for _, s := range append(c.NTPReferenceClocks, c.SCIONPeers...) {
+			remoteAddr, err := snet.ParseUDPAddr(s)
+			if err != nil {
+				return invalidScenario("instance %q has invalid peer address %q: %v", c.Name, s, err)
+			}
+			if !remoteAddr.IA.IsZero() && localAddr.IA.IsZero() {
+				return invalidScenario("instance %q needs a local AS to reach %q via SCION", c.Name, s)
+			}
+		}
+		for _, s := range c.SCIONPeers {
+			remoteAddr, _ := snet.ParseUDPAddr(s)
+			if remoteAddr.IA.IsZero() {
+				return invalidScenario("instance %q has unexpected peer address %q", c.Name, s)
+			}
+		}
+		if c.Type != InstanceTypeServer && len(c.SCIONPeers) != 0 {
+			return invalidScenario("instance %q of type %q must not have SCION peers", c.Name, c.Type)
+		}
+		syncCfg, err := c.FileConfig.Config()
+		if err == nil {
+			err = syncCfg.Validate()
+		}
+		if err != nil {
+			return invalidScenario("instance %q has invalid sync configuration: %v", c.Name, err)
+		}
+		err = validateChance(c.Name+".failure_chance", c.FailureChance)
+		if err != nil {
+			return err
+		}
+		err = validateFailureDurations(c.Name, c.MeanFailureDuration, c.MinFailureDuration, c.MaxFailureDuration)
+		if err != nil {
+			return err
+		}
+	}
+	numPaths := 0
+	for i := range cfg.Paths {
+		numPaths += max(cfg.Paths[i].Count, 1)
+	}
+	for i := range cfg.Instances {
+		c := &cfg.Instances[i]
+		if c.Malicious != nil {
+			err := validateMalicious(c, names, numPaths)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i := range cfg.Links {
+		l := &cfg.Links[i]
+		if !names[l.From] || !names[l.To] || l.From == l.To {
+			return invalidScenario("link %d has invalid endpoints %q and %q", i, l.From, l.To)
+		}
+		err := validateLink(l.From+"-"+l.To, l)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Paths {
+		err := validatePath(i, &cfg.Paths[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i := range cfg.Failures {
+		f := &cfg.Failures[i]
+		if !names[f.Instance] || f.Peer != "" && (!names[f.Peer] || f.Peer == f.Instance) {
+			return invalidScenario("failure %d refers to unknown instance", i)
+		}
+		if f.At < 0 || f.Duration <= 0 {
+			return invalidScenario("failure %d has invalid schedule", i)
+		}
+	}
+	return nil
+}
+
+func LoadSimConfig(file string) (SimConfig, error) {
+	var cfg SimConfig
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, err
+	}
+	err = toml.NewDecoder(bytes.NewReader(raw)).DisallowUnknownFields().Decode(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
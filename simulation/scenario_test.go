@@ -0,0 +1,58 @@
+package simulation_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/scion-time/simulation"
+)
+
+func TestLoadSimConfig(t *testing.T) {
+	files, err := filepath.Glob("../testnet/sim/*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no scenario files found")
+	}
+	for _, f := range files {
+		_, err := simulation.LoadSimConfig(f)
+		if err != nil {
+			t.Errorf("LoadSimConfig(%q) failed: %v", f, err)
+		}
+	}
+}
+
+func TestLoadSimConfigInvalid(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "scenario.toml")
+	err := os.WriteFile(f, []byte(`
+duration = "1m"
+
+[[instances]]
+name = "a"
+type = "client"
+local_address = "0-0,10.0.0.1"
+
+[[failures]]
+instance = "b"
+at = "10s"
+duration = "10s"
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := simulation.LoadSimConfig(f)
+	if err == nil {
+		t.Fatal("LoadSimConfig() succeeded for unknown failure instance")
+	}
+	if time.Duration(cfg.Duration) != time.Minute {
+		t.Errorf("cfg.Duration == %v; want %v", time.Duration(cfg.Duration), time.Minute)
+	}
+	var perr *os.PathError
+	if errors.As(err, &perr) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
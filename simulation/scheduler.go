@@ -0,0 +1,176 @@
+package simulation
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+type event struct {
+	at   time.Time
+	seq  uint64
+	f    func()
+	qidx int
+}
+
+type eventQueue []*event
+
+// Scheduler is a discrete-event scheduler that keeps the true (virtual) time
+// shared by all simulated instances. Virtual time only advances when the
+// simulation is idle, i.e., when all goroutines started by Go are blocked.
+// These goroutines must call Block before they wait for an event, and
+// whoever triggers the event must call Unblock on their behalf before doing
+// so. Events scheduled for the same virtual time are processed in the order
+// they were scheduled.
+type Scheduler struct {
+	mu     sync.Mutex
+	idle   sync.Cond
+	now    time.Time
+	seq    uint64
+	queue  eventQueue
+	active int
+}
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	return q[i].at.Before(q[j].at) ||
+		q[i].at.Equal(q[j].at) && q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].qidx = i
+	q[j].qidx = j
+}
+
+func (q *eventQueue) Push(x any) {
+	ev := x.(*event)
+	ev.qidx = len(*q)
+	*q = append(*q, ev)
+}
+
+func (q *eventQueue) Pop() any {
+	n := len(*q)
+	ev := (*q)[n-1]
+	(*q)[n-1] = nil
+	*q = (*q)[0 : n-1]
+	ev.qidx = -1
+	return ev
+}
+
+func NewScheduler(start time.Time) *Scheduler {
+	s := &Scheduler{now: start.UTC()}
+	s.idle.L = &s.mu
+	return s
+}
+
+// Now returns the current true time of the simulation.
+func (s *Scheduler) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// At schedules f to be called at true time t. Events in the past are
+// processed at the current true time. f is called from the goroutine that
+// runs the scheduler and must not block.
+func (s *Scheduler) At(t time.Time, f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.Before(s.now) {
+		t = s.now
+	}
+	heap.Push(&s.queue, &event{at: t, seq: s.seq, f: f})
+	s.seq++
+}
+
+// AfterFunc schedules f to be called after duration d of true time, like At.
+// Calling stop removes f from the schedule; stop reports whether it did so.
+func (s *Scheduler) AfterFunc(d time.Duration, f func()) (stop func() bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev := &event{at: s.now.Add(d), seq: s.seq, f: f}
+	heap.Push(&s.queue, ev)
+	s.seq++
+	return func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ev.qidx < 0 {
+			return false
+		}
+		heap.Remove(&s.queue, ev.qidx)
+		return true
+	}
+}
+
+// Sleep blocks the calling goroutine for duration d of true time.
+func (s *Scheduler) Sleep(d time.Duration) {
+	if d < 0 {
+		panic("invalid duration value")
+	}
+	done := make(chan struct{})
+	s.At(s.Now().Add(d), func() {
+		s.Unblock(1)
+		close(done)
+	})
+	s.Block()
+	<-done
+}
+
+// Go calls f in a new goroutine. Virtual time does not advance before the
+// goroutine has returned or blocks.
+func (s *Scheduler) Go(f func()) {
+	s.Unblock(1)
+	go func() {
+		defer s.Block() // returned goroutines are never woken up again
+		f()
+	}()
+}
+
+// Block records that the calling goroutine, started by Go, is about to wait
+// for an event.
+func (s *Scheduler) Block() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == 0 {
+		panic("unbalanced Block call")
+	}
+	s.active--
+	if s.active == 0 {
+		s.idle.Broadcast()
+	}
+}
+
+// Unblock records that n goroutines blocked in Block are about to be woken
+// up.
+func (s *Scheduler) Unblock(n int) {
+	if n < 0 {
+		panic("invalid argument: n must not be negative")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active += n
+}
+
+// Run processes events in order of their scheduled true time until the
+// simulation reaches true time t or no more events are pending.
+func (s *Scheduler) Run(t time.Time) {
+	for {
+		s.mu.Lock()
+		for s.active != 0 {
+			s.idle.Wait()
+		}
+		if len(s.queue) == 0 || s.queue[0].at.After(t) {
+			if s.now.Before(t) {
+				s.now = t
+			}
+			s.mu.Unlock()
+			return
+		}
+		ev := heap.Pop(&s.queue).(*event)
+		s.now = ev.at
+		s.mu.Unlock()
+		ev.f()
+	}
+}
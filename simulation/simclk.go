@@ -1,47 +1,207 @@
 package simulation
 
 import (
+	"math"
+	"math/rand"
+	"sync"
 	"time"
 
 	"example.com/scion-time/base/timebase"
+	"example.com/scion-time/base/timemath"
 )
 
+const (
+	wanderInterval = 1 * time.Second
+
+	defaultMaxOffset    = 100 * time.Millisecond
+	defaultMaxFrequency = 20e-6
+	defaultWander       = 1e-9
+	defaultNoise        = 50 * time.Nanosecond
+	defaultTolerance    = 100e-6
+)
+
+// ClockModel describes the oscillator of a simulated local clock.
+type ClockModel struct {
+	Offset    time.Duration // initial phase offset from true time
+	Frequency float64       // constant frequency offset, e.g., 1e-6 == 1 ppm
+	Wander    float64       // standard deviation of the frequency random walk per second
+	Noise     time.Duration // standard deviation of the read noise
+	Tolerance float64       // frequency tolerance used for MaxDrift
+}
+
 type SimulationClock struct {
-	seed int64 // still TODO if this is needed
+	sched *Scheduler
+	model ClockModel
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	epoch uint64
+	t     time.Time // true time of the last update
+	tw    time.Time // true time of the next wander update
+	phase float64   // local minus true time at t [s]
+	wfreq float64   // current wander frequency component
+	cfreq float64   // frequency correction applied via Adjust
+	qual  timebase.SyncQuality
+	adj   struct {
+		active    bool
+		end       time.Time
+		afterFreq float64
+	}
+}
+
+var (
+	_ timebase.LocalClock       = (*SimulationClock)(nil)
+	_ timebase.SyncQualityClock = (*SimulationClock)(nil)
+	_ timebase.TimerClock       = (*SimulationClock)(nil)
+	_ timebase.TrackingClock    = (*SimulationClock)(nil)
+)
+
+// NewClockModel draws a clock model from seed using default parameters.
+func NewClockModel(seed int64) ClockModel {
+	r := rand.New(rand.NewSource(seed))
+	return ClockModel{
+		Offset:    time.Duration((2*r.Float64() - 1) * float64(defaultMaxOffset)),
+		Frequency: (2*r.Float64() - 1) * defaultMaxFrequency,
+		Wander:    defaultWander,
+		Noise:     defaultNoise,
+		Tolerance: defaultTolerance,
+	}
 }
 
-func NewSimulationClock(seed int64) *SimulationClock {
-	return &SimulationClock{seed: seed}
+func NewSimulationClock(sched *Scheduler, seed int64, model ClockModel) *SimulationClock {
+	now := sched.Now()
+	return &SimulationClock{
+		sched: sched,
+		model: model,
+		rnd:   rand.New(rand.NewSource(seed)),
+		t:     now,
+		tw:    now.Add(wanderInterval),
+		phase: timemath.Seconds(model.Offset),
+	}
+}
+
+func (c *SimulationClock) frequency() float64 {
+	return c.model.Frequency + c.wfreq + c.cfreq
+}
+
+func (c *SimulationClock) update(t time.Time) {
+	for c.t.Before(t) {
+		next := t
+		if c.adj.active && c.adj.end.Before(next) {
+			next = c.adj.end
+		}
+		if c.tw.Before(next) {
+			next = c.tw
+		}
+		c.phase += timemath.Seconds(next.Sub(c.t)) * c.frequency()
+		c.t = next
+		if c.adj.active && !c.adj.end.After(c.t) {
+			c.cfreq = c.adj.afterFreq
+			c.adj.active = false
+		}
+		if !c.tw.After(c.t) {
+			c.wfreq += c.model.Wander * c.rnd.NormFloat64() * math.Sqrt(timemath.Seconds(wanderInterval))
+			c.tw = c.tw.Add(wanderInterval)
+		}
+	}
 }
 
 func (c *SimulationClock) Epoch() uint64 {
-	//TODO implement me
-	panic("SimulationClock.Epoch() implement me")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
 }
 
 func (c *SimulationClock) Now() time.Time {
-	//TODO implement me
-	panic("SimulationClock.Now(): implement me")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.sched.Now()
+	c.update(t)
+	noise := c.rnd.NormFloat64() * timemath.Seconds(c.model.Noise)
+	return t.Add(timemath.Duration(c.phase + noise))
+}
+
+// Offset returns the current offset of the clock from true time without read
+// noise.
+func (c *SimulationClock) Offset() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(c.sched.Now())
+	return timemath.Duration(c.phase)
 }
 
 func (c *SimulationClock) MaxDrift(duration time.Duration) time.Duration {
-	//TODO implement me
-	panic("SimulationClock.MaxDrift(): implement me")
+	return time.Duration(float64(duration) * c.model.Tolerance)
+}
+
+func (c *SimulationClock) SetSyncQuality(q timebase.SyncQuality) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.qual = q
+}
+
+func (c *SimulationClock) SyncQuality() timebase.SyncQuality {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.qual
 }
 
 func (c *SimulationClock) Step(offset time.Duration) {
-	//TODO implement me
-	panic("SimulationClock.Step(): implement me")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(c.sched.Now())
+	if c.adj.active {
+		c.cfreq = c.adj.afterFreq
+		c.adj.active = false
+	}
+	c.phase += timemath.Seconds(offset)
+	if c.epoch == math.MaxUint64 {
+		panic("epoch overflow")
+	}
+	c.epoch++
 }
 
 func (c *SimulationClock) Adjust(offset, duration time.Duration, frequency float64) {
-	//TODO implement me
-	panic("SimulationClock.Adjust(): implement me")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if duration < 0 {
+		panic("invalid duration value")
+	}
+	t := c.sched.Now()
+	c.update(t)
+	duration = duration / time.Second * time.Second
+	if duration == 0 {
+		duration = time.Second
+	}
+	c.cfreq = frequency + timemath.Seconds(offset)/timemath.Seconds(duration)
+	c.adj.active = true
+	c.adj.end = t.Add(duration)
+	c.adj.afterFreq = frequency
+}
+
+// trueDuration returns the true time it takes for the clock to advance by
+// duration at its current frequency.
+func (c *SimulationClock) trueDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		panic("invalid duration value")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(c.sched.Now())
+	return time.Duration(float64(duration) / (1.0 + c.frequency()))
 }
 
 func (c *SimulationClock) Sleep(duration time.Duration) {
-	//TODO implement me
-	panic("SimulationClock.Sleep(): implement me")
+	c.sched.Sleep(c.trueDuration(duration))
+}
+
+// AfterFunc calls f from the goroutine that runs the scheduler after duration
+// d measured by the clock.
+func (c *SimulationClock) AfterFunc(d time.Duration, f func()) (stop func() bool) {
+	return c.sched.AfterFunc(c.trueDuration(d), f)
 }
 
-var _ timebase.LocalClock = (*SimulationClock)(nil)
+// Go, Block and Unblock track the goroutines of the simulated instance with
+// the scheduler, see Scheduler.
+func (c *SimulationClock) Go(f func())   { c.sched.Go(f) }
+func (c *SimulationClock) Block()        { c.sched.Block() }
+func (c *SimulationClock) Unblock(n int) { c.sched.Unblock(n) }
@@ -0,0 +1,32 @@
+package simulation_test
+
+import (
+	"testing"
+	"time"
+
+	"example.com/scion-time/simulation"
+)
+
+func TestSimulationClockDrift(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sched := simulation.NewScheduler(t0)
+	clk := simulation.NewSimulationClock(sched, 0, simulation.ClockModel{
+		Frequency: 10e-6,
+		Tolerance: 100e-6,
+	})
+
+	sched.Run(t0.Add(time.Hour))
+
+	if !sched.Now().Equal(t0.Add(time.Hour)) {
+		t.Errorf("sched.Now() == %v; want %v", sched.Now(), t0.Add(time.Hour))
+	}
+	off := clk.Offset()
+	if off != 36*time.Millisecond {
+		t.Errorf("clk.Offset() == %v; want %v", off, 36*time.Millisecond)
+	}
+
+	clk.Step(-off)
+	if clk.Epoch() != 1 || clk.Offset() != 0 {
+		t.Errorf("clk.Step(%v) failed: epoch %d, offset %v", -off, clk.Epoch(), clk.Offset())
+	}
+}
@@ -1,33 +1,269 @@
 package simulation
 
 import (
-	"example.com/scion-time/base/netprovider"
+	"errors"
 	"net"
+	"net/netip"
+	"os"
+	"sync"
 	"time"
+
+	"golang.org/x/sys/unix"
+
+	"example.com/scion-time/base/netprovider"
+	"example.com/scion-time/net/ntp"
+	"example.com/scion-time/net/scion"
+	"example.com/scion-time/net/udp"
 )
 
+var (
+	errAddrInUse             = errors.New("address already in use")
+	errNotConnected          = errors.New("connection not connected")
+	errTimestampingDisabled  = errors.New("timestamping not enabled")
+	errTimestampNotAvailable = errors.New("no tx timestamp available")
+	errUnexpectedConnection  = errors.New("unexpected connection type")
+)
+
+// SimConnector provides the connections of a single simulated instance. All
+// connections of an instance share the instance's host address and are
+// timestamped with the instance's local clock.
 type SimConnector struct {
-	// Nothing yet
+	net  *Network
+	clk  *SimulationClock
+	host netip.Addr
+	bhv  behavior
+}
+
+type simConn struct {
+	net       *Network
+	clk       *SimulationClock
+	bhv       behavior
+	laddr     netip.AddrPort
+	reusePort bool
+	mu        sync.Mutex
+	closed    bool
+	tsEnabled bool
+	dscp      uint8
+	deadline  time.Time // in true time
+	rxq       []rxPacket
+	wake      chan struct{}
+	waiting   int
+	txID      uint32
+	txTime    time.Time
+}
+
+type rxPacket struct {
+	packet
+	rxTime time.Time
+}
+
+var _ netprovider.ConnProvider = (*SimConnector)(nil)
+var _ netprovider.Connection = (*simConn)(nil)
+
+func NewSimConnector(net *Network, clk *SimulationClock, host netip.Addr) *SimConnector {
+	return &SimConnector{net: net, clk: clk, host: host.Unmap()}
+}
+
+func (s *SimConnector) listen(laddr *net.UDPAddr, reusePort bool) (netprovider.Connection, error) {
+	var ip netip.Addr
+	if laddr != nil {
+		ip, _ = netip.AddrFromSlice(laddr.IP)
+	}
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() {
+		ip = s.host
+	}
+	var port uint16
+	if laddr != nil {
+		port = uint16(laddr.Port)
+	}
+	c := &simConn{
+		net:       s.net,
+		clk:       s.clk,
+		bhv:       s.bhv,
+		reusePort: reusePort,
+		wake:      make(chan struct{}),
+	}
+	addr, err := s.net.bind(c, netip.AddrPortFrom(ip, port), reusePort)
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "udp", Addr: laddr, Err: err}
+	}
+	c.laddr = addr
+	return c, nil
 }
 
 func (s *SimConnector) ListenUDP(network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
-	//TODO implement me
-	panic("implement me")
+	return s.listen(laddr, false /* reusePort */)
+}
+
+func (s *SimConnector) ListenUDPReusePort(network string, laddr *net.UDPAddr) (netprovider.Connection, error) {
+	return s.listen(laddr, true /* reusePort */)
 }
 
 func (s *SimConnector) EnableTimestamping(n netprovider.Connection, localHostIface string) error {
-	//TODO implement me
-	panic("implement me")
+	c, ok := n.(*simConn)
+	if !ok {
+		return errUnexpectedConnection
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tsEnabled = true
+	return nil
 }
 
 func (s *SimConnector) SetDSCP(n netprovider.Connection, dscp uint8) error {
-	//TODO implement me
-	panic("implement me")
+	if dscp > 63 {
+		panic("invalid argument: dscp must not be greater than 63")
+	}
+	c, ok := n.(*simConn)
+	if !ok {
+		return errUnexpectedConnection
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dscp = dscp
+	return nil
 }
 
 func (s *SimConnector) ReadTXTimestamp(n netprovider.Connection) (time.Time, uint32, error) {
-	//TODO implement me
-	panic("implement me")
+	c, ok := n.(*simConn)
+	if !ok {
+		return time.Time{}, 0, errUnexpectedConnection
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.tsEnabled {
+		return time.Time{}, 0, errTimestampingDisabled
+	}
+	if c.txID == 0 {
+		return time.Time{}, 0, errTimestampNotAvailable
+	}
+	return c.txTime, c.txID - 1, nil
 }
 
-var _ netprovider.ConnProvider = (*SimConnector)(nil)
+func (c *simConn) notify() {
+	c.net.sched.Unblock(c.waiting)
+	c.waiting = 0
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+func (c *simConn) enqueue(pkt packet) {
+	rxTime := c.clk.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.rxq = append(c.rxq, rxPacket{packet: pkt, rxTime: rxTime})
+	c.notify()
+}
+
+func (c *simConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return net.ErrClosed
+	}
+	c.closed = true
+	c.rxq = nil
+	c.notify()
+	c.mu.Unlock()
+	c.net.unbind(c)
+	return nil
+}
+
+func (c *simConn) Write(b []byte) (int, error) {
+	return 0, errNotConnected
+}
+
+func (c *simConn) ReadMsgUDPAddrPort(buf []byte, oob []byte) (
+	n int, oobn int, flags int, addr netip.AddrPort, err error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return 0, 0, 0, netip.AddrPort{}, net.ErrClosed
+		}
+		if len(c.rxq) != 0 {
+			pkt := c.rxq[0]
+			c.rxq = c.rxq[1:]
+			tsEnabled := c.tsEnabled
+			c.mu.Unlock()
+			n = copy(buf, pkt.buf)
+			if n < len(pkt.buf) {
+				flags |= unix.MSG_TRUNC
+			}
+			if tsEnabled && len(oob) >= udp.TimestampLen() {
+				oobn = udp.EncodeTimestampOOBData(oob, pkt.rxTime)
+			}
+			return n, oobn, flags, pkt.src, nil
+		}
+		if !c.deadline.IsZero() && !c.net.sched.Now().Before(c.deadline) {
+			c.mu.Unlock()
+			return 0, 0, 0, netip.AddrPort{}, os.ErrDeadlineExceeded
+		}
+		wake := c.wake
+		c.waiting++
+		c.mu.Unlock()
+		c.net.sched.Block()
+		<-wake
+	}
+}
+
+func (c *simConn) WriteToUDPAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	txTime := c.clk.Now()
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return 0, net.ErrClosed
+	}
+	if c.tsEnabled {
+		c.txID++
+		c.txTime = txTime
+	}
+	c.mu.Unlock()
+	n := len(b)
+	var delay time.Duration
+	if c.bhv != nil {
+		switch c.laddr.Port() {
+		case ntp.ServerPortIP:
+			b, delay = c.bhv.tamper(c.net.sched.Now(), addr.Addr().Unmap(), 0 /* path */, append([]byte(nil), b...))
+		case scion.EndhostPort:
+			b, delay = tamperSCION(c.bhv, c.net.sched.Now(), c.laddr.Addr(), b)
+		}
+		if b == nil {
+			return n, nil
+		}
+	}
+	c.net.send(c.laddr, addr, b, delay)
+	return n, nil
+}
+
+// SetDeadline sets the read deadline of the connection. Like all timestamps
+// handled by the simulated instance, t is interpreted in terms of the
+// instance's local clock.
+func (c *simConn) SetDeadline(t time.Time) error {
+	var deadline time.Time
+	if !t.IsZero() {
+		deadline = c.net.sched.Now().Add(t.Sub(c.clk.Now()))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.deadline = deadline
+	if !deadline.IsZero() {
+		c.net.sched.At(deadline, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.notify()
+		})
+	}
+	return nil
+}
+
+func (c *simConn) LocalAddr() net.Addr {
+	return net.UDPAddrFromAddrPort(c.laddr)
+}
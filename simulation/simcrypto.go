@@ -3,11 +3,13 @@ package simulation
 import (
 	"context"
 	"math/rand"
+	"sync"
 
 	"example.com/scion-time/base/cryptobase"
 )
 
 type SimCrypto struct {
+	mu         sync.Mutex
 	seededRand rand.Rand
 }
 
@@ -19,6 +21,8 @@ func (s *SimCrypto) RandIntn(ctx context.Context, n int) (int, error) {
 	if n <= 0 {
 		panic("invalid argument to RandIntn: n must be greater than 0")
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.seededRand.Intn(n), nil
 }
 
@@ -35,6 +39,8 @@ func (s *SimCrypto) Sample(ctx context.Context, k, n int, pick func(dst int, src
 	for i := 0; i != k; i++ {
 		pick(i, i)
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for i := k; i != n; i++ {
 		j := s.seededRand.Intn(i + 1)
 		if j < k {
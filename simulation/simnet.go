@@ -0,0 +1,331 @@
+package simulation
+
+import (
+	"math/rand"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"example.com/scion-time/net/scion"
+)
+
+const (
+	ephemeralPortMin = 32768
+	ephemeralPortMax = 60999
+)
+
+type link struct {
+	src, dst netip.Addr
+}
+
+type packet struct {
+	src netip.AddrPort
+	buf []byte
+}
+
+// Network is an in-memory packet fabric connecting simulated instances.
+// Packets are delivered by the scheduler after a latency drawn from the
+// connection parameters of the link between sender and receiver. SCION packets
+// sent to the router of an AS are forwarded along the simulated path given in
+// their path header instead and delivered to the SCION endhost port of the
+// destination host.
+type Network struct {
+	sched    *Scheduler
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	conns    map[netip.AddrPort][]*simConn
+	next     map[netip.AddrPort]int
+	links    map[link]connection
+	defLink  connection
+	nextPort map[netip.Addr]uint16
+	hostDown map[netip.Addr]int
+	linkDown map[link]int
+	routers  map[addr.IA]netip.AddrPort
+	routerIA map[netip.AddrPort]addr.IA
+	pathIdx  []*pathModel
+}
+
+func NewNetwork(sched *Scheduler, seed int64) *Network {
+	return &Network{
+		sched:    sched,
+		rnd:      rand.New(rand.NewSource(seed)),
+		conns:    make(map[netip.AddrPort][]*simConn),
+		next:     make(map[netip.AddrPort]int),
+		links:    make(map[link]connection),
+		nextPort: make(map[netip.Addr]uint16),
+		hostDown: make(map[netip.Addr]int),
+		linkDown: make(map[link]int),
+		routers:  make(map[addr.IA]netip.AddrPort),
+		routerIA: make(map[netip.AddrPort]addr.IA),
+	}
+}
+
+func (n *Network) setDefaultConnection(c connection) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.defLink = c
+}
+
+func (n *Network) setConnection(src, dst netip.Addr, c connection) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.links[link{src.Unmap(), dst.Unmap()}] = c
+}
+
+func (n *Network) setHostDown(host netip.Addr, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	host = host.Unmap()
+	if down {
+		n.hostDown[host]++
+	} else if n.hostDown[host] == 1 {
+		delete(n.hostDown, host)
+	} else if n.hostDown[host] > 1 {
+		n.hostDown[host]--
+	}
+}
+
+func (n *Network) setLinkDown(src, dst netip.Addr, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	l := link{src.Unmap(), dst.Unmap()}
+	if down {
+		n.linkDown[l]++
+	} else if n.linkDown[l] == 1 {
+		delete(n.linkDown, l)
+	} else if n.linkDown[l] > 1 {
+		n.linkDown[l]--
+	}
+}
+
+// router returns the underlay address of the router of AS ia. Routers are
+// assigned link-local addresses which are not available to instances.
+func (n *Network) router(ia addr.IA) netip.AddrPort {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	r, ok := n.routers[ia]
+	if !ok {
+		i := len(n.routers) + 1
+		r = netip.AddrPortFrom(netip.AddrFrom4([4]byte{169, 254, byte(i >> 8), byte(i)}), routerPort)
+		n.routers[ia] = r
+		n.routerIA[r] = ia
+	}
+	return r
+}
+
+func (n *Network) addPaths(from, to addr.IA, start time.Time, cfg *PathConfig) []*pathModel {
+	fromRouter, toRouter := n.router(from), n.router(to)
+	k := cfg.Count
+	if k == 0 {
+		k = 1
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ps := make([]*pathModel, k)
+	for i := 0; i != k; i++ {
+		id := uint16(len(n.pathIdx) + 1)
+		ps[i] = newPathModel(id, from, to, fromRouter, toRouter, start, cfg)
+		n.pathIdx = append(n.pathIdx, ps[i])
+	}
+	return ps
+}
+
+func (n *Network) setPathDown(p *pathModel, down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if down {
+		p.down++
+	} else if p.down > 0 {
+		p.down--
+	}
+}
+
+// paths returns the simulated SCION paths from AS src to AS dst which are not
+// currently withdrawn. Within an AS, packets are sent directly via an empty
+// path.
+func (n *Network) paths(src, dst addr.IA) []snet.Path {
+	if src.Equal(dst) {
+		return []snet.Path{simPath{src: src, dst: dst, dp: emptyPath{}}}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var ps []snet.Path
+	for _, p := range n.pathIdx {
+		if p.down != 0 {
+			continue
+		}
+		if p.from.Equal(src) && p.to.Equal(dst) {
+			ps = append(ps, p.fwd)
+		} else if p.from.Equal(dst) && p.to.Equal(src) {
+			ps = append(ps, p.rev)
+		}
+	}
+	return ps
+}
+
+func (n *Network) bind(c *simConn, addr netip.AddrPort, reusePort bool) (netip.AddrPort, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	ip := addr.Addr().Unmap()
+	port := addr.Port()
+	if port == 0 {
+		p := n.nextPort[ip]
+		for i := 0; port == 0; i++ {
+			if i > ephemeralPortMax-ephemeralPortMin {
+				return netip.AddrPort{}, errAddrInUse
+			}
+			if p < ephemeralPortMin || p > ephemeralPortMax {
+				p = ephemeralPortMin
+			}
+			_, ok := n.conns[netip.AddrPortFrom(ip, p)]
+			if !ok {
+				port = p
+			}
+			p++
+		}
+		n.nextPort[ip] = p
+	}
+	addr = netip.AddrPortFrom(ip, port)
+	cs, ok := n.conns[addr]
+	if ok && (!reusePort || !cs[0].reusePort) {
+		return netip.AddrPort{}, errAddrInUse
+	}
+	n.conns[addr] = append(cs, c)
+	return addr, nil
+}
+
+func (n *Network) unbind(c *simConn) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	cs := n.conns[c.laddr]
+	for i := range cs {
+		if cs[i] == c {
+			cs = append(cs[:i], cs[i+1:]...)
+			break
+		}
+	}
+	if len(cs) == 0 {
+		delete(n.conns, c.laddr)
+		delete(n.next, c.laddr)
+	} else {
+		n.conns[c.laddr] = cs
+	}
+}
+
+func (n *Network) latency(c *connection) time.Duration {
+	d := c.minLatency
+	if c.meanLatency > c.minLatency {
+		d += time.Duration(n.rnd.ExpFloat64() * float64(c.meanLatency-c.minLatency))
+	}
+	if c.maxLatency > c.minLatency && d > c.maxLatency {
+		d = c.maxLatency
+	}
+	return d
+}
+
+func (n *Network) corrupt(c *connection, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	k := int(c.corruptionSeverity * float64(len(b)))
+	if k == 0 {
+		k = 1
+	}
+	for i := 0; i != k; i++ {
+		b[n.rnd.Intn(len(b))] ^= byte(1 + n.rnd.Intn(255))
+	}
+}
+
+// copies returns the copies of b which are delivered via connection c, i.e., b
+// and its duplicates, each of which may have been corrupted.
+func (n *Network) copies(c *connection, b []byte) [][]byte {
+	k := 1
+	p := c.duplicationChance
+	for k <= int(c.maxDuplicates) && n.rnd.Float64() < p {
+		k++
+		p *= c.multipleDuplicateChanceModifier
+	}
+	bufs := make([][]byte, k)
+	for i := 0; i != k; i++ {
+		bufs[i] = append([]byte(nil), b...)
+		if n.rnd.Float64() < c.corruptionChance {
+			n.corrupt(c, bufs[i])
+		}
+	}
+	return bufs
+}
+
+func (n *Network) send(src, dst netip.AddrPort, b []byte, delay time.Duration) {
+	src = netip.AddrPortFrom(src.Addr().Unmap(), src.Port())
+	dst = netip.AddrPortFrom(dst.Addr().Unmap(), dst.Port())
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.routerIA[dst]; ok {
+		n.route(src, b, delay)
+		return
+	}
+	l := link{src.Addr(), dst.Addr()}
+	if n.hostDown[l.src] != 0 || n.hostDown[l.dst] != 0 || n.linkDown[l] != 0 {
+		return
+	}
+	var c connection
+	if l.src != l.dst {
+		var ok bool
+		c, ok = n.links[l]
+		if !ok {
+			c = n.defLink
+		}
+	}
+	if n.rnd.Float64() < c.dropChance {
+		return
+	}
+	now := n.sched.Now()
+	for _, buf := range n.copies(&c, b) {
+		buf := buf
+		n.sched.At(now.Add(delay+n.latency(&c)), func() {
+			n.deliver(dst, packet{src: src, buf: buf})
+		})
+	}
+}
+
+func (n *Network) route(src netip.AddrPort, b []byte, delay time.Duration) {
+	dstIA, dstHost, id, consDir, err := decodeRoute(b)
+	if err != nil || id == 0 || int(id) > len(n.pathIdx) {
+		return
+	}
+	p := n.pathIdx[id-1]
+	if consDir && !dstIA.Equal(p.to) || !consDir && !dstIA.Equal(p.from) {
+		return
+	}
+	if p.down != 0 || n.hostDown[src.Addr()] != 0 || n.rnd.Float64() < p.conn.dropChance {
+		return
+	}
+	now := n.sched.Now()
+	dst := netip.AddrPortFrom(dstHost, scion.EndhostPort)
+	lastHop := n.routers[dstIA]
+	for _, buf := range n.copies(&p.conn, b) {
+		buf := buf
+		d := delay + n.latency(&p.conn) + p.latency(now, consDir)
+		n.sched.At(now.Add(d), func() {
+			n.deliver(dst, packet{src: lastHop, buf: buf})
+		})
+	}
+}
+
+func (n *Network) deliver(dst netip.AddrPort, pkt packet) {
+	n.mu.Lock()
+	cs := n.conns[dst]
+	if len(cs) == 0 || n.hostDown[dst.Addr()] != 0 {
+		n.mu.Unlock()
+		return
+	}
+	i := n.next[dst] % len(cs)
+	n.next[dst] = i + 1
+	c := cs[i]
+	n.mu.Unlock()
+	c.enqueue(pkt)
+}
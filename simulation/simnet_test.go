@@ -0,0 +1,93 @@
+package simulation
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"example.com/scion-time/net/udp"
+)
+
+func TestNetworkDelivery(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sched := NewScheduler(t0)
+	nw := NewNetwork(sched, 0)
+	nw.setDefaultConnection(connection{
+		minLatency:  10 * time.Millisecond,
+		meanLatency: 10 * time.Millisecond,
+	})
+
+	clkA := NewSimulationClock(sched, 1, ClockModel{})
+	clkB := NewSimulationClock(sched, 2, ClockModel{Offset: time.Second})
+	hostA := netip.MustParseAddr("10.0.0.1")
+	hostB := netip.MustParseAddr("10.0.0.2")
+	a := NewSimConnector(nw, clkA, hostA)
+	b := NewSimConnector(nw, clkB, hostB)
+
+	connA, err := a.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	connB, err := b.ListenUDP("udp", &net.UDPAddr{Port: 123})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	_ = a.EnableTimestamping(connA, "")
+	_ = b.EnableTimestamping(connB, "")
+
+	type result struct {
+		n   int
+		rxt time.Time
+		src netip.AddrPort
+		err error
+	}
+	res := make(chan result, 1)
+	sched.Go(func() {
+		buf := make([]byte, 64)
+		oob := make([]byte, udp.TimestampLen())
+		n, oobn, _, src, err := connB.ReadMsgUDPAddrPort(buf, oob)
+		var rxt time.Time
+		if err == nil {
+			rxt, err = udp.TimestampFromOOBData(oob[:oobn])
+		}
+		res <- result{n, rxt, src, err}
+	})
+
+	_, err = connA.WriteToUDPAddrPort([]byte("ping"), netip.AddrPortFrom(hostB, 123))
+	if err != nil {
+		t.Fatalf("WriteToUDPAddrPort failed: %v", err)
+	}
+	txt, id, err := a.ReadTXTimestamp(connA)
+	if err != nil || id != 0 || !txt.Equal(t0) {
+		t.Errorf("ReadTXTimestamp() == %v, %d, %v; want %v, 0, nil", txt, id, err, t0)
+	}
+
+	sched.Run(t0.Add(time.Second))
+	r := <-res
+	if r.err != nil {
+		t.Fatalf("ReadMsgUDPAddrPort failed: %v", r.err)
+	}
+	if r.n != 4 || r.src.Addr() != hostA {
+		t.Errorf("received %d bytes from %v; want 4 bytes from %v", r.n, r.src, hostA)
+	}
+	want := t0.Add(time.Second + 10*time.Millisecond)
+	if !r.rxt.Equal(want) {
+		t.Errorf("rx timestamp == %v; want %v", r.rxt, want)
+	}
+
+	err = connA.SetDeadline(clkA.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("SetDeadline failed: %v", err)
+	}
+	errc := make(chan error, 1)
+	sched.Go(func() {
+		_, _, _, _, err := connA.ReadMsgUDPAddrPort(make([]byte, 64), nil)
+		errc <- err
+	})
+	sched.Run(t0.Add(3 * time.Second))
+	err = <-errc
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+		t.Errorf("ReadMsgUDPAddrPort() error == %v; want timeout", err)
+	}
+}
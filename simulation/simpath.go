@@ -0,0 +1,243 @@
+package simulation
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/google/gopacket"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/private/common"
+	"github.com/scionproto/scion/pkg/slayers"
+	"github.com/scionproto/scion/pkg/slayers/path"
+	"github.com/scionproto/scion/pkg/slayers/path/empty"
+	"github.com/scionproto/scion/pkg/slayers/path/scion"
+	"github.com/scionproto/scion/pkg/snet"
+)
+
+const (
+	routerPort    = 30042
+	pathMTU       = 1472
+	maxHopExpTime = 255 // 24h
+)
+
+var (
+	errUnexpectedPath = errors.New("unexpected path")
+)
+
+// pathModel is a simulated SCION path between the ASes from and to. A path
+// consists of a single segment whose ID identifies the path in the network.
+// The path is handed out as fwd to instances in from and as rev to instances
+// in to.
+type pathModel struct {
+	id          uint16
+	from, to    addr.IA
+	conn        connection
+	asymmetry   time.Duration
+	attackStart time.Time
+	attackDelay time.Duration
+	down        int
+	fwd, rev    snet.Path
+}
+
+type simPath struct {
+	src, dst addr.IA
+	dp       snet.DataplanePath
+	nextHop  *net.UDPAddr
+	meta     snet.PathMetadata
+}
+
+type rawPath struct {
+	raw []byte
+}
+
+type emptyPath struct{}
+
+// simPather hands out the simulated SCION paths which are currently available
+// from the AS of an instance.
+type simPather struct {
+	net     *Network
+	localIA addr.IA
+}
+
+var _ snet.Path = simPath{}
+
+func (p simPath) UnderlayNextHop() *net.UDPAddr {
+	if p.nextHop == nil {
+		return nil
+	}
+	return &net.UDPAddr{
+		IP:   append(p.nextHop.IP[:0:0], p.nextHop.IP...),
+		Port: p.nextHop.Port,
+	}
+}
+
+func (p simPath) Dataplane() snet.DataplanePath { return p.dp }
+func (p simPath) Source() addr.IA               { return p.src }
+func (p simPath) Destination() addr.IA          { return p.dst }
+
+func (p simPath) Metadata() *snet.PathMetadata {
+	return p.meta.Copy()
+}
+
+func (p rawPath) SetPath(s *slayers.SCION) error {
+	var sp scion.Raw
+	err := sp.DecodeFromBytes(p.raw)
+	if err != nil {
+		return err
+	}
+	s.Path, s.PathType = &sp, sp.Type()
+	return nil
+}
+
+func (emptyPath) SetPath(s *slayers.SCION) error {
+	s.Path, s.PathType = empty.Path{}, empty.PathType
+	return nil
+}
+
+func newRawPaths(id uint16, ts time.Time) (fwd, rev rawPath) {
+	d := &scion.Decoded{
+		Base: scion.Base{
+			PathMeta: scion.MetaHdr{SegLen: [3]uint8{2, 0, 0}},
+			NumINF:   1,
+			NumHops:  2,
+		},
+		InfoFields: []path.InfoField{{
+			ConsDir:   true,
+			SegID:     id,
+			Timestamp: uint32(ts.Unix()),
+		}},
+		HopFields: []path.HopField{{
+			ExpTime:    maxHopExpTime,
+			ConsEgress: id,
+		}, {
+			ExpTime:     maxHopExpTime,
+			ConsIngress: id,
+		}},
+	}
+	fwd.raw = make([]byte, d.Len())
+	err := d.SerializeTo(fwd.raw)
+	if err != nil {
+		panic(err)
+	}
+	_, err = d.Reverse()
+	if err != nil {
+		panic(err)
+	}
+	d.PathMeta.CurrINF, d.PathMeta.CurrHF = 0, 0
+	rev.raw = make([]byte, d.Len())
+	err = d.SerializeTo(rev.raw)
+	if err != nil {
+		panic(err)
+	}
+	return
+}
+
+func newPathModel(id uint16, from, to addr.IA, fromRouter, toRouter netip.AddrPort,
+	start time.Time, cfg *PathConfig) *pathModel {
+	fwd, rev := newRawPaths(id, start)
+	meta := snet.PathMetadata{
+		Interfaces: []snet.PathInterface{
+			{ID: common.IFIDType(id), IA: from},
+			{ID: common.IFIDType(id), IA: to},
+		},
+		MTU:     pathMTU,
+		Latency: []time.Duration{time.Duration(cfg.MinLatency)},
+	}
+	p := &pathModel{
+		id:          id,
+		from:        from,
+		to:          to,
+		conn:        cfg.connection(),
+		asymmetry:   time.Duration(cfg.Asymmetry),
+		attackStart: start.Add(time.Duration(cfg.AttackStart)),
+		attackDelay: time.Duration(cfg.AttackDelay),
+	}
+	p.fwd = simPath{
+		src:     from,
+		dst:     to,
+		dp:      fwd,
+		nextHop: net.UDPAddrFromAddrPort(fromRouter),
+		meta:    meta,
+	}
+	meta = *meta.Copy()
+	meta.Interfaces[0], meta.Interfaces[1] = meta.Interfaces[1], meta.Interfaces[0]
+	p.rev = simPath{
+		src:     to,
+		dst:     from,
+		dp:      rev,
+		nextHop: net.UDPAddrFromAddrPort(toRouter),
+		meta:    meta,
+	}
+	return p
+}
+
+// latency returns the additional latency of a packet sent at true time now
+// along the path in the given direction.
+func (p *pathModel) latency(now time.Time, consDir bool) time.Duration {
+	var d time.Duration
+	if consDir {
+		if p.asymmetry < 0 {
+			d -= p.asymmetry
+		}
+		if p.attackDelay != 0 && !now.Before(p.attackStart) {
+			d += p.attackDelay
+		}
+	} else if p.asymmetry > 0 {
+		d += p.asymmetry
+	}
+	return d
+}
+
+// decodeRoute returns the destination of a SCION packet together with the ID
+// and direction of the simulated path along which it is sent.
+func decodeRoute(b []byte) (dstIA addr.IA, dstHost netip.Addr, id uint16, consDir bool, err error) {
+	var s slayers.SCION
+	err = s.DecodeFromBytes(b, gopacket.NilDecodeFeedback)
+	if err != nil {
+		return
+	}
+	sp, ok := s.Path.(*scion.Raw)
+	if !ok {
+		err = errUnexpectedPath
+		return
+	}
+	info, err := sp.GetInfoField(0)
+	if err != nil {
+		return
+	}
+	dstHost, ok = netip.AddrFromSlice(s.RawDstAddr)
+	if !ok {
+		err = errUnexpectedPath
+		return
+	}
+	return s.DstIA, dstHost.Unmap(), info.SegID, info.ConsDir, nil
+}
+
+// pathID returns the ID of the simulated path in the path header of s, or 0 if
+// s is not sent along a simulated path.
+func pathID(s *slayers.SCION) uint16 {
+	sp, ok := s.Path.(*scion.Raw)
+	if !ok {
+		return 0
+	}
+	info, err := sp.GetInfoField(0)
+	if err != nil {
+		return 0
+	}
+	return info.SegID
+}
+
+func newSimPather(net *Network, localIA addr.IA) *simPather {
+	return &simPather{net: net, localIA: localIA}
+}
+
+func (p *simPather) LocalIA() addr.IA {
+	return p.localIA
+}
+
+func (p *simPather) Paths(dst addr.IA) []snet.Path {
+	return p.net.paths(p.localIA, dst)
+}
@@ -0,0 +1,144 @@
+package simulation
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/slayers"
+
+	"example.com/scion-time/net/scion"
+)
+
+func TestSimPather(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	nw := NewNetwork(NewScheduler(t0), 0)
+	ia0 := addr.MustIAFrom(1, 0xff00_0000_0110)
+	ia1 := addr.MustIAFrom(1, 0xff00_0000_0111)
+	ps := nw.addPaths(ia0, ia1, t0, &PathConfig{Count: 3})
+
+	p0, p1 := newSimPather(nw, ia0), newSimPather(nw, ia1)
+	if n := len(p0.Paths(ia1)); n != 3 {
+		t.Errorf("len(p0.Paths(ia1)) == %d; want 3", n)
+	}
+	nw.setPathDown(ps[1], true)
+	if n := len(p1.Paths(ia0)); n != 2 {
+		t.Errorf("len(p1.Paths(ia0)) == %d; want 2", n)
+	}
+	nw.setPathDown(ps[1], false)
+	if n := len(p1.Paths(ia0)); n != 3 {
+		t.Errorf("len(p1.Paths(ia0)) == %d; want 3", n)
+	}
+	if n := len(p0.Paths(ia0)); n != 1 {
+		t.Errorf("len(p0.Paths(ia0)) == %d; want 1", n)
+	}
+
+	dstHost := netip.MustParseAddr("10.0.1.1")
+	p := p1.Paths(ia0)[2]
+	var s slayers.SCION
+	s.SrcIA, s.DstIA = ia1, ia0
+	err := s.SetSrcAddr(addr.HostIP(netip.MustParseAddr("10.0.0.1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetDstAddr(addr.HostIP(dstHost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Dataplane().SetPath(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.NextHdr = slayers.L4UDP
+	buf := gopacket.NewSerializeBuffer()
+	err = s.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstIA, host, id, consDir, err := decodeRoute(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decodeRoute failed: %v", err)
+	}
+	if !dstIA.Equal(ia0) || host != dstHost || id != ps[2].id || consDir {
+		t.Errorf("decodeRoute() == %v, %v, %d, %t; want %v, %v, %d, false",
+			dstIA, host, id, consDir, ia0, dstHost, ps[2].id)
+	}
+
+	s.Path, err = s.Path.Reverse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DstIA, s.SrcIA = s.SrcIA, s.DstIA
+	buf = gopacket.NewSerializeBuffer()
+	err = s.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, id, consDir, err = decodeRoute(buf.Bytes())
+	if err != nil || id != ps[2].id || !consDir {
+		t.Errorf("decodeRoute() == _, _, %d, %t, %v; want _, _, %d, true, nil",
+			id, consDir, err, ps[2].id)
+	}
+}
+
+func TestRouteImpairments(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sched := NewScheduler(t0)
+	nw := NewNetwork(sched, 0)
+	ia0 := addr.MustIAFrom(1, 0xff00_0000_0110)
+	ia1 := addr.MustIAFrom(1, 0xff00_0000_0111)
+	nw.addPaths(ia0, ia1, t0, &PathConfig{
+		DuplicationChance:               1,
+		MaxDuplicates:                   2,
+		MultipleDuplicateChanceModifier: 1,
+		CorruptionChance:                1,
+	})
+
+	srcHost := netip.MustParseAddr("10.0.0.1")
+	dstHost := netip.MustParseAddr("10.0.1.1")
+	dst := NewSimConnector(nw, NewSimulationClock(sched, 0, ClockModel{}), dstHost)
+	conn, err := dst.ListenUDP("udp", &net.UDPAddr{Port: scion.EndhostPort})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+
+	p := newSimPather(nw, ia0).Paths(ia1)[0]
+	var s slayers.SCION
+	s.SrcIA, s.DstIA = ia0, ia1
+	err = s.SetSrcAddr(addr.HostIP(srcHost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetDstAddr(addr.HostIP(dstHost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Dataplane().SetPath(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.NextHdr = slayers.L4UDP
+	buf := gopacket.NewSerializeBuffer()
+	err = s.SerializeTo(buf, gopacket.SerializeOptions{FixLengths: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nw.send(netip.AddrPortFrom(srcHost, scion.EndhostPort),
+		p.UnderlayNextHop().AddrPort(), buf.Bytes(), 0)
+	sched.Run(t0.Add(time.Second))
+
+	c := conn.(*simConn)
+	if len(c.rxq) != 3 {
+		t.Fatalf("received %d copies of the packet; want 3", len(c.rxq))
+	}
+	for _, pkt := range c.rxq {
+		if bytes.Equal(pkt.buf, buf.Bytes()) {
+			t.Errorf("received uncorrupted copy of the packet")
+		}
+	}
+}
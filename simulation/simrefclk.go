@@ -0,0 +1,98 @@
+package simulation
+
+import (
+	"context"
+	"net"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/core/client"
+
+	"example.com/scion-time/net/ntp"
+	"example.com/scion-time/net/udp"
+)
+
+const (
+	scionRefClockNumClient = 5
+)
+
+// simReferenceClock stands in for a hardware reference clock, e.g., a
+// Meinberg GNSS receiver, and measures the exact offset between true time and
+// the local clock of an instance.
+type simReferenceClock struct {
+	dev   string
+	sched *Scheduler
+	clk   *SimulationClock
+}
+
+type simNTPReferenceClockIP struct {
+	localAddr, remoteAddr *net.UDPAddr
+	ntpc                  *client.IPClient
+}
+
+type simNTPReferenceClockSCION struct {
+	ntpcs      [scionRefClockNumClient]*client.SCIONClient
+	localAddr  udp.UDPAddr
+	remoteAddr udp.UDPAddr
+	pather     *simPather
+}
+
+func (c *simReferenceClock) String() string {
+	return c.dev
+}
+
+func (c *simReferenceClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	now := c.clk.Now()
+	return client.Measurement{
+		Time:   now,
+		Source: c.dev,
+		Offset: c.sched.Now().Sub(now),
+		Weight: 1000.0,
+		RefID:  ntp.ReferenceIDGNSS,
+	}, nil
+}
+
+func newSimNTPReferenceClockIP(localAddr, remoteAddr *net.UDPAddr) *simNTPReferenceClockIP {
+	return &simNTPReferenceClockIP{
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		ntpc: &client.IPClient{
+			InterleavedMode: true,
+		},
+	}
+}
+
+func (c *simNTPReferenceClockIP) String() string {
+	return c.remoteAddr.String()
+}
+
+func (c *simNTPReferenceClockIP) MeasureClockOffset(ctx context.Context, log *zap.Logger) (
+	client.Measurement, error) {
+	return client.MeasureClockOffsetIP(ctx, log, c.ntpc, c.localAddr, c.remoteAddr)
+}
+
+func newSimNTPReferenceClockSCION(localAddr, remoteAddr udp.UDPAddr,
+	pather *simPather) *simNTPReferenceClockSCION {
+	c := &simNTPReferenceClockSCION{
+		localAddr:  localAddr,
+		remoteAddr: remoteAddr,
+		pather:     pather,
+	}
+	for i := 0; i != len(c.ntpcs); i++ {
+		c.ntpcs[i] = &client.SCIONClient{
+			InterleavedMode: true,
+		}
+	}
+	return c
+}
+
+func (c *simNTPReferenceClockSCION) String() string {
+	return c.remoteAddr.IA.String() + "," + c.remoteAddr.Host.String()
+}
+
+func (c *simNTPReferenceClockSCION) MeasureClockOffset(ctx context.Context, log *zap.Logger) (
+	client.Measurement, error) {
+	paths := c.pather.Paths(c.remoteAddr.IA)
+	return client.MeasureClockOffsetSCION(ctx, log, c.ntpcs[:], c.localAddr, c.remoteAddr, paths)
+}
@@ -1,50 +1,368 @@
 package simulation
 
 import (
-	"example.com/scion-time/base/cryptobase"
-	"example.com/scion-time/base/timebase"
+	"context"
+	"math/rand"
+	"net/netip"
 	"time"
+
+	"github.com/scionproto/scion/pkg/addr"
+	"github.com/scionproto/scion/pkg/snet"
+
+	"go.uber.org/zap"
+
+	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/cryptobase"
+	"example.com/scion-time/core/netbase"
+	"example.com/scion-time/core/server"
+	"example.com/scion-time/core/sync"
+	"example.com/scion-time/core/timebase"
+
+	"example.com/scion-time/net/ntp"
+	"example.com/scion-time/net/ntske"
+	"example.com/scion-time/net/udp"
 )
 
-type SimConfig struct {
-	// TODO, just an example for now
-	instances             []instance
-	connections           [][]connection
-	maxSimulationDuration time.Duration
-}
+const (
+	failureCheckInterval = 1 * time.Minute
+)
+
+var (
+	defaultStartTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+)
 
 type instance struct {
-	// TODO, just an example for now
-	instanceType        int8
-	malicious           int8
-	failureChance       float64
-	meanFailureDuration time.Duration
-	minFailureDuration  time.Duration
-	maxFailureDuration  time.Duration
-}
-
-type connection struct {
-	failureChance                   float64
-	dropChance                      float64
-	duplicationChance               float64
-	maxDuplicates                   int32
-	multipleDuplicateChanceModifier float64
-	corruptionChance                float64
-	corruptionSeverity              float64
-	meanLatency                     time.Duration
-	minLatency                      time.Duration
-	maxLatency                      time.Duration
-}
-
-func RunSimulation(lclk timebase.LocalClock, lcrypt cryptobase.CryptoProvider) {
-	// Some logic to read a config file and fill a settings struct
-
-	// Some set up to build the simulated network and start instances
-
-	// Main loop of simulation
-	for condition := true; condition; {
-		// Pass messages around between instances
-		// Drop, corrupt, duplicate, kill, start, disconnect connections and instances as needed
-		condition = false
+	cfg       *InstanceConfig
+	seed      int64
+	localAddr *snet.UDPAddr
+	host      netip.Addr
+	sched     *Scheduler
+	clk       *SimulationClock
+	lnet      *SimConnector
+	crypt     *SimCrypto
+	pather    *simPather
+	rec       *instanceRecorder
+}
+
+func failureDuration(rnd *rand.Rand, mean, min, max Duration) time.Duration {
+	d := time.Duration(min)
+	if mean > min {
+		d += time.Duration(rnd.ExpFloat64() * float64(mean-min))
+	}
+	if max > min && d > time.Duration(max) {
+		d = time.Duration(max)
+	}
+	return d
+}
+
+func scheduleFailure(sched *Scheduler, at time.Time, d time.Duration, setDown func(bool)) {
+	sched.At(at, func() { setDown(true) })
+	sched.At(at.Add(d), func() { setDown(false) })
+}
+
+func scheduleRandomFailures(sched *Scheduler, rnd *rand.Rand, start, end time.Time,
+	chance float64, mean, min, max Duration, setDown func(bool)) {
+	if chance == 0.0 {
+		return
+	}
+	for t := start; t.Before(end); t = t.Add(failureCheckInterval) {
+		if rnd.Float64() < chance {
+			d := failureDuration(rnd, mean, min, max)
+			if d > 0 {
+				scheduleFailure(sched, t, d, setDown)
+				t = t.Add(d)
+			}
+		}
+	}
+}
+
+func newInstance(sched *Scheduler, nw *Network, cfg *InstanceConfig, seed int64) *instance {
+	localAddr, err := snet.ParseUDPAddr(cfg.LocalAddr)
+	if err != nil {
+		panic(err)
+	}
+	host, ok := netip.AddrFromSlice(localAddr.Host.IP)
+	if !ok {
+		panic("unexpected host address")
+	}
+	model := NewClockModel(seed)
+	cfg.Clock.apply(&model)
+	clk := NewSimulationClock(sched, seed, model)
+	return &instance{
+		cfg:       cfg,
+		seed:      seed,
+		localAddr: localAddr,
+		host:      host.Unmap(),
+		sched:     sched,
+		clk:       clk,
+		lnet:      NewSimConnector(nw, clk, host),
+		crypt:     NewSimCrypto(seed),
+		pather:    newSimPather(nw, localAddr.IA),
+		rec:       newInstanceRecorder(sched, cfg.Name, cfg.Malicious != nil),
+	}
+}
+
+func (x *instance) createClocks(log *zap.Logger) (
+	refClocks, netClocks []client.ReferenceClock) {
+	localAddr := snet.CopyUDPAddr(x.localAddr.Host)
+	localAddr.Port = 0
+
+	for _, dev := range x.cfg.MBGReferenceClocks {
+		refClocks = append(refClocks, &simReferenceClock{
+			dev:   dev,
+			sched: x.sched,
+			clk:   x.clk,
+		})
+	}
+
+	for _, s := range x.cfg.NTPReferenceClocks {
+		remoteAddr, err := snet.ParseUDPAddr(s)
+		if err != nil {
+			panic(err)
+		}
+		if !remoteAddr.IA.IsZero() {
+			refClocks = append(refClocks, newSimNTPReferenceClockSCION(
+				udp.UDPAddrFromSnet(x.localAddr),
+				udp.UDPAddrFromSnet(remoteAddr),
+				x.pather,
+			))
+		} else {
+			refClocks = append(refClocks, newSimNTPReferenceClockIP(localAddr, remoteAddr.Host))
+		}
+	}
+
+	for _, s := range x.cfg.SCIONPeers {
+		remoteAddr, err := snet.ParseUDPAddr(s)
+		if err != nil {
+			panic(err)
+		}
+		netClocks = append(netClocks, newSimNTPReferenceClockSCION(
+			udp.UDPAddrFromSnet(x.localAddr),
+			udp.UDPAddrFromSnet(remoteAddr),
+			x.pather,
+		))
+	}
+
+	return
+}
+
+// context returns a context in which the core time service packages use the
+// instance's own clock, crypto and net providers and keep separate state.
+func (x *instance) context(refClocks, netClocks []client.ReferenceClock) context.Context {
+	syncCfg, err := x.cfg.FileConfig.Config()
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	ctx = timebase.WithClock(ctx, &recordingClock{SimulationClock: x.clk, rec: x.rec})
+	ctx = cryptobase.WithCrypto(ctx, x.crypt)
+	ctx = netbase.WithNetProvider(ctx, x.lnet)
+	ctx = client.WithFilters(ctx)
+	ctx = client.WithFilterObserver(ctx, x.rec)
+	ctx = server.WithTimestampStore(ctx)
+	ctx = sync.WithClocks(ctx, refClocks, netClocks, syncCfg)
+	return ctx
+}
+
+func (x *instance) start(log *zap.Logger) {
+	log = log.With(zap.String("instance", x.cfg.Name))
+	refClocks, netClocks := x.createClocks(log)
+	ctx := x.context(refClocks, netClocks)
+
+	x.sched.Go(func() {
+		provider := ntske.NewProvider()
+
+		// Without a separate dispatcher, the SCION server of an instance also
+		// forwards the responses to its own SCION clients and therefore has to
+		// be started before the initial synchronization.
+		if !x.localAddr.IA.IsZero() {
+			if x.cfg.Type != InstanceTypeClient {
+				localAddr := snet.CopyUDPAddr(x.localAddr.Host)
+				localAddr.Port = ntp.ServerPortSCION
+				server.StartSCIONServer(ctx, log, "" /* daemonAddr */, localAddr, 0 /* DSCP */, provider)
+			} else {
+				for _, c := range refClocks {
+					_, ok := c.(*simNTPReferenceClockSCION)
+					if ok {
+						server.StartSCIONDispatcher(ctx, log, snet.CopyUDPAddr(x.localAddr.Host))
+						break
+					}
+				}
+			}
+		}
+
+		if len(refClocks) != 0 {
+			sync.SyncToRefClocks(ctx, log)
+			x.sched.Go(func() { sync.RunLocalClockSync(ctx, log) })
+		}
+
+		if len(netClocks) != 0 {
+			x.sched.Go(func() { sync.RunGlobalClockSync(ctx, log) })
+		}
+
+		if x.cfg.Type != InstanceTypeClient {
+			localAddr := snet.CopyUDPAddr(x.localAddr.Host)
+			localAddr.Port = ntp.ServerPortIP
+			server.StartIPServer(ctx, log, localAddr, 0 /* DSCP */, provider)
+		}
+	})
+}
+
+func (x *instance) sample(t time.Time) {
+	x.rec.recordOffset(t, x.clk.Offset(), x.clk.SyncQuality())
+}
+
+func RunSimulation(log *zap.Logger, cfg SimConfig, seed int64) *Results {
+	err := cfg.Validate()
+	if err != nil {
+		log.Fatal("invalid simulation configuration", zap.Error(err))
+	}
+
+	rnd := rand.New(rand.NewSource(seed))
+	start := cfg.StartTime
+	if start.IsZero() {
+		start = defaultStartTime
+	}
+	end := start.Add(time.Duration(cfg.Duration))
+
+	sched := NewScheduler(start)
+	nw := NewNetwork(sched, rnd.Int63())
+	nw.setDefaultConnection(cfg.DefaultLink.connection())
+
+	insts := make(map[string]*instance, len(cfg.Instances))
+	for i := range cfg.Instances {
+		c := &cfg.Instances[i]
+		insts[c.Name] = newInstance(sched, nw, c, rnd.Int63())
+	}
+
+	for i := range cfg.Instances {
+		c := &cfg.Instances[i]
+		if c.Malicious != nil {
+			targets := make(map[netip.Addr]bool, len(c.Malicious.Targets))
+			for _, t := range c.Malicious.Targets {
+				targets[insts[t].host] = true
+			}
+			insts[c.Name].lnet.bhv = newBehavior(c.Malicious, start, targets)
+		}
+	}
+
+	for i := range cfg.Links {
+		l := &cfg.Links[i]
+		x, y := insts[l.From], insts[l.To]
+		nw.setConnection(x.host, y.host, l.connection())
+		if l.Bidirectional {
+			nw.setConnection(y.host, x.host, l.connection())
+		}
+		scheduleRandomFailures(sched, rnd, start, end,
+			l.FailureChance, l.MeanFailureDuration, l.MinFailureDuration, l.MaxFailureDuration,
+			func(down bool) {
+				log.Info("link state changed",
+					zap.String("from", l.From), zap.String("to", l.To), zap.Bool("down", down))
+				nw.setLinkDown(x.host, y.host, down)
+				if l.Bidirectional {
+					nw.setLinkDown(y.host, x.host, down)
+				}
+			})
+	}
+
+	for i := range cfg.Paths {
+		p := &cfg.Paths[i]
+		from, err := addr.ParseIA(p.From)
+		if err != nil {
+			panic(err)
+		}
+		to, err := addr.ParseIA(p.To)
+		if err != nil {
+			panic(err)
+		}
+		for _, m := range nw.addPaths(from, to, start, p) {
+			m := m
+			scheduleRandomFailures(sched, rnd, start, end,
+				p.FailureChance, p.MeanFailureDuration, p.MinFailureDuration, p.MaxFailureDuration,
+				func(down bool) {
+					log.Info("path state changed",
+						zap.Stringer("from", m.from), zap.Stringer("to", m.to),
+						zap.Uint16("id", m.id), zap.Bool("down", down))
+					nw.setPathDown(m, down)
+				})
+		}
+	}
+
+	for i := range cfg.Instances {
+		c := &cfg.Instances[i]
+		x := insts[c.Name]
+		scheduleRandomFailures(sched, rnd, start, end,
+			c.FailureChance, c.MeanFailureDuration, c.MinFailureDuration, c.MaxFailureDuration,
+			func(down bool) {
+				log.Info("instance state changed", zap.String("name", c.Name), zap.Bool("down", down))
+				nw.setHostDown(x.host, down)
+			})
+	}
+
+	for i := range cfg.Failures {
+		f := &cfg.Failures[i]
+		x := insts[f.Instance]
+		at := start.Add(time.Duration(f.At))
+		if f.Peer == "" {
+			scheduleFailure(sched, at, time.Duration(f.Duration), func(down bool) {
+				log.Info("instance state changed", zap.String("name", f.Instance), zap.Bool("down", down))
+				nw.setHostDown(x.host, down)
+			})
+		} else {
+			y := insts[f.Peer]
+			scheduleFailure(sched, at, time.Duration(f.Duration), func(down bool) {
+				log.Info("link state changed",
+					zap.String("from", f.Instance), zap.String("to", f.Peer), zap.Bool("down", down))
+				nw.setLinkDown(x.host, y.host, down)
+				nw.setLinkDown(y.host, x.host, down)
+			})
+		}
+	}
+
+	for i := range cfg.Instances {
+		insts[cfg.Instances[i].Name].start(log)
+	}
+
+	sampleInterval := time.Duration(cfg.SampleInterval)
+	if sampleInterval == 0 {
+		sampleInterval = defaultSampleInterval
+	}
+	var sample func()
+	sample = func() {
+		now := sched.Now()
+		for i := range cfg.Instances {
+			insts[cfg.Instances[i].Name].sample(now)
+		}
+		sched.At(now.Add(sampleInterval), sample)
+	}
+	sched.At(start, sample)
+
+	log.Info("simulation started", zap.Time("at", start), zap.Int("instances", len(insts)))
+	sched.Run(end)
+	log.Info("simulation finished", zap.Time("at", sched.Now()))
+
+	res := &Results{
+		Seed:  seed,
+		Start: start,
+		End:   end,
+	}
+	for i := range cfg.Instances {
+		x := insts[cfg.Instances[i].Name]
+		x.rec.mu.Lock()
+		res.Instances = append(res.Instances, x.rec.res)
+		x.rec.mu.Unlock()
+	}
+	summarize(res, start.Add(time.Duration(cfg.SettlingTime)))
+	for _, x := range res.Instances {
+		log.Info("instance results",
+			zap.String("name", x.Name),
+			zap.Bool("malicious", x.Malicious),
+			zap.Duration("max deviation", x.MaxDeviation),
+		)
 	}
+	log.Info("simulation results",
+		zap.Duration("max skew", res.Summary.MaxSkew),
+		zap.Duration("max deviation", res.Summary.MaxDeviation),
+	)
+	return res
 }
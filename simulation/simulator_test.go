@@ -0,0 +1,316 @@
+package simulation_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"example.com/scion-time/base/timemath"
+
+	"example.com/scion-time/core/sync"
+
+	"example.com/scion-time/simulation"
+)
+
+func TestRunSimulation(t *testing.T) {
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(2 * time.Minute),
+		SettlingTime: simulation.Duration(1 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(1 * time.Millisecond),
+		},
+		Instances: []simulation.InstanceConfig{{
+			Name:               "server",
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          "0-0,10.0.0.1",
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+		}, {
+			Name:               "client",
+			Type:               simulation.InstanceTypeClient,
+			LocalAddr:          "0-0,10.0.0.2",
+			NTPReferenceClocks: []string{"0-0,10.0.0.1:123"},
+		}},
+	}
+	res := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+
+	if len(res.Instances) != 2 {
+		t.Fatalf("len(res.Instances) == %d; want 2", len(res.Instances))
+	}
+	for _, x := range res.Instances {
+		if len(x.Offsets) != 121 {
+			t.Errorf("len(%s.Offsets) == %d; want 121", x.Name, len(x.Offsets))
+		}
+	}
+	if len(res.Instances[1].Measurements) == 0 {
+		t.Errorf("no peer measurements recorded")
+	}
+	if res.Summary.MaxDeviation > 5*time.Millisecond {
+		t.Errorf("res.Summary.MaxDeviation == %v; want <= 5ms", res.Summary.MaxDeviation)
+	}
+	for _, s := range res.Instances[1].Offsets {
+		if !s.Time.Before(res.Start.Add(time.Duration(cfg.SettlingTime))) &&
+			(!s.Synchronized || timemath.Abs(s.Offset) > s.MaxError) {
+			t.Errorf("client sample %+v not within max error", s)
+		}
+	}
+}
+
+func TestRunSimulationDeterministic(t *testing.T) {
+	cfg := simulation.SimConfig{
+		Duration: simulation.Duration(1 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(2 * time.Millisecond),
+		},
+		Instances: []simulation.InstanceConfig{{
+			Name:               "server",
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          "1-ff00:0:110,10.0.0.1",
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+		}, {
+			Name:               "client",
+			Type:               simulation.InstanceTypeClient,
+			LocalAddr:          "1-ff00:0:111,10.0.1.1",
+			NTPReferenceClocks: []string{"1-ff00:0:110,10.0.0.1:10123"},
+		}},
+		Paths: []simulation.PathConfig{{
+			From:        "1-ff00:0:110",
+			To:          "1-ff00:0:111",
+			Count:       4,
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(2 * time.Millisecond),
+		}},
+	}
+	a := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+	for i := 0; i != 3; i++ {
+		b := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+		if !reflect.DeepEqual(a.Instances, b.Instances) {
+			t.Fatalf("results of repeated simulation runs with the same seed differ")
+		}
+	}
+}
+
+func TestRunSimulationSCION(t *testing.T) {
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(2 * time.Minute),
+		SettlingTime: simulation.Duration(1 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(100 * time.Microsecond),
+			MeanLatency: simulation.Duration(100 * time.Microsecond),
+		},
+		Instances: []simulation.InstanceConfig{{
+			Name:               "server",
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          "1-ff00:0:110,10.0.0.1",
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+		}, {
+			Name:               "client",
+			Type:               simulation.InstanceTypeClient,
+			LocalAddr:          "1-ff00:0:111,10.0.1.1",
+			NTPReferenceClocks: []string{"1-ff00:0:110,10.0.0.1:10123"},
+		}},
+		Paths: []simulation.PathConfig{{
+			From:        "1-ff00:0:110",
+			To:          "1-ff00:0:111",
+			Count:       4,
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(1 * time.Millisecond),
+		}, {
+			From:        "1-ff00:0:110",
+			To:          "1-ff00:0:111",
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(1 * time.Millisecond),
+			AttackDelay: simulation.Duration(50 * time.Millisecond),
+		}},
+	}
+	res := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+
+	if len(res.Instances[1].Measurements) == 0 {
+		t.Errorf("no peer measurements recorded")
+	}
+	if res.Summary.MaxDeviation > 5*time.Millisecond {
+		t.Errorf("res.Summary.MaxDeviation == %v; want <= 5ms", res.Summary.MaxDeviation)
+	}
+}
+
+func TestRunSimulationKalman(t *testing.T) {
+	freq := 10e-6
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(4 * time.Minute),
+		SettlingTime: simulation.Duration(2 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(2 * time.Millisecond),
+		},
+		Instances: []simulation.InstanceConfig{{
+			Name:               "server",
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          "0-0,10.0.0.1",
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+			FileConfig:         sync.FileConfig{LocalSync: sync.LoopFileConfig{Discipline: "kalman"}},
+		}, {
+			Name:               "client",
+			Type:               simulation.InstanceTypeClient,
+			LocalAddr:          "0-0,10.0.0.2",
+			NTPReferenceClocks: []string{"0-0,10.0.0.1:123"},
+			FileConfig:         sync.FileConfig{LocalSync: sync.LoopFileConfig{Discipline: "kalman"}},
+			Clock:              simulation.ClockConfig{Frequency: &freq},
+		}},
+	}
+	res := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+
+	if res.Summary.MaxDeviation > 1*time.Millisecond {
+		t.Errorf("res.Summary.MaxDeviation == %v; want <= 1ms", res.Summary.MaxDeviation)
+	}
+}
+
+func TestRunSimulationHoldover(t *testing.T) {
+	freq := 10e-6
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(8 * time.Minute),
+		SettlingTime: simulation.Duration(2 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(1 * time.Millisecond),
+			MeanLatency: simulation.Duration(1 * time.Millisecond),
+		},
+		Instances: []simulation.InstanceConfig{{
+			Name:               "server",
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          "0-0,10.0.0.1",
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+		}, {
+			Name:               "client",
+			Type:               simulation.InstanceTypeClient,
+			LocalAddr:          "0-0,10.0.0.2",
+			NTPReferenceClocks: []string{"0-0,10.0.0.1:123"},
+			Clock:              simulation.ClockConfig{Frequency: &freq},
+		}},
+		Failures: []simulation.FailureConfig{{
+			Instance: "server",
+			At:       simulation.Duration(3 * time.Minute),
+			Duration: simulation.Duration(3 * time.Minute),
+		}},
+	}
+	res := simulation.RunSimulation(zap.NewNop(), cfg, 1)
+
+	if res.Summary.MaxDeviation > 2*time.Millisecond {
+		t.Errorf("res.Summary.MaxDeviation == %v; want <= 2ms", res.Summary.MaxDeviation)
+	}
+}
+
+func TestRunSimulationByzantineSCIONPeer(t *testing.T) {
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(10 * time.Minute),
+		SettlingTime: simulation.Duration(2 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(100 * time.Microsecond),
+			MeanLatency: simulation.Duration(100 * time.Microsecond),
+		},
+	}
+	ias := []string{"1-ff00:0:110", "1-ff00:0:111", "1-ff00:0:112", "1-ff00:0:113"}
+	for i := 0; i != len(ias); i++ {
+		x := simulation.InstanceConfig{
+			Name:               fmt.Sprintf("server-%d", i+1),
+			Type:               simulation.InstanceTypeServer,
+			LocalAddr:          fmt.Sprintf("%s,10.0.%d.1", ias[i], i),
+			MBGReferenceClocks: []string{"/dev/mbgclock0"},
+			FileConfig:         sync.FileConfig{GlobalSync: sync.LoopFileConfig{Aggregation: "ftm"}},
+		}
+		for j := 0; j != len(ias); j++ {
+			if j != i {
+				x.SCIONPeers = append(x.SCIONPeers, fmt.Sprintf("%s,10.0.%d.1:10123", ias[j], j))
+			}
+			if j > i {
+				cfg.Paths = append(cfg.Paths, simulation.PathConfig{
+					From:        ias[i],
+					To:          ias[j],
+					Count:       2,
+					MinLatency:  simulation.Duration(1 * time.Millisecond),
+					MeanLatency: simulation.Duration(1 * time.Millisecond),
+				})
+			}
+		}
+		cfg.Instances = append(cfg.Instances, x)
+	}
+	cfg.Instances[3].Malicious = &simulation.MaliciousConfig{
+		Behavior: simulation.BehaviorConstantOffset,
+		Offset:   simulation.Duration(50 * time.Millisecond),
+	}
+	core, logs := observer.New(zap.InfoLevel)
+	res := simulation.RunSimulation(zap.New(core), cfg, 1)
+
+	if res.Summary.MaxDeviation > 5*time.Millisecond {
+		t.Errorf("res.Summary.MaxDeviation == %v; want <= 5ms", res.Summary.MaxDeviation)
+	}
+	var k int
+	for _, e := range logs.FilterMessage("excluding falseticker").All() {
+		ctx := e.ContextMap()
+		if ctx["loop"] == "global" && strings.Contains(fmt.Sprint(ctx["source"]), "10.0.3.1") {
+			k++
+		}
+	}
+	if k == 0 {
+		t.Errorf("malicious SCION peer never excluded as falseticker")
+	}
+}
+
+func TestRunSimulationPeersOnly(t *testing.T) {
+	// Three servers which only peer with each other via SCION.
+	cfg := simulation.SimConfig{
+		Duration:     simulation.Duration(60 * time.Minute),
+		SettlingTime: simulation.Duration(5 * time.Minute),
+		DefaultLink: simulation.LinkConfig{
+			MinLatency:  simulation.Duration(100 * time.Microsecond),
+			MeanLatency: simulation.Duration(100 * time.Microsecond),
+		},
+	}
+	ias := []string{"1-ff00:0:110", "1-ff00:0:111", "1-ff00:0:112"}
+	offsets := []time.Duration{-20 * time.Millisecond, 0, 20 * time.Millisecond}
+	for i := 0; i != len(ias); i++ {
+		off := simulation.Duration(offsets[i])
+		x := simulation.InstanceConfig{
+			Name:       fmt.Sprintf("server-%d", i+1),
+			Type:       simulation.InstanceTypeServer,
+			LocalAddr:  fmt.Sprintf("%s,10.0.%d.1", ias[i], i),
+			Clock:      simulation.ClockConfig{Offset: &off},
+			FileConfig: sync.FileConfig{GlobalSync: sync.LoopFileConfig{Aggregation: "ftm"}},
+		}
+		for j := 0; j != len(ias); j++ {
+			if j != i {
+				x.SCIONPeers = append(x.SCIONPeers, fmt.Sprintf("%s,10.0.%d.1:10123", ias[j], j))
+			}
+			if j > i {
+				cfg.Paths = append(cfg.Paths, simulation.PathConfig{
+					From:        ias[i],
+					To:          ias[j],
+					MinLatency:  simulation.Duration(1 * time.Millisecond),
+					MeanLatency: simulation.Duration(1 * time.Millisecond),
+				})
+			}
+		}
+		cfg.Instances = append(cfg.Instances, x)
+	}
+	core, logs := observer.New(zap.InfoLevel)
+	res := simulation.RunSimulation(zap.New(core), cfg, 1)
+
+	// Servers without a reference clock must answer their peers before they
+	// are synchronized, and their strata must not count up to unsynchronized
+	// while they synchronize with each other.
+	if n := logs.FilterMessage("failed to measure clock offset").Len(); n != 0 {
+		t.Errorf("%d failed measurements between peers; want 0", n)
+	}
+	for _, x := range res.Instances {
+		if len(x.Measurements) == 0 {
+			t.Errorf("%s never measured its peers", x.Name)
+		}
+	}
+	if res.Summary.MaxSkew > 5*time.Millisecond {
+		t.Errorf("res.Summary.MaxSkew == %v; want <= 5ms", res.Summary.MaxSkew)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/hex"
+	"encoding/json"
 	"example.com/scion-time/core/netbase"
 	"example.com/scion-time/driver/networking"
 	"flag"
@@ -14,7 +15,10 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/mmcloughlin/profile"
@@ -31,6 +35,7 @@ import (
 	"go.uber.org/zap/zapcore"
 
 	"example.com/scion-time/base/crypto"
+	"example.com/scion-time/base/leap"
 
 	"example.com/scion-time/simulation"
 
@@ -63,20 +68,40 @@ const (
 )
 
 type svcConfig struct {
-	LocalAddr               string   `toml:"local_address,omitempty"`
-	DaemonAddr              string   `toml:"daemon_address,omitempty"`
-	RemoteAddr              string   `toml:"remote_address,omitempty"`
-	MBGReferenceClocks      []string `toml:"mbg_reference_clocks,omitempty"`
-	NTPReferenceClocks      []string `toml:"ntp_reference_clocks,omitempty"`
-	SCIONPeers              []string `toml:"scion_peers,omitempty"`
-	NTSKECertFile           string   `toml:"ntske_cert_file,omitempty"`
-	NTSKEKeyFile            string   `toml:"ntske_key_file,omitempty"`
-	NTSKEServerName         string   `toml:"ntske_server_name,omitempty"`
-	AuthModes               []string `toml:"auth_modes,omitempty"`
-	NTSKEInsecureSkipVerify bool     `toml:"ntske_insecure_skip_verify,omitempty"`
-	DSCP                    uint8    `toml:"dscp,omitempty"` // must be in range [0, 63]
+	LocalAddr               string       `toml:"local_address,omitempty"`
+	DaemonAddr              string       `toml:"daemon_address,omitempty"`
+	RemoteAddr              string       `toml:"remote_address,omitempty"`
+	MBGReferenceClocks      []string     `toml:"mbg_reference_clocks,omitempty"`
+	NTPReferenceClocks      []string     `toml:"ntp_reference_clocks,omitempty"`
+	SCIONPeers              []string     `toml:"scion_peers,omitempty"`
+	NTSKECertFile           string       `toml:"ntske_cert_file,omitempty"`
+	NTSKEKeyFile            string       `toml:"ntske_key_file,omitempty"`
+	NTSKEServerName         string       `toml:"ntske_server_name,omitempty"`
+	AuthModes               []string     `toml:"auth_modes,omitempty"`
+	NTSKEInsecureSkipVerify bool         `toml:"ntske_insecure_skip_verify,omitempty"`
+	DSCP                    uint8        `toml:"dscp,omitempty"` // must be in range [0, 63]
+	LeapSecondsFile         string       `toml:"leap_seconds_file,omitempty"`
+	LeapSmear               leapSmear    `toml:"leap_smear,omitempty"`
+	DriftFile               string       `toml:"drift_file,omitempty"`
+	ClockTolerance          float64      `toml:"clock_tolerance,omitempty"`
+	MeasuredDrift           bool         `toml:"measured_drift,omitempty"`
+	VirtualClock            virtualClock `toml:"virtual_clock,omitempty"`
+	TrueTimeSocket          string       `toml:"truetime_socket,omitempty"`
+	sync.FileConfig
 }
 
+type virtualClock struct {
+	Enabled  bool   `toml:"enabled,omitempty"`
+	TimeFile string `toml:"time_file,omitempty"`
+}
+
+type leapSmear struct {
+	Mode     string   `toml:"mode,omitempty"`
+	Duration duration `toml:"duration,omitempty"`
+}
+
+type duration time.Duration
+
 type mbgReferenceClock struct {
 	dev string
 }
@@ -136,8 +161,14 @@ func initLogger(verbose bool) {
 	}
 }
 
+func serveSourceStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(sync.GetSourceStatus(r.Context()))
+}
+
 func runMonitor(log *zap.Logger) {
 	http.Handle("/metrics", promhttp.Handler())
+	http.HandleFunc("/sources", serveSourceStatus)
 	err := http.ListenAndServe("127.0.0.1:8080", nil)
 	log.Fatal("failed to serve metrics", zap.Error(err))
 }
@@ -150,6 +181,15 @@ func ntskeServerFromRemoteAddr(remoteAddr string) string {
 	return split[1]
 }
 
+func (d *duration) UnmarshalText(text []byte) error {
+	x, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration(x)
+	return nil
+}
+
 func (c *tlsCertCache) loadCert(chi *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	now := time.Now()
 	if now.Before(c.reloadedAt) || !now.Before(c.reloadedAt.Add(tlsCertReloadInterval)) {
@@ -163,9 +203,21 @@ func (c *tlsCertCache) loadCert(chi *tls.ClientHelloInfo) (*tls.Certificate, err
 	return c.cert, nil
 }
 
+func (c *mbgReferenceClock) String() string {
+	return c.dev
+}
+
 func (c *mbgReferenceClock) MeasureClockOffset(ctx context.Context, log *zap.Logger) (
-	time.Duration, error) {
-	return mbg.MeasureClockOffset(ctx, log, c.dev)
+	client.Measurement, error) {
+	off, li, err := mbg.MeasureClockOffset(ctx, log, c.dev)
+	return client.Measurement{
+		Time:   timebase.Now(ctx),
+		Source: c.dev,
+		Offset: off,
+		Weight: 1000.0,
+		RefID:  ntp.ReferenceIDGNSS,
+		Leap:   li,
+	}, err
 }
 
 func configureIPClientNTS(c *client.IPClient, ntskeServer string, ntskeInsecureSkipVerify bool) {
@@ -200,10 +252,13 @@ func newNTPReferenceClockIP(localAddr, remoteAddr *net.UDPAddr, dscp uint8,
 	return c
 }
 
+func (c *ntpReferenceClockIP) String() string {
+	return c.remoteAddr.String()
+}
+
 func (c *ntpReferenceClockIP) MeasureClockOffset(ctx context.Context, log *zap.Logger) (
-	time.Duration, error) {
-	_, off, err := client.MeasureClockOffsetIP(ctx, log, c.ntpc, c.localAddr, c.remoteAddr)
-	return off, err
+	client.Measurement, error) {
+	return client.MeasureClockOffsetIP(ctx, log, c.ntpc, c.localAddr, c.remoteAddr)
 }
 
 func configureSCIONClientNTS(c *client.SCIONClient, ntskeServer string, ntskeInsecureSkipVerify bool, daemonAddr string, localAddr, remoteAddr udp.UDPAddr) {
@@ -244,8 +299,12 @@ func newNTPReferenceClockSCION(daemonAddr string, localAddr, remoteAddr udp.UDPA
 	return c
 }
 
+func (c *ntpReferenceClockSCION) String() string {
+	return c.remoteAddr.IA.String() + "," + c.remoteAddr.Host.String()
+}
+
 func (c *ntpReferenceClockSCION) MeasureClockOffset(ctx context.Context, log *zap.Logger) (
-	time.Duration, error) {
+	client.Measurement, error) {
 	paths := c.pather.Paths(c.remoteAddr.IA)
 	return client.MeasureClockOffsetSCION(ctx, log, c.ntpcs[:], c.localAddr, c.remoteAddr, paths)
 }
@@ -298,6 +357,92 @@ func dscp(cfg svcConfig) uint8 {
 	return cfg.DSCP
 }
 
+func syncConfig(cfg svcConfig) sync.Config {
+	c, err := cfg.FileConfig.Config()
+	if err != nil {
+		log.Fatal("invalid sync configuration", zap.Error(err))
+	}
+	if cfg.LeapSecondsFile != "" {
+		c.LeapSeconds, err = leap.Load(cfg.LeapSecondsFile)
+		if err != nil {
+			log.Fatal("failed to load leap seconds file", zap.Error(err))
+		}
+	}
+	c.DriftFile = cfg.DriftFile
+	c.IntervalTolerance = cfg.ClockTolerance
+	err = c.Validate()
+	if err != nil {
+		log.Fatal("invalid sync configuration", zap.Error(err))
+	}
+	return c
+}
+
+func systemClock(cfg svcConfig) *clock.SystemClock {
+	if cfg.ClockTolerance < 0 || cfg.ClockTolerance >= 1 {
+		log.Fatal("invalid clock tolerance specified in config")
+	}
+	clk := &clock.SystemClock{
+		Log:           log,
+		Tolerance:     cfg.ClockTolerance,
+		MeasuredDrift: cfg.MeasuredDrift,
+	}
+	clock.CheckTimeDaemons(log)
+	go clk.DetectForeignSteps()
+	return clk
+}
+
+// registerClock registers the local clock to be disciplined: the system clock
+// or, if configured, a virtual clock, which requires no privileges.
+func registerClock(cfg svcConfig) {
+	if cfg.VirtualClock.Enabled {
+		if cfg.ClockTolerance < 0 || cfg.ClockTolerance >= 1 {
+			log.Fatal("invalid clock tolerance specified in config")
+		}
+		timebase.RegisterClock(
+			clock.NewVirtualClock(log, cfg.ClockTolerance, cfg.VirtualClock.TimeFile))
+		return
+	}
+	timebase.RegisterClock(systemClock(cfg))
+}
+
+func saveDriftOnShutdown(ctx context.Context, cfg svcConfig) {
+	if cfg.DriftFile == "" {
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	for _, sig := range []os.Signal{syscall.SIGINT, syscall.SIGTERM} {
+		if !signal.Ignored(sig) {
+			signal.Notify(sigs, sig)
+		}
+	}
+	go func() {
+		sig := <-sigs
+		log.Info("shutting down", zap.Stringer("signal", sig))
+		sync.SaveDrift(ctx, log)
+		// Terminate as if the signal had not been handled at all.
+		signal.Reset(sig)
+		err := syscall.Kill(os.Getpid(), sig.(syscall.Signal))
+		if err != nil {
+			log.Fatal("failed to terminate", zap.Error(err))
+		}
+	}()
+}
+
+func withLeapSmear(ctx context.Context, cfg svcConfig) context.Context {
+	if cfg.LeapSmear.Mode == "" {
+		return ctx
+	}
+	s := server.LeapSmear{
+		Mode:     cfg.LeapSmear.Mode,
+		Duration: time.Duration(cfg.LeapSmear.Duration),
+	}
+	err := s.Validate()
+	if err != nil {
+		log.Fatal("invalid leap_smear configuration", zap.Error(err))
+	}
+	return server.WithLeapSmear(ctx, s)
+}
+
 func tlsConfig(cfg svcConfig) *tls.Config {
 	if cfg.NTSKEServerName == "" || cfg.NTSKECertFile == "" || cfg.NTSKEKeyFile == "" {
 		log.Fatal("missing parameters in configuration for NTSKE server")
@@ -426,10 +571,10 @@ func runServer(configFile string) {
 
 	localAddr.Host.Port = 0
 	refClocks, netClocks := createClocks(cfg, localAddr)
-	sync.RegisterClocks(refClocks, netClocks)
+	sync.RegisterClocks(refClocks, netClocks, syncConfig(cfg))
+	saveDriftOnShutdown(ctx, cfg)
 
-	lclk := &clock.SystemClock{Log: log}
-	timebase.RegisterClock(lclk)
+	registerClock(cfg)
 
 	lcrypt := &crypto.SafeCrypto{}
 	cryptobase.RegisterCrypto(lcrypt)
@@ -438,14 +583,16 @@ func runServer(configFile string) {
 	netbase.RegisterNetProvider(lnet)
 
 	if len(refClocks) != 0 {
-		sync.SyncToRefClocks(log, lclk)
-		go sync.RunLocalClockSync(log, lclk)
+		sync.SyncToRefClocks(ctx, log)
+		go sync.RunLocalClockSync(ctx, log)
 	}
 
 	if len(netClocks) != 0 {
-		go sync.RunGlobalClockSync(log, lclk)
+		go sync.RunGlobalClockSync(ctx, log)
 	}
 
+	ctx = withLeapSmear(ctx, cfg)
+
 	dscp := dscp(cfg)
 	tlsConfig := tlsConfig(cfg)
 	provider := ntske.NewProvider()
@@ -458,6 +605,10 @@ func runServer(configFile string) {
 	server.StartNTSKEServerSCION(ctx, log, udp.UDPAddrFromSnet(localAddr), tlsConfig, provider)
 	server.StartSCIONServer(ctx, log, daemonAddr, snet.CopyUDPAddr(localAddr.Host), dscp, provider)
 
+	if cfg.TrueTimeSocket != "" {
+		server.StartTrueTimeServer(ctx, log, cfg.TrueTimeSocket)
+	}
+
 	runMonitor(log)
 }
 
@@ -470,10 +621,10 @@ func runRelay(configFile string) {
 
 	localAddr.Host.Port = 0
 	refClocks, netClocks := createClocks(cfg, localAddr)
-	sync.RegisterClocks(refClocks, netClocks)
+	sync.RegisterClocks(refClocks, netClocks, syncConfig(cfg))
+	saveDriftOnShutdown(ctx, cfg)
 
-	lclk := &clock.SystemClock{Log: log}
-	timebase.RegisterClock(lclk)
+	registerClock(cfg)
 
 	lcrypt := &crypto.SafeCrypto{}
 	cryptobase.RegisterCrypto(lcrypt)
@@ -482,14 +633,16 @@ func runRelay(configFile string) {
 	netbase.RegisterNetProvider(lnet)
 
 	if len(refClocks) != 0 {
-		sync.SyncToRefClocks(log, lclk)
-		go sync.RunLocalClockSync(log, lclk)
+		sync.SyncToRefClocks(ctx, log)
+		go sync.RunLocalClockSync(ctx, log)
 	}
 
 	if len(netClocks) != 0 {
 		log.Fatal("unexpected configuration", zap.Int("number of peers", len(netClocks)))
 	}
 
+	ctx = withLeapSmear(ctx, cfg)
+
 	dscp := dscp(cfg)
 	tlsConfig := tlsConfig(cfg)
 	provider := ntske.NewProvider()
@@ -502,6 +655,10 @@ func runRelay(configFile string) {
 	server.StartNTSKEServerSCION(ctx, log, udp.UDPAddrFromSnet(localAddr), tlsConfig, provider)
 	server.StartSCIONServer(ctx, log, daemonAddr, snet.CopyUDPAddr(localAddr.Host), dscp, provider)
 
+	if cfg.TrueTimeSocket != "" {
+		server.StartTrueTimeServer(ctx, log, cfg.TrueTimeSocket)
+	}
+
 	runMonitor(log)
 }
 
@@ -513,10 +670,10 @@ func runClient(configFile string) {
 
 	localAddr.Host.Port = 0
 	refClocks, netClocks := createClocks(cfg, localAddr)
-	sync.RegisterClocks(refClocks, netClocks)
+	sync.RegisterClocks(refClocks, netClocks, syncConfig(cfg))
+	saveDriftOnShutdown(ctx, cfg)
 
-	lclk := &clock.SystemClock{Log: log}
-	timebase.RegisterClock(lclk)
+	registerClock(cfg)
 
 	lcrypt := &crypto.SafeCrypto{}
 	cryptobase.RegisterCrypto(lcrypt)
@@ -537,14 +694,18 @@ func runClient(configFile string) {
 	}
 
 	if len(refClocks) != 0 {
-		sync.SyncToRefClocks(log, lclk)
-		go sync.RunLocalClockSync(log, lclk)
+		sync.SyncToRefClocks(ctx, log)
+		go sync.RunLocalClockSync(ctx, log)
 	}
 
 	if len(netClocks) != 0 {
 		log.Fatal("unexpected configuration", zap.Int("number of peers", len(netClocks)))
 	}
 
+	if cfg.TrueTimeSocket != "" {
+		server.StartTrueTimeServer(ctx, log, cfg.TrueTimeSocket)
+	}
+
 	runMonitor(log)
 }
 
@@ -572,14 +733,14 @@ func runIPTool(localAddr, remoteAddr *snet.UDPAddr, dscp uint8,
 	}
 
 	for {
-		at, off, err := client.MeasureClockOffsetIP(ctx, log, c, laddr, raddr)
+		m, err := client.MeasureClockOffsetIP(ctx, log, c, laddr, raddr)
 		if err != nil {
 			log.Fatal("failed to measure clock offset", zap.Stringer("to", raddr), zap.Error(err))
 		}
 		if !periodic {
 			break
 		}
-		fmt.Printf("%s,%+.9f,%t\n", at.UTC().Format(time.RFC3339), off.Seconds(), c.InInterleavedMode())
+		fmt.Printf("%s,%+.9f,%t\n", m.Time.UTC().Format(time.RFC3339), m.Offset.Seconds(), c.InInterleavedMode())
 		lclk.Sleep(1 * time.Second)
 	}
 }
@@ -691,15 +852,26 @@ func runSCIONBenchmark(daemonAddr string, localAddr, remoteAddr *snet.UDPAddr, a
 	benchmark.RunSCIONBenchmark(daemonAddr, localAddr, remoteAddr, authModes, ntskeServer, log)
 }
 
-func runSimulation(seed int64) {
-	lclk := simulation.NewSimulationClock(seed)
-	timebase.RegisterClock(lclk)
-
-	lcrypt := simulation.NewSimCrypto(seed)
-	cryptobase.RegisterCrypto(lcrypt)
-
-	lnet := &simulation.SimConnector{}
-	netbase.RegisterNetProvider(lnet)
+func runSimulation(scenarioFile string, seed int64, resultsDir string) {
+	cfg, err := simulation.LoadSimConfig(scenarioFile)
+	if err != nil {
+		log.Fatal("failed to load scenario", zap.Error(err))
+	}
+	res := simulation.RunSimulation(log, cfg, seed)
+	if resultsDir != "" {
+		err = os.MkdirAll(resultsDir, 0o755)
+		if err != nil {
+			log.Fatal("failed to create results directory", zap.Error(err))
+		}
+		err = res.WriteCSV(resultsDir)
+		if err != nil {
+			log.Fatal("failed to write simulation results", zap.Error(err))
+		}
+		err = res.WriteJSON(filepath.Join(resultsDir, "results.json"))
+		if err != nil {
+			log.Fatal("failed to write simulation results", zap.Error(err))
+		}
+	}
 }
 
 func runDRKeyDemo(daemonAddr string, serverMode bool, serverAddr, clientAddr *snet.UDPAddr) {
@@ -763,6 +935,9 @@ func main() {
 	var (
 		verbose                 bool
 		configFile              string
+		scenarioFile            string
+		seed                    int64
+		resultsDir              string
 		daemonAddr              string
 		localAddr               snet.UDPAddr
 		remoteAddrStr           string
@@ -783,6 +958,7 @@ func main() {
 	toolFlags := flag.NewFlagSet("tool", flag.ExitOnError)
 	benchmarkFlags := flag.NewFlagSet("benchmark", flag.ExitOnError)
 	drkeyFlags := flag.NewFlagSet("drkey", flag.ExitOnError)
+	simFlags := flag.NewFlagSet("sim", flag.ExitOnError)
 
 	serverFlags.BoolVar(&verbose, "verbose", false, "Verbose logging")
 	serverFlags.StringVar(&configFile, "config", "", "Config file")
@@ -813,6 +989,11 @@ func main() {
 	drkeyFlags.Var(&drkeyServerAddr, "server", "Server address")
 	drkeyFlags.Var(&drkeyClientAddr, "client", "Client address")
 
+	simFlags.BoolVar(&verbose, "verbose", false, "Verbose logging")
+	simFlags.StringVar(&scenarioFile, "scenario", "", "Scenario file")
+	simFlags.Int64Var(&seed, "seed", 0, "Random seed")
+	simFlags.StringVar(&resultsDir, "results", "", "Directory for simulation results")
+
 	if len(os.Args) < 2 {
 		exitWithUsage()
 	}
@@ -912,6 +1093,16 @@ func main() {
 		serverMode := drkeyMode == "server"
 		initLogger(verbose)
 		runDRKeyDemo(daemonAddr, serverMode, &drkeyServerAddr, &drkeyClientAddr)
+	case simFlags.Name():
+		err := simFlags.Parse(os.Args[2:])
+		if err != nil || simFlags.NArg() != 0 {
+			exitWithUsage()
+		}
+		if scenarioFile == "" {
+			exitWithUsage()
+		}
+		initLogger(verbose)
+		runSimulation(scenarioFile, seed, resultsDir)
 	case "x":
 		runX()
 	default:
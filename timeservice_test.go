@@ -7,13 +7,21 @@ import (
 	"example.com/scion-time/core/cryptobase"
 	"example.com/scion-time/core/netbase"
 	"example.com/scion-time/driver/networking"
+	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
 
 	"example.com/scion-time/core/client"
+	"example.com/scion-time/core/server"
+	"example.com/scion-time/core/sync"
 	"example.com/scion-time/core/timebase"
 	"example.com/scion-time/driver/clock"
+	"example.com/scion-time/net/truetime"
 	"github.com/scionproto/scion/pkg/snet"
 )
 
@@ -71,8 +79,61 @@ func TestTimeserviceNTSChrony(t *testing.T) {
 	c.Auth.NTSKEFetcher.Port = ntskePort
 	c.Auth.NTSKEFetcher.Log = log
 
-	_, _, err = client.MeasureClockOffsetIP(ctx, log, c, laddr, raddr)
+	_, err = client.MeasureClockOffsetIP(ctx, log, c, laddr, raddr)
 	if err != nil {
 		t.Fatalf("failed to measure clock offset %v", err)
 	}
 }
+
+type trueTimeTestClock struct{}
+
+func (c *trueTimeTestClock) MeasureClockOffset(context.Context, *zap.Logger) (
+	client.Measurement, error) {
+	return client.Measurement{
+		Delay:          2 * time.Millisecond,
+		Weight:         1000.0,
+		Stratum:        1,
+		RootDelay:      3 * time.Millisecond,
+		RootDispersion: 1 * time.Millisecond,
+	}, nil
+}
+
+func TestTrueTimeDefaultTolerance(t *testing.T) {
+	cfg := svcConfig{
+		TrueTimeSocket: filepath.Join(t.TempDir(), "truetime.sock"),
+		MeasuredDrift:  true,
+	}
+
+	// Serving time intervals must not change the maximum drift of the local
+	// clock, which limits the corrections of the synchronization loops.
+	lclk := &clock.SystemClock{
+		Log:           zap.NewNop(),
+		Tolerance:     cfg.ClockTolerance,
+		MeasuredDrift: cfg.MeasuredDrift,
+	}
+	if d := lclk.MaxDrift(time.Second); d != math.MaxInt64 {
+		t.Fatalf("lclk.MaxDrift(1s) == %v; want unbounded", d)
+	}
+	lclk.SetStability(1e-9)
+
+	ctx := timebase.WithClock(context.Background(), lclk)
+	ctx = sync.WithClocks(ctx, []client.ReferenceClock{&trueTimeTestClock{}}, nil, syncConfig(cfg))
+	server.StartTrueTimeServer(ctx, zap.NewNop(), cfg.TrueTimeSocket)
+	c, err := truetime.Dial(cfg.TrueTimeSocket)
+	if err != nil {
+		t.Fatalf("truetime.Dial failed: %v", err)
+	}
+	defer c.Close()
+
+	sync.SyncToRefClocks(ctx, zap.NewNop())
+	time.Sleep(10 * time.Millisecond)
+	earliest, latest, err := c.Now()
+	if err != nil {
+		t.Fatalf("c.Now() failed: %v", err)
+	}
+	// The interval grows at the default tolerance of 500 ppm, not at the
+	// measured stability of the local clock.
+	if d := latest.Sub(earliest); d < 7010*time.Microsecond || d > 8*time.Millisecond {
+		t.Errorf("c.Now() returned interval of %v; want 7.01ms to 8ms", d)
+	}
+}